@@ -1,8 +1,10 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/damianiandrea/mongodb-nats-connector/internal/config"
 	"github.com/damianiandrea/mongodb-nats-connector/pkg/connector"
@@ -11,6 +13,11 @@ import (
 const defaultConfigFileName = "connector.yaml"
 
 func main() {
+	resetTokens := flag.String("reset-tokens", os.Getenv("RESET_TOKENS"),
+		"comma-separated namespaces ('db.coll', 'db' or '*') whose stored resume tokens are ignored, to start them at "+
+			"their startAt position")
+	flag.Parse()
+
 	configFileName := getEnvOrDefault("CONFIG_FILE", defaultConfigFileName)
 	cfg, err := config.Load(configFileName)
 	if err != nil {
@@ -22,22 +29,91 @@ func main() {
 		connector.WithMongoUri(getEnvOrDefault("MONGO_URI", cfg.Connector.Mongo.Uri)),
 		connector.WithNatsUrl(getEnvOrDefault("NATS_URL", cfg.Connector.Nats.Url)),
 		connector.WithServerAddr(getEnvOrDefault("SERVER_ADDR", cfg.Connector.Server.Addr)),
+		connector.WithAdminAddr(getEnvOrDefault("SERVER_ADMIN_ADDR", cfg.Connector.Server.AdminAddr)),
+		connector.WithNatsConnection(connector.NatsConnectionConfig{
+			Name:             cfg.Connector.Nats.Name,
+			Urls:             cfg.Connector.Nats.Urls,
+			MaxReconnects:    cfg.Connector.Nats.MaxReconnects,
+			ReconnectWait:    cfg.Connector.Nats.ReconnectWait,
+			ReconnectJitter:  cfg.Connector.Nats.ReconnectJitter,
+			PingInterval:     cfg.Connector.Nats.PingInterval,
+			MaxPingsOut:      cfg.Connector.Nats.MaxPingsOut,
+			ReconnectBufSize: cfg.Connector.Nats.ReconnectBufSize,
+		}),
+	}
+	if auth := cfg.Connector.Mongo.Auth; auth != nil {
+		opts = append(opts, connector.WithMongoAuth(connector.MongoAuthConfig{
+			Mechanism:           auth.Mechanism,
+			MechanismProperties: auth.MechanismProperties,
+			Source:              auth.Source,
+			Username:            auth.Username,
+			Password:            auth.Password,
+			PasswordFile:        auth.PasswordFile,
+		}))
+	}
+	if tls := cfg.Connector.Mongo.TLS; tls != nil {
+		opts = append(opts, connector.WithMongoTLS(connector.MongoTLSConfig{
+			CAFile:              tls.CAFile,
+			CertKeyFile:         tls.CertKeyFile,
+			CertKeyPassword:     tls.CertKeyPassword,
+			CertKeyPasswordFile: tls.CertKeyPasswordFile,
+		}))
+	}
+	if readPreference := cfg.Connector.Mongo.ReadPreference; readPreference != nil {
+		opts = append(opts, connector.WithMongoReadPreference(connector.MongoReadPreferenceConfig{
+			Mode:         readPreference.Mode,
+			MaxStaleness: readPreference.MaxStaleness,
+		}))
+	}
+	if auth := cfg.Connector.Nats.Auth; auth != nil {
+		opts = append(opts, connector.WithNatsAuth(connector.NatsAuthConfig{
+			CredsFile:    auth.CredsFile,
+			NkeySeedFile: auth.NkeySeedFile,
+			Token:        auth.Token,
+			TokenFile:    auth.TokenFile,
+			User:         auth.User,
+			Password:     auth.Password,
+			PasswordFile: auth.PasswordFile,
+		}))
+	}
+	if tls := cfg.Connector.Nats.TLS; tls != nil {
+		opts = append(opts, connector.WithNatsTLS(connector.NatsTLSConfig{
+			CAFile:     tls.CAFile,
+			CertFile:   tls.CertFile,
+			KeyFile:    tls.KeyFile,
+			ServerName: tls.ServerName,
+		}))
+	}
+	if backoff := cfg.Connector.Backoff; backoff != nil {
+		opts = append(opts, connector.WithBackoff(connector.BackoffConfig{
+			Initial:     backoff.Initial,
+			Max:         backoff.Max,
+			Multiplier:  backoff.Multiplier,
+			Jitter:      backoff.Jitter,
+			MaxAttempts: backoff.MaxAttempts,
+		}))
 	}
 	for _, coll := range cfg.Connector.Collections {
-		collOpts := []connector.CollectionOption{
-			connector.WithTokensDbName(coll.TokensDbName),
-			connector.WithTokensCollName(coll.TokensCollName),
-			connector.WithStreamName(coll.StreamName),
-		}
-		// nolint:staticcheck
-		if coll.ChangeStreamPreAndPostImages != nil && *coll.ChangeStreamPreAndPostImages {
-			collOpts = append(collOpts, connector.WithChangeStreamPreAndPostImages())
-		}
-		if coll.TokensCollCapped != nil && coll.TokensCollSizeInBytes != nil && *coll.TokensCollCapped {
-			collOpts = append(collOpts, connector.WithTokensCollCapped(*coll.TokensCollSizeInBytes))
-		}
-		opt := connector.WithCollection(coll.DbName, coll.CollName, collOpts...)
-		opts = append(opts, opt)
+		opts = append(opts, connector.WithCollection(coll.DbName, coll.CollName, collectionOptions(coll)...))
+	}
+	for _, db := range cfg.Connector.Databases {
+		opts = append(opts, connector.WithDatabase(db.DbName, collectionOptions(db)...))
+	}
+	if cluster := cfg.Connector.Cluster; cluster != nil {
+		opts = append(opts, connector.WithCluster(collectionOptions(cluster)...))
+	}
+	for _, pattern := range cfg.Connector.CollectionPatterns {
+		opts = append(opts, connector.WithCollectionPattern(connector.CollectionPatternConfig{
+			DbName:                 pattern.DbName,
+			CollNamePattern:        pattern.CollNamePattern,
+			StreamNameTemplate:     pattern.StreamName,
+			TokensCollNameTemplate: pattern.TokensCollName,
+			DiscoveryInterval:      pattern.DiscoveryInterval,
+		}, collectionOptions(&pattern.Collection)...))
+	}
+
+	if *resetTokens != "" {
+		opts = append(opts, connector.WithResetTokens(strings.Split(*resetTokens, ",")...))
 	}
 
 	if conn, err := connector.New(opts...); err != nil {
@@ -47,6 +123,78 @@ func main() {
 	}
 }
 
+func collectionOptions(coll *config.Collection) []connector.CollectionOption {
+	collOpts := []connector.CollectionOption{
+		connector.WithTokensStore(coll.TokensStore),
+		connector.WithTokensDbName(coll.TokensDbName),
+		connector.WithTokensCollName(coll.TokensCollName),
+		connector.WithStreamName(coll.StreamName),
+		connector.WithSubjectTemplate(coll.SubjectTemplate),
+		connector.WithHeaders(coll.Headers),
+		connector.WithPipeline(coll.Pipeline),
+		connector.WithOperationTypes(coll.OperationTypes...),
+		connector.WithFullDocument(coll.FullDocument),
+		connector.WithFullDocumentBeforeChange(coll.FullDocumentBeforeChange),
+		connector.WithFailurePolicy(coll.FailurePolicy),
+		connector.WithSnapshot(coll.Snapshot),
+		connector.WithOnInvalidate(coll.OnInvalidate),
+		connector.WithOnHistoryLost(coll.OnHistoryLost),
+		connector.WithStartAt(coll.StartAt),
+	}
+	// nolint:staticcheck
+	if coll.ChangeStreamPreAndPostImages != nil && *coll.ChangeStreamPreAndPostImages {
+		collOpts = append(collOpts, connector.WithChangeStreamPreAndPostImages())
+	}
+	if coll.TokensCollCapped != nil && coll.TokensCollSizeInBytes != nil && *coll.TokensCollCapped {
+		collOpts = append(collOpts, connector.WithTokensCollCapped(*coll.TokensCollSizeInBytes))
+	}
+	if stream := coll.Stream; stream != nil {
+		collOpts = append(collOpts, connector.WithStreamConfig(connector.StreamConfig{
+			Storage:         stream.Storage,
+			Retention:       stream.Retention,
+			Replicas:        stream.Replicas,
+			MaxAge:          stream.MaxAge,
+			MaxBytes:        stream.MaxBytes,
+			MaxMsgs:         stream.MaxMsgs,
+			Discard:         stream.Discard,
+			DuplicateWindow: stream.DuplicateWindow,
+		}))
+	}
+	if changeStream := coll.ChangeStream; changeStream != nil {
+		collOpts = append(collOpts, connector.WithChangeStreamConfig(connector.ChangeStreamConfig{
+			BatchSize:          changeStream.BatchSize,
+			MaxAwaitTime:       changeStream.MaxAwaitTime,
+			Collation:          changeStream.Collation,
+			ShowExpandedEvents: changeStream.ShowExpandedEvents,
+			Comment:            changeStream.Comment,
+		}))
+	}
+	if publish := coll.Publish; publish != nil {
+		collOpts = append(collOpts, connector.WithPublishConfig(connector.PublishConfig{
+			Mode:       publish.Mode,
+			MaxPending: publish.MaxPending,
+		}))
+	}
+	if checkpoint := coll.Checkpoint; checkpoint != nil {
+		collOpts = append(collOpts, connector.WithCheckpoint(connector.CheckpointConfig{
+			Every:    checkpoint.Every,
+			Interval: checkpoint.Interval,
+		}))
+	}
+	if deadLetter := coll.DeadLetter; deadLetter != nil {
+		collOpts = append(collOpts, connector.WithDeadLetter(connector.DeadLetterConfig{
+			Store:           deadLetter.Store,
+			StreamName:      deadLetter.StreamName,
+			DbName:          deadLetter.DbName,
+			CollName:        deadLetter.CollName,
+			MaxRetries:      deadLetter.MaxRetries,
+			RetryBackoff:    deadLetter.RetryBackoff,
+			MaxRetryBackoff: deadLetter.MaxRetryBackoff,
+		}))
+	}
+	return collOpts
+}
+
 func getEnvOrDefault(env, def string) string {
 	if val, found := os.LookupEnv(env); found {
 		return val
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -31,7 +32,14 @@ type Connector struct {
 	Mongo       Mongo         `yaml:"mongo"`
 	Nats        Nats          `yaml:"nats"`
 	Server      Server        `yaml:"server"`
+	Backoff     *Backoff      `yaml:"backoff"`
 	Collections []*Collection `yaml:"collections"`
+	// Databases and Cluster accept the same settings as Collections, except for collName, and dbName for the latter.
+	Databases []*Collection `yaml:"databases"`
+	Cluster   *Collection   `yaml:"cluster"`
+	// CollectionPatterns accept the same settings as Collections, except for collName, and their streamName and
+	// tokensCollName are templates.
+	CollectionPatterns []*CollectionPattern `yaml:"collectionPatterns"`
 }
 
 type Log struct {
@@ -39,25 +47,147 @@ type Log struct {
 }
 
 type Mongo struct {
-	Uri string `yaml:"uri"`
+	Uri            string               `yaml:"uri"`
+	Auth           *MongoAuth           `yaml:"auth,omitempty"`
+	TLS            *MongoTLS            `yaml:"tls,omitempty"`
+	ReadPreference *MongoReadPreference `yaml:"readPreference,omitempty"`
+}
+
+type MongoAuth struct {
+	Mechanism           string            `yaml:"mechanism,omitempty"`
+	MechanismProperties map[string]string `yaml:"mechanismProperties,omitempty"`
+	Source              string            `yaml:"source,omitempty"`
+	Username            string            `yaml:"username,omitempty"`
+	Password            string            `yaml:"password,omitempty"`
+	PasswordFile        string            `yaml:"passwordFile,omitempty"`
+}
+
+type MongoTLS struct {
+	CAFile              string `yaml:"caFile,omitempty"`
+	CertKeyFile         string `yaml:"certKeyFile,omitempty"`
+	CertKeyPassword     string `yaml:"certKeyPassword,omitempty"`
+	CertKeyPasswordFile string `yaml:"certKeyPasswordFile,omitempty"`
+}
+
+type MongoReadPreference struct {
+	Mode         string        `yaml:"mode,omitempty"`
+	MaxStaleness time.Duration `yaml:"maxStaleness,omitempty"`
 }
 
 type Nats struct {
-	Url string `yaml:"url"`
+	Url              string        `yaml:"url"`
+	Urls             []string      `yaml:"urls,omitempty"`
+	Name             string        `yaml:"name,omitempty"`
+	MaxReconnects    int           `yaml:"maxReconnects,omitempty"`
+	ReconnectWait    time.Duration `yaml:"reconnectWait,omitempty"`
+	ReconnectJitter  time.Duration `yaml:"reconnectJitter,omitempty"`
+	PingInterval     time.Duration `yaml:"pingInterval,omitempty"`
+	MaxPingsOut      int           `yaml:"maxPingsOut,omitempty"`
+	ReconnectBufSize int           `yaml:"reconnectBufSize,omitempty"`
+	Auth             *NatsAuth     `yaml:"auth,omitempty"`
+	TLS              *NatsTLS      `yaml:"tls,omitempty"`
+}
+
+type NatsAuth struct {
+	CredsFile    string `yaml:"credsFile,omitempty"`
+	NkeySeedFile string `yaml:"nkeySeedFile,omitempty"`
+	Token        string `yaml:"token,omitempty"`
+	TokenFile    string `yaml:"tokenFile,omitempty"`
+	User         string `yaml:"user,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	PasswordFile string `yaml:"passwordFile,omitempty"`
+}
+
+type NatsTLS struct {
+	CAFile     string `yaml:"caFile,omitempty"`
+	CertFile   string `yaml:"certFile,omitempty"`
+	KeyFile    string `yaml:"keyFile,omitempty"`
+	ServerName string `yaml:"serverName,omitempty"`
 }
 
 type Server struct {
-	Addr string `yaml:"addr"`
+	Addr      string `yaml:"addr"`
+	AdminAddr string `yaml:"adminAddr,omitempty"`
+}
+
+type Backoff struct {
+	Initial     time.Duration `yaml:"initial,omitempty"`
+	Max         time.Duration `yaml:"max,omitempty"`
+	Multiplier  float64       `yaml:"multiplier,omitempty"`
+	Jitter      float64       `yaml:"jitter,omitempty"`
+	MaxAttempts int           `yaml:"maxAttempts,omitempty"`
 }
 
 type Collection struct {
 	DbName   string `yaml:"dbName,omitempty"`
 	CollName string `yaml:"collName,omitempty"`
 	// Deprecated: will be removed in future versions. Set this configuration directly on MongoDB instead.
-	ChangeStreamPreAndPostImages *bool  `yaml:"changeStreamPreAndPostImages,omitempty"`
-	TokensDbName                 string `yaml:"tokensDbName,omitempty"`
-	TokensCollName               string `yaml:"tokensCollName,omitempty"`
-	TokensCollCapped             *bool  `yaml:"tokensCollCapped,omitempty"`
-	TokensCollSizeInBytes        *int64 `yaml:"tokensCollSizeInBytes,omitempty"`
-	StreamName                   string `yaml:"streamName,omitempty"`
+	ChangeStreamPreAndPostImages *bool             `yaml:"changeStreamPreAndPostImages,omitempty"`
+	TokensStore                  string            `yaml:"tokensStore,omitempty"`
+	TokensDbName                 string            `yaml:"tokensDbName,omitempty"`
+	TokensCollName               string            `yaml:"tokensCollName,omitempty"`
+	TokensCollCapped             *bool             `yaml:"tokensCollCapped,omitempty"`
+	TokensCollSizeInBytes        *int64            `yaml:"tokensCollSizeInBytes,omitempty"`
+	StreamName                   string            `yaml:"streamName,omitempty"`
+	Stream                       *Stream           `yaml:"stream,omitempty"`
+	ChangeStream                 *ChangeStream     `yaml:"changeStream,omitempty"`
+	Publish                      *Publish          `yaml:"publish,omitempty"`
+	Checkpoint                   *Checkpoint       `yaml:"checkpoint,omitempty"`
+	SubjectTemplate              string            `yaml:"subjectTemplate,omitempty"`
+	Headers                      map[string]string `yaml:"headers,omitempty"`
+	Pipeline                     string            `yaml:"pipeline,omitempty"`
+	OperationTypes               []string          `yaml:"operationTypes,omitempty"`
+	FullDocument                 string            `yaml:"fullDocument,omitempty"`
+	FullDocumentBeforeChange     string            `yaml:"fullDocumentBeforeChange,omitempty"`
+	DeadLetter                   *DeadLetter       `yaml:"deadLetter,omitempty"`
+	FailurePolicy                string            `yaml:"failurePolicy,omitempty"`
+	Snapshot                     string            `yaml:"snapshot,omitempty"`
+	OnInvalidate                 string            `yaml:"onInvalidate,omitempty"`
+	OnHistoryLost                string            `yaml:"onHistoryLost,omitempty"`
+	StartAt                      string            `yaml:"startAt,omitempty"`
+}
+
+type Stream struct {
+	Storage         string        `yaml:"storage,omitempty"`
+	Retention       string        `yaml:"retention,omitempty"`
+	Replicas        int           `yaml:"replicas,omitempty"`
+	MaxAge          time.Duration `yaml:"maxAge,omitempty"`
+	MaxBytes        int64         `yaml:"maxBytes,omitempty"`
+	MaxMsgs         int64         `yaml:"maxMsgs,omitempty"`
+	Discard         string        `yaml:"discard,omitempty"`
+	DuplicateWindow time.Duration `yaml:"duplicateWindow,omitempty"`
+}
+
+type CollectionPattern struct {
+	Collection        `yaml:",inline"`
+	CollNamePattern   string        `yaml:"collNamePattern"`
+	DiscoveryInterval time.Duration `yaml:"discoveryInterval,omitempty"`
+}
+
+type ChangeStream struct {
+	BatchSize          int32         `yaml:"batchSize,omitempty"`
+	MaxAwaitTime       time.Duration `yaml:"maxAwaitTime,omitempty"`
+	Collation          string        `yaml:"collation,omitempty"`
+	ShowExpandedEvents bool          `yaml:"showExpandedEvents,omitempty"`
+	Comment            string        `yaml:"comment,omitempty"`
+}
+
+type Publish struct {
+	Mode       string `yaml:"mode,omitempty"`
+	MaxPending int    `yaml:"maxPending,omitempty"`
+}
+
+type Checkpoint struct {
+	Every    int           `yaml:"every,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+type DeadLetter struct {
+	Store           string        `yaml:"store,omitempty"`
+	StreamName      string        `yaml:"streamName,omitempty"`
+	DbName          string        `yaml:"dbName,omitempty"`
+	CollName        string        `yaml:"collName,omitempty"`
+	MaxRetries      int           `yaml:"maxRetries,omitempty"`
+	RetryBackoff    time.Duration `yaml:"retryBackoff,omitempty"`
+	MaxRetryBackoff time.Duration `yaml:"maxRetryBackoff,omitempty"`
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -15,10 +16,44 @@ connector:
     level: "debug"
   mongo:
     uri: "mongodb://127.0.0.1:27017,127.0.0.1:27018,127.0.0.1:27019/?replicaSet=mongodb-nats-connector"
+    auth:
+      mechanism: "MONGODB-X509"
+    tls:
+      caFile: "/etc/mongo/ca.pem"
+      certKeyFile: "/etc/mongo/client.pem"
+      certKeyPasswordFile: "/run/secrets/mongo-key-password"
+    readPreference:
+      mode: "secondaryPreferred"
+      maxStaleness: "2m"
   nats:
     url: "nats://127.0.0.1:4222"
+    urls:
+      - "nats://127.0.0.1:4223"
+      - "nats://127.0.0.1:4224"
+    name: "mongodb-nats-connector"
+    maxReconnects: -1
+    reconnectWait: "5s"
+    reconnectJitter: "1s"
+    pingInterval: "30s"
+    maxPingsOut: 3
+    reconnectBufSize: 16777216
+    auth:
+      user: "connector"
+      passwordFile: "/run/secrets/nats-password"
+    tls:
+      caFile: "/etc/nats/ca.pem"
+      certFile: "/etc/nats/cert.pem"
+      keyFile: "/etc/nats/key.pem"
+      serverName: "nats.internal"
   server:
     addr: ":8080"
+    adminAddr: "127.0.0.1:8081"
+  backoff:
+    initial: "500ms"
+    max: "30s"
+    multiplier: 1.5
+    jitter: 0.1
+    maxAttempts: 10
   collections:
     - dbName: "test-connector"
       collName: "coll1"
@@ -28,13 +63,74 @@ connector:
       tokensCollCapped: true
       tokensCollSizeInBytes: 4096
       streamName: "COLL1"
+      stream:
+        storage: "file"
+        retention: "limits"
+        replicas: 3
+        maxAge: "72h"
+        maxBytes: 1073741824
+        maxMsgs: 1000000
+        discard: "old"
+        duplicateWindow: "2m"
+      changeStream:
+        batchSize: 500
+        maxAwaitTime: "200ms"
+        collation: '{"locale": "en", "strength": 2}'
+        showExpandedEvents: true
+        comment: "mongodb-nats-connector"
+      publish:
+        mode: "async"
+        maxPending: 512
+      checkpoint:
+        every: 100
+        interval: "5s"
+      pipeline: '[{"$match": {"operationType": "insert"}}]'
+      operationTypes: ["insert", "drop", "invalidate"]
+      fullDocument: "required"
+      fullDocumentBeforeChange: "off"
+      deadLetter:
+        store: "nats"
+        streamName: "COLL1_DLQ"
+        maxRetries: 5
+        retryBackoff: "1s"
+        maxRetryBackoff: "30s"
+      failurePolicy: "stop"
+      snapshot: "initial"
+      onInvalidate: "follow-rename"
+      onHistoryLost: "resnapshot"
     - dbName: "test-connector"
       collName: "coll2"
       changeStreamPreAndPostImages: true
+      tokensStore: "mongo"
+      subjectTemplate: "{{.Stream}}.{{.OperationType}}.{{.FullDocument.tenantId}}"
+      headers:
+        Source: "connector"
       tokensDbName: "resume-tokens"
       tokensCollName: "coll2"
       tokensCollCapped: false
       streamName: "COLL2"
+      startAt: "2024-06-06T12:00:00Z"
+  databases:
+    - dbName: "test-connector-db"
+      tokensDbName: "resume-tokens"
+      tokensCollName: "test-connector-db"
+      streamName: "DB"
+      deadLetter:
+        store: "mongo"
+        dbName: "dead-letters"
+        collName: "test-connector-db"
+  cluster:
+    tokensDbName: "resume-tokens"
+    tokensCollName: "cluster"
+    streamName: "CLUSTER"
+  collectionPatterns:
+    - dbName: "test-connector-customers"
+      collNamePattern: "customer_*"
+      discoveryInterval: "1m"
+      tokensDbName: "resume-tokens"
+      tokensCollName: "{{.CollName}}"
+      streamName: "CUSTOMERS_{{upper .CollName}}"
+      snapshot: "initial"
 `
 
 var invalidYamlConfig = `
@@ -63,8 +159,40 @@ func TestLoad(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, logLevel, config.Connector.Log.Level)
 		require.Equal(t, mongoUri, config.Connector.Mongo.Uri)
+		require.Equal(t, &MongoAuth{Mechanism: "MONGODB-X509"}, config.Connector.Mongo.Auth)
+		require.Equal(t, &MongoTLS{
+			CAFile:              "/etc/mongo/ca.pem",
+			CertKeyFile:         "/etc/mongo/client.pem",
+			CertKeyPasswordFile: "/run/secrets/mongo-key-password",
+		}, config.Connector.Mongo.TLS)
+		require.Equal(t, &MongoReadPreference{Mode: "secondaryPreferred", MaxStaleness: 2 * time.Minute},
+			config.Connector.Mongo.ReadPreference)
 		require.Equal(t, natsUrl, config.Connector.Nats.Url)
+		require.Equal(t, []string{"nats://127.0.0.1:4223", "nats://127.0.0.1:4224"}, config.Connector.Nats.Urls)
+		require.Equal(t, "mongodb-nats-connector", config.Connector.Nats.Name)
+		require.Equal(t, -1, config.Connector.Nats.MaxReconnects)
+		require.Equal(t, 5*time.Second, config.Connector.Nats.ReconnectWait)
+		require.Equal(t, time.Second, config.Connector.Nats.ReconnectJitter)
+		require.Equal(t, 30*time.Second, config.Connector.Nats.PingInterval)
+		require.Equal(t, 3, config.Connector.Nats.MaxPingsOut)
+		require.Equal(t, 16777216, config.Connector.Nats.ReconnectBufSize)
+		require.Equal(t, &NatsAuth{User: "connector", PasswordFile: "/run/secrets/nats-password"},
+			config.Connector.Nats.Auth)
+		require.Equal(t, &NatsTLS{
+			CAFile:     "/etc/nats/ca.pem",
+			CertFile:   "/etc/nats/cert.pem",
+			KeyFile:    "/etc/nats/key.pem",
+			ServerName: "nats.internal",
+		}, config.Connector.Nats.TLS)
 		require.Equal(t, addr, config.Connector.Server.Addr)
+		require.Equal(t, "127.0.0.1:8081", config.Connector.Server.AdminAddr)
+		require.Equal(t, &Backoff{
+			Initial:     500 * time.Millisecond,
+			Max:         30 * time.Second,
+			Multiplier:  1.5,
+			Jitter:      0.1,
+			MaxAttempts: 10,
+		}, config.Connector.Backoff)
 		require.Contains(t, config.Connector.Collections, &Collection{
 			DbName:                       "test-connector",
 			CollName:                     "coll1",
@@ -74,15 +202,80 @@ func TestLoad(t *testing.T) {
 			TokensCollCapped:             &capped,
 			TokensCollSizeInBytes:        &collSize,
 			StreamName:                   "COLL1",
+			Stream: &Stream{
+				Storage:         "file",
+				Retention:       "limits",
+				Replicas:        3,
+				MaxAge:          72 * time.Hour,
+				MaxBytes:        1073741824,
+				MaxMsgs:         1000000,
+				Discard:         "old",
+				DuplicateWindow: 2 * time.Minute,
+			},
+			ChangeStream: &ChangeStream{
+				BatchSize:          500,
+				MaxAwaitTime:       200 * time.Millisecond,
+				Collation:          `{"locale": "en", "strength": 2}`,
+				ShowExpandedEvents: true,
+				Comment:            "mongodb-nats-connector",
+			},
+			Publish:                  &Publish{Mode: "async", MaxPending: 512},
+			Checkpoint:               &Checkpoint{Every: 100, Interval: 5 * time.Second},
+			Pipeline:                 `[{"$match": {"operationType": "insert"}}]`,
+			OperationTypes:           []string{"insert", "drop", "invalidate"},
+			FullDocument:             "required",
+			FullDocumentBeforeChange: "off",
+			DeadLetter: &DeadLetter{
+				Store:           "nats",
+				StreamName:      "COLL1_DLQ",
+				MaxRetries:      5,
+				RetryBackoff:    1 * time.Second,
+				MaxRetryBackoff: 30 * time.Second,
+			},
+			FailurePolicy: "stop",
+			Snapshot:      "initial",
+			OnInvalidate:  "follow-rename",
+			OnHistoryLost: "resnapshot",
 		})
 		require.Contains(t, config.Connector.Collections, &Collection{
 			DbName:                       "test-connector",
 			CollName:                     "coll2",
 			ChangeStreamPreAndPostImages: &csPrePostImages,
+			TokensStore:                  "mongo",
+			SubjectTemplate:              "{{.Stream}}.{{.OperationType}}.{{.FullDocument.tenantId}}",
+			Headers:                      map[string]string{"Source": "connector"},
 			TokensDbName:                 "resume-tokens",
 			TokensCollName:               "coll2",
 			TokensCollCapped:             &nonCapped,
 			StreamName:                   "COLL2",
+			StartAt:                      "2024-06-06T12:00:00Z",
+		})
+		require.Contains(t, config.Connector.Databases, &Collection{
+			DbName:         "test-connector-db",
+			TokensDbName:   "resume-tokens",
+			TokensCollName: "test-connector-db",
+			StreamName:     "DB",
+			DeadLetter: &DeadLetter{
+				Store:    "mongo",
+				DbName:   "dead-letters",
+				CollName: "test-connector-db",
+			},
+		})
+		require.Equal(t, &Collection{
+			TokensDbName:   "resume-tokens",
+			TokensCollName: "cluster",
+			StreamName:     "CLUSTER",
+		}, config.Connector.Cluster)
+		require.Contains(t, config.Connector.CollectionPatterns, &CollectionPattern{
+			Collection: Collection{
+				DbName:         "test-connector-customers",
+				TokensDbName:   "resume-tokens",
+				TokensCollName: "{{.CollName}}",
+				StreamName:     "CUSTOMERS_{{upper .CollName}}",
+				Snapshot:       "initial",
+			},
+			CollNamePattern:   "customer_*",
+			DiscoveryInterval: 1 * time.Minute,
 		})
 	})
 	t.Run("when file not found should return error", func(t *testing.T) {
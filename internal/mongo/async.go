@@ -0,0 +1,102 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AsyncChangeEventHandler publishes the change event without waiting for its acknowledgement. The returned channel
+// receives nil once the change event is acknowledged, or the error that prevented it.
+type AsyncChangeEventHandler func(ctx context.Context, event *ChangeEvent) (<-chan error, error)
+
+// pendingEvent represents a change event published asynchronously, whose acknowledgement is awaited.
+type pendingEvent struct {
+	event *ChangeEvent
+	raw   bson.Raw // a copy of the change event, the current one of the change stream is overwritten
+	start time.Time
+	ack   <-chan error
+}
+
+// publishAsync publishes the given change event with the AsyncChangeEventHandler, and appends it to the pending change
+// events. A change event that could not even be published is appended along with its error, so that it is handled
+// in order with the others.
+func (w *watcher) publishAsync(ctx context.Context, event *ChangeEvent, raw bson.Raw, start time.Time) {
+	ack, err := w.opts.AsyncChangeEventHandler(ctx, event)
+	if err != nil {
+		failed := make(chan error, 1)
+		failed <- err
+		ack = failed
+	}
+	w.pending = append(w.pending, &pendingEvent{event: event, raw: bson.Raw(append([]byte(nil), raw...)), start: start,
+		ack: ack})
+}
+
+// commitPending removes the acknowledged change events from the head of the pending ones, waiting for at least the
+// given number of them, and checkpoints the resume token of the last one. Resume tokens are thus only stored up to the
+// highest contiguous acknowledged change event, and the change events following a stored token are published again if
+// the change stream is reopened after it.
+// It returns whether the change stream should be reopened, which happens once a change event is not acknowledged.
+func (c *DefaultClient) commitPending(ctx context.Context, w *watcher, waitFor int) (bool, error) {
+	var last *pendingEvent
+	var acked int
+acks:
+	for i := 0; len(w.pending) > 0; i++ {
+		head := w.pending[0]
+		var err error
+		if i < waitFor {
+			select {
+			case <-ctx.Done():
+				return true, ctx.Err()
+			case err = <-head.ack:
+			}
+		} else {
+			select {
+			case err = <-head.ack:
+			default:
+				// the head is not acknowledged yet
+				break acks
+			}
+		}
+		if err != nil {
+			if last != nil {
+				if err := c.processed(ctx, w, last.event, acked); err != nil {
+					return true, err
+				}
+			}
+			return true, c.pendingFailed(w, err)
+		}
+		w.pending = w.pending[1:]
+		last, acked = head, acked+1
+		c.onChangeEventProcessing(head.event.CollName, head.event.Subj, time.Since(head.start))
+		c.observeClusterTime(w, head.raw)
+	}
+	if last != nil {
+		if err := c.processed(ctx, w, last.event, acked); err != nil {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// pendingFailed discards the pending change events once the one at their head is not acknowledged, so that the change
+// stream is reopened before it, after the resume token of the last acknowledged change event. The change event that
+// was not acknowledged is then published again synchronously, retried and dead-lettered according to the options,
+// before the following ones, which keeps change events in order. The following change events may have been stored by
+// the NATS server already, they are then discarded as duplicates.
+func (c *DefaultClient) pendingFailed(w *watcher, cause error) error {
+	head := w.pending[0]
+	c.logger.Warn("change event not acknowledged, reopening change stream before it", "subj", head.event.Subj,
+		"discarded", len(w.pending)-1, "err", cause)
+	w.pending, w.resync = nil, true
+	return fmt.Errorf("change event not acknowledged: %v", cause)
+}
+
+// publishesAsync returns whether the given change event should be published with the AsyncChangeEventHandler. The
+// change event that was not acknowledged is published synchronously instead once the change stream is reopened before
+// it, until it is processed, as well as invalidate events.
+func (w *watcher) publishesAsync(event *ChangeEvent) bool {
+	return w.opts.AsyncChangeEventHandler != nil && event.OperationType != invalidateOperationType && !w.resync
+}
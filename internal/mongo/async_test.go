@@ -0,0 +1,122 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultClient_commitPending(t *testing.T) {
+	newWatcher := func(tokenStore TokenStore, handler ChangeEventHandler) *watcher {
+		return &watcher{
+			opts:       &WatchCollectionOptions{ChangeEventHandler: handler},
+			tokenStore: tokenStore,
+			namespace:  "test-db.coll1",
+			backoff:    &backoff{},
+		}
+	}
+	pending := func(id string, err error, acked bool) *pendingEvent {
+		ack := make(chan error, 1)
+		if acked {
+			ack <- err
+		}
+		return &pendingEvent{event: &ChangeEvent{Id: id, CollName: "coll1", Subj: "COLL1.insert"}, ack: ack}
+	}
+
+	t.Run("should store the token of the highest contiguous acknowledged change event", func(t *testing.T) {
+		var processed int
+		c := &DefaultClient{logger: slog.Default(), onChangeEventProcessing: func(_, _ string, _ time.Duration) {
+			processed++
+		}}
+		tokenStore := &testTokenStore{}
+		w := newWatcher(tokenStore, nil)
+		w.pending = []*pendingEvent{pending("1", nil, true), pending("2", nil, true), pending("3", nil, false),
+			pending("4", nil, true)}
+
+		reopen, err := c.commitPending(context.Background(), w, 0)
+
+		require.False(t, reopen)
+		require.NoError(t, err)
+		require.Equal(t, "2", tokenStore.token)
+		require.Equal(t, 2, processed)
+		require.Len(t, w.pending, 2)
+	})
+	t.Run("should wait for the given number of change events to be acknowledged", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default(), onChangeEventProcessing: func(_, _ string, _ time.Duration) {}}
+		tokenStore := &testTokenStore{}
+		w := newWatcher(tokenStore, nil)
+		ack := make(chan error, 1)
+		w.pending = []*pendingEvent{{event: &ChangeEvent{Id: "1"}, ack: ack}}
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			ack <- nil
+		}()
+
+		reopen, err := c.commitPending(context.Background(), w, 1)
+
+		require.False(t, reopen)
+		require.NoError(t, err)
+		require.Equal(t, "1", tokenStore.token)
+		require.Empty(t, w.pending)
+	})
+	t.Run("should reopen the change stream before the change event not acknowledged", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default(), onChangeEventProcessing: func(_, _ string, _ time.Duration) {}}
+		tokenStore := &testTokenStore{}
+		w := newWatcher(tokenStore, nil)
+		w.pending = []*pendingEvent{pending("1", nil, true), pending("2", errors.New("timeout"), true),
+			pending("3", nil, true)}
+
+		reopen, err := c.commitPending(context.Background(), w, 0)
+
+		require.True(t, reopen)
+		require.ErrorContains(t, err, "timeout")
+		require.Equal(t, "1", tokenStore.token)
+		require.Empty(t, w.pending)
+		require.True(t, w.resync)
+	})
+	t.Run("should keep change events in order once a change event in the middle is not acknowledged", func(t *testing.T) {
+		var published []string
+		c := &DefaultClient{logger: slog.Default(), onChangeEventProcessing: func(_, _ string, _ time.Duration) {}}
+		tokenStore := &testTokenStore{}
+		w := newWatcher(tokenStore, func(_ context.Context, event *ChangeEvent) error {
+			published = append(published, event.Id+" (sync)")
+			return nil
+		})
+		w.opts.AsyncChangeEventHandler = func(_ context.Context, event *ChangeEvent) (<-chan error, error) {
+			published = append(published, event.Id)
+			ack := make(chan error, 1)
+			ack <- nil
+			return ack, nil
+		}
+		// change events are handled as by the change stream, which is reopened after the stored token
+		handle := func(id string) {
+			event := &ChangeEvent{Id: id, CollName: "coll1", Subj: "COLL1.insert", OperationType: "insert"}
+			if w.publishesAsync(event) {
+				w.publishAsync(context.Background(), event, nil, time.Now())
+				_, err := c.commitPending(context.Background(), w, 1)
+				require.NoError(t, err)
+				return
+			}
+			require.NoError(t, c.handleChangeEvent(context.Background(), w.opts, event))
+			require.NoError(t, c.processed(context.Background(), w, event, 1))
+			w.resync = false
+		}
+		w.pending = []*pendingEvent{pending("1", nil, true), pending("2", errors.New("timeout"), true),
+			pending("3", nil, true), pending("4", nil, true)}
+
+		reopen, err := c.commitPending(context.Background(), w, 0)
+		require.True(t, reopen)
+		require.Error(t, err)
+		for _, id := range []string{"2", "3", "4"} {
+			handle(id)
+		}
+
+		require.Equal(t, []string{"2 (sync)", "3", "4"}, published)
+		require.Equal(t, "4", tokenStore.token)
+		require.False(t, w.resync)
+	})
+}
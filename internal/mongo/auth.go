@@ -0,0 +1,92 @@
+package mongo
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/damianiandrea/mongodb-nats-connector/internal/secret"
+)
+
+// AuthOptions represents the credentials used to authenticate to MongoDB, which replace the ones in the uri.
+// Password can be read from PasswordFile instead, so that it is not part of the configuration.
+type AuthOptions struct {
+	Mechanism           string // e.g. 'SCRAM-SHA-256', 'MONGODB-X509', 'MONGODB-AWS' or 'MONGODB-OIDC'
+	MechanismProperties map[string]string
+	Source              string
+	Username            string
+	Password            string
+	PasswordFile        string
+}
+
+// TLSOptions represents the TLS configuration of the connection to MongoDB, which replaces the one in the uri.
+// CertKeyFile contains both the client certificate and its private key, as used by X.509 authentication.
+type TLSOptions struct {
+	CAFile              string
+	CertKeyFile         string
+	CertKeyPassword     string
+	CertKeyPasswordFile string
+}
+
+// ReadPreferenceOptions represents the members of the replica set the change streams are read from. Snapshots and
+// resume tokens are always read from the primary.
+type ReadPreferenceOptions struct {
+	Mode         string // 'primary', 'primaryPreferred', 'secondary', 'secondaryPreferred' or 'nearest'
+	MaxStaleness time.Duration
+}
+
+// applyClientOptions sets the configured credentials, TLS and read preference on the given client options, reading the
+// secrets from their files.
+func (c *DefaultClient) applyClientOptions(clientOpts *options.ClientOptions) error {
+	if auth := c.auth; auth != nil {
+		password, err := secret.Read(auth.Password, auth.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("could not read mongodb password file: %v", err)
+		}
+		clientOpts.SetAuth(options.Credential{
+			AuthMechanism:           auth.Mechanism,
+			AuthMechanismProperties: auth.MechanismProperties,
+			AuthSource:              auth.Source,
+			Username:                auth.Username,
+			Password:                password,
+			PasswordSet:             password != "",
+		})
+	}
+	if tlsOpts := c.tls; tlsOpts != nil {
+		cfg := map[string]any{}
+		if tlsOpts.CAFile != "" {
+			cfg["tlsCAFile"] = tlsOpts.CAFile
+		}
+		if tlsOpts.CertKeyFile != "" {
+			cfg["tlsCertificateKeyFile"] = tlsOpts.CertKeyFile
+			keyPassword, err := secret.Read(tlsOpts.CertKeyPassword, tlsOpts.CertKeyPasswordFile)
+			if err != nil {
+				return fmt.Errorf("could not read mongodb certificate key password file: %v", err)
+			}
+			cfg["tlsCertificateKeyFilePassword"] = keyPassword
+		}
+		tlsConfig, err := options.BuildTLSConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("invalid mongodb tls options: %v", err)
+		}
+		clientOpts.SetTLSConfig(tlsConfig)
+	}
+	if readPref := c.readPreference; readPref != nil {
+		mode, err := readpref.ModeFromString(readPref.Mode)
+		if err != nil {
+			return fmt.Errorf("invalid mongodb read preference: %v", err)
+		}
+		var readPrefOpts []readpref.Option
+		if readPref.MaxStaleness > 0 {
+			readPrefOpts = append(readPrefOpts, readpref.WithMaxStaleness(readPref.MaxStaleness))
+		}
+		rp, err := readpref.New(mode, readPrefOpts...)
+		if err != nil {
+			return fmt.Errorf("invalid mongodb read preference: %v", err)
+		}
+		clientOpts.SetReadPreference(rp)
+	}
+	return nil
+}
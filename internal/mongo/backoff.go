@@ -0,0 +1,53 @@
+package mongo
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff is the policy used to wait between consecutive failures of a change stream, such as the failure to open it,
+// to publish a change event or to store its resume token. Zero values disable the corresponding behavior: a zero
+// Multiplier keeps the backoff constant, a zero Jitter does not randomize it and zero MaxAttempts never give up.
+type Backoff struct {
+	Initial     time.Duration
+	Max         time.Duration
+	Multiplier  float64
+	Jitter      float64 // the fraction of the backoff that is randomized, between 0 and 1
+	MaxAttempts int
+}
+
+// Delay returns the time to wait after the given number of consecutive failures, starting from 1.
+func (p Backoff) Delay(attempt int) time.Duration {
+	delay := float64(p.Initial)
+	if p.Multiplier > 1 {
+		delay *= math.Pow(p.Multiplier, float64(attempt-1))
+	}
+	if p.Max > 0 {
+		delay = min(delay, float64(p.Max))
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// backoff tracks the consecutive failures of a change stream.
+type backoff struct {
+	policy   Backoff
+	attempts int
+}
+
+// next records a failure and returns the time to wait before the next attempt, or false if no attempts are left.
+func (b *backoff) next() (time.Duration, bool) {
+	b.attempts++
+	if b.policy.MaxAttempts > 0 && b.attempts > b.policy.MaxAttempts {
+		return 0, false
+	}
+	return b.policy.Delay(b.attempts), true
+}
+
+// reset records a success.
+func (b *backoff) reset() {
+	b.attempts = 0
+}
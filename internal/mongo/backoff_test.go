@@ -0,0 +1,46 @@
+package mongo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff_next(t *testing.T) {
+	t.Run("should grow exponentially up to the max backoff", func(t *testing.T) {
+		b := &backoff{policy: Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}}
+
+		var delays []time.Duration
+		for range 4 {
+			delay, ok := b.next()
+			require.True(t, ok)
+			delays = append(delays, delay)
+		}
+
+		require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, delays)
+	})
+	t.Run("should randomize the backoff within the jitter", func(t *testing.T) {
+		b := &backoff{policy: Backoff{Initial: time.Second, Jitter: 0.5}}
+
+		for range 100 {
+			delay, _ := b.next()
+			require.GreaterOrEqual(t, delay, 500*time.Millisecond)
+			require.LessOrEqual(t, delay, 1500*time.Millisecond)
+		}
+	})
+	t.Run("should give up after the max attempts and start over once reset", func(t *testing.T) {
+		b := &backoff{policy: Backoff{Initial: time.Second, MaxAttempts: 2}}
+
+		_, ok := b.next()
+		require.True(t, ok)
+		_, ok = b.next()
+		require.True(t, ok)
+		_, ok = b.next()
+		require.False(t, ok)
+
+		b.reset()
+		_, ok = b.next()
+		require.True(t, ok)
+	})
+}
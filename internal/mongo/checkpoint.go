@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"context"
+	"time"
+)
+
+// checkpointFlushTimeout bounds the time spent storing the last resume token once the change stream is closed, since
+// the context of the watcher may already be done.
+const checkpointFlushTimeout = 5 * time.Second
+
+// checkpoint holds the resume token of the last processed change event until it is stored, according to the
+// CheckpointEvery and CheckpointInterval options.
+type checkpoint struct {
+	token  string    // the resume token not stored yet, if any
+	events int       // the number of change events processed since the last stored resume token
+	since  time.Time // when the first of them was processed
+}
+
+// due returns whether the resume token should be stored now. Resume tokens are stored after every change event unless
+// CheckpointEvery or CheckpointInterval is set.
+func (cp *checkpoint) due(opts *WatchCollectionOptions, now time.Time) bool {
+	if opts.CheckpointEvery <= 0 && opts.CheckpointInterval <= 0 {
+		return true
+	}
+	if opts.CheckpointEvery > 0 && cp.events >= opts.CheckpointEvery {
+		return true
+	}
+	return opts.CheckpointInterval > 0 && now.Sub(cp.since) >= opts.CheckpointInterval
+}
+
+// checkpoint records the resume token of the given number of processed change events, the last of them having the
+// given token, and stores it if it is due.
+func (c *DefaultClient) checkpoint(ctx context.Context, w *watcher, token string, events int) error {
+	now := time.Now()
+	if w.checkpoint.events == 0 {
+		w.checkpoint.since = now
+	}
+	w.checkpoint.token = token
+	w.checkpoint.events += events
+	if !w.checkpoint.due(w.opts, now) {
+		return nil
+	}
+	return c.flushCheckpoint(ctx, w)
+}
+
+// flushCheckpoint stores the resume token not stored yet, if any, and resets the state that only applies until a
+// resume token is stored.
+func (c *DefaultClient) flushCheckpoint(ctx context.Context, w *watcher) error {
+	if w.checkpoint.token == "" {
+		return nil
+	}
+	if err := w.tokenStore.StoreToken(ctx, w.checkpoint.token); err != nil {
+		// change events have been published but token insertion failed.
+		// connector will resume after the previous token, publishing them again.
+		// consumers should be able to detect and discard the duplicate change events by using the msg id.
+		c.logger.Error("could not store resume token", "err", err)
+		return err
+	}
+	// the change stream is reopened after the stored resume token from now on
+	w.checkpoint = checkpoint{}
+	w.snapshot, w.startAt, w.startAfter = nil, nil, ""
+	return nil
+}
+
+// closeCheckpoint stores the resume token not stored yet once the change stream is closed or a snapshot scan is
+// interrupted, including on graceful shutdown, so that the change events processed since the last checkpoint are not
+// published again. The change events still awaiting their acknowledgement are waited for first.
+func (c *DefaultClient) closeCheckpoint(ctx context.Context, w *watcher) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), checkpointFlushTimeout)
+	defer cancel()
+	if len(w.pending) > 0 {
+		_, _ = c.commitPending(ctx, w, len(w.pending))
+	}
+	_ = c.flushCheckpoint(ctx, w)
+}
@@ -0,0 +1,98 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCheckpoint_due(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		opts       *WatchCollectionOptions
+		checkpoint checkpoint
+		want       bool
+	}{
+		{
+			name:       "should be due after every change event by default",
+			opts:       &WatchCollectionOptions{},
+			checkpoint: checkpoint{events: 1, since: now},
+			want:       true,
+		},
+		{
+			name:       "should not be due before the given number of change events",
+			opts:       &WatchCollectionOptions{CheckpointEvery: 3},
+			checkpoint: checkpoint{events: 2, since: now},
+		},
+		{
+			name:       "should be due after the given number of change events",
+			opts:       &WatchCollectionOptions{CheckpointEvery: 3},
+			checkpoint: checkpoint{events: 3, since: now},
+			want:       true,
+		},
+		{
+			name:       "should not be due before the given interval",
+			opts:       &WatchCollectionOptions{CheckpointInterval: time.Minute},
+			checkpoint: checkpoint{events: 100, since: now.Add(-30 * time.Second)},
+		},
+		{
+			name:       "should be due after the given interval",
+			opts:       &WatchCollectionOptions{CheckpointEvery: 100, CheckpointInterval: time.Minute},
+			checkpoint: checkpoint{events: 1, since: now.Add(-time.Minute)},
+			want:       true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.checkpoint.due(tt.opts, now))
+		})
+	}
+}
+
+func TestDefaultClient_checkpoint(t *testing.T) {
+	t.Run("should store the resume token every given number of change events", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default()}
+		tokenStore := &testTokenStore{}
+		w := &watcher{opts: &WatchCollectionOptions{CheckpointEvery: 3}, tokenStore: tokenStore,
+			startAt: &primitive.Timestamp{T: 1683637178}}
+
+		require.NoError(t, c.checkpoint(context.Background(), w, "1", 1))
+		require.NoError(t, c.checkpoint(context.Background(), w, "2", 1))
+
+		require.Empty(t, tokenStore.token)
+		require.NotNil(t, w.startAt)
+
+		require.NoError(t, c.checkpoint(context.Background(), w, "3", 1))
+
+		require.Equal(t, "3", tokenStore.token)
+		require.Nil(t, w.startAt)
+		require.Equal(t, checkpoint{}, w.checkpoint)
+	})
+	t.Run("should count the change events committed together", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default()}
+		tokenStore := &testTokenStore{}
+		w := &watcher{opts: &WatchCollectionOptions{CheckpointEvery: 3}, tokenStore: tokenStore}
+
+		require.NoError(t, c.checkpoint(context.Background(), w, "4", 4))
+
+		require.Equal(t, "4", tokenStore.token)
+	})
+	t.Run("should store the resume token not stored yet once the change stream is closed", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default()}
+		tokenStore := &testTokenStore{}
+		w := &watcher{opts: &WatchCollectionOptions{CheckpointInterval: time.Minute}, tokenStore: tokenStore}
+		require.NoError(t, c.checkpoint(context.Background(), w, "1", 1))
+		require.Empty(t, tokenStore.token)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c.closeCheckpoint(ctx, w)
+
+		require.Equal(t, "1", tokenStore.token)
+	})
+}
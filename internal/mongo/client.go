@@ -1,15 +1,19 @@
 package mongo
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -30,6 +34,21 @@ const (
 	invalidateOperationType = "invalidate"
 )
 
+const (
+	// FullDocumentDefault omits the full document of update events.
+	FullDocumentDefault = string(options.Default)
+	// FullDocumentUpdateLookup looks up the current version of the document for update events.
+	FullDocumentUpdateLookup = string(options.UpdateLookup)
+	// FullDocumentWhenAvailable includes the post-image of the document if available, requires MongoDB 6.0.
+	FullDocumentWhenAvailable = string(options.WhenAvailable)
+	// FullDocumentRequired includes the post-image or pre-image of the document, and fails if it is not available.
+	// It requires MongoDB 6.0, and changeStreamPreAndPostImages enabled on the collection.
+	FullDocumentRequired = string(options.Required)
+	// FullDocumentOff omits the pre-image of the document.
+	FullDocumentOff = string(options.Off)
+)
+
+// publishableOperationTypes contains the operation types of the change events published by default.
 var publishableOperationTypes = map[string]struct{}{
 	insertOperationType: {},
 	updateOperationType: {},
@@ -43,6 +62,11 @@ type Client interface {
 
 	CreateCollection(ctx context.Context, opts *CreateCollectionOptions) error
 	WatchCollection(ctx context.Context, opts *WatchCollectionOptions) error
+	InsertDeadLetter(ctx context.Context, opts *InsertDeadLetterOptions) error
+	SnapshotCollection(ctx context.Context, opts *SnapshotCollectionOptions) error
+	ServerVersion(ctx context.Context) (string, error)
+	Topology(ctx context.Context) (*Topology, error)
+	ListCollectionNames(ctx context.Context, dbName string) ([]string, error)
 }
 
 type CreateCollectionOptions struct {
@@ -53,31 +77,152 @@ type CreateCollectionOptions struct {
 	ChangeStreamPreAndPostImages bool
 }
 
-type ChangeEventHandler func(ctx context.Context, subj, msgId string, data []byte) error
+// ChangeEvent represents a change event to be published, along with the metadata extracted from it.
+type ChangeEvent struct {
+	Subj          string
+	Id            string // the resume token of the change event
+	OperationType string
+	DbName        string
+	CollName      string
+	DocumentKey   string    // the document key, as extended json
+	ClusterTime   string    // the cluster time, as '<seconds>.<increment>'
+	WallTime      time.Time // zero if not available, requires MongoDB 6.0
+	Data          []byte    // the whole change event, as extended json
+}
 
+type ChangeEventHandler func(ctx context.Context, event *ChangeEvent) error
+
+// DeadLetterHandler is called with a change event that could not be handled, along with the last error, once all
+// retries are exhausted.
+type DeadLetterHandler func(ctx context.Context, event *ChangeEvent, cause error) error
+
+// WatchCollectionOptions describes what to watch and where to publish its change events.
+// If WatchedCollName is empty the whole WatchedDbName database is watched, and if WatchedDbName is empty as well the
+// whole deployment is watched. In both cases the namespace of each change event is appended to the subject, unless a
+// SubjectTemplate is given. A change event whose subject cannot be rendered from the SubjectTemplate is passed to the
+// DeadLetterHandler right away if one is given, or published on the default subject otherwise.
+// Resume tokens are stored in the ResumeTokens* collection, unless a TokenStore is given. The resume token of the last
+// processed change event is stored after every change event, or once CheckpointEvery change events have been
+// processed or CheckpointInterval has elapsed if any of them is set, and anyway once the change stream is idle or
+// closed. If the connector stops without closing the change stream, the change events processed since the last stored
+// resume token are published again.
+// If a DeadLetterHandler is given, a change event that cannot be handled is retried up to MaxRetries times, waiting
+// an exponential backoff between RetryBackoff and MaxRetryBackoff, and it is then passed to the DeadLetterHandler and
+// its resume token is stored. Otherwise the change stream is reopened after the previous resume token, waiting
+// according to the Backoff policy.
+// If an AsyncChangeEventHandler is given, change events are published with it without waiting for their
+// acknowledgement, up to MaxPending at a time, and resume tokens are only stored up to the highest contiguous
+// acknowledged change event. Once a change event is not acknowledged, the change stream is reopened before it, and it
+// is handled with the ChangeEventHandler as above before the following change events, which are published again.
+// Invalidate events are always handled with the ChangeEventHandler.
+// Only the change events whose operation type is listed in OperationTypes are published, or the insert, update,
+// replace and delete ones if it is empty. Change events carry the full document according to FullDocument, and the
+// document before the change according to FullDocumentBeforeChange, which default to FullDocumentUpdateLookup and
+// FullDocumentWhenAvailable. BatchSize, MaxAwaitTime, Collation and Comment tune the change stream, zero values fall
+// back to the server defaults. DDL operation types such as create or createIndexes are only reported by
+// MongoDB if ShowExpandedEvents is set.
+// Once the change stream is invalidated, e.g. because the watched collection was dropped or renamed, watching stops
+// or goes on according to OnInvalidate, which defaults to InvalidateStop. If the change stream cannot be resumed
+// because its resume token is no longer in the oplog, watching fails or goes on according to OnHistoryLost, which
+// defaults to HistoryLostFail.
+// If Snapshot is SnapshotInitial or SnapshotAlways, the existing documents of the watched collection are published as
+// snapshot events before the change stream starts, at the operation time captured before scanning them.
+// Otherwise, if no resume token is stored, the change stream starts at StartAt, or at the current operation time if it
+// is nil. If ResetTokens is set, the stored resume tokens are ignored until a new one is stored, as if none was stored.
 type WatchCollectionOptions struct {
-	WatchedDbName          string
-	WatchedCollName        string
-	ResumeTokensDbName     string
-	ResumeTokensCollName   string
-	ResumeTokensCollCapped bool
-	StreamName             string
-	ChangeEventHandler     ChangeEventHandler
+	WatchedDbName            string
+	WatchedCollName          string
+	ResumeTokensDbName       string
+	ResumeTokensCollName     string
+	ResumeTokensCollCapped   bool
+	TokenStore               TokenStore
+	CheckpointEvery          int
+	CheckpointInterval       time.Duration
+	StreamName               string
+	Pipeline                 []bson.D
+	SubjectTemplate          *SubjectTemplate
+	OperationTypes           []string
+	ShowExpandedEvents       bool
+	FullDocument             string
+	FullDocumentBeforeChange string
+	BatchSize                int32
+	MaxAwaitTime             time.Duration
+	Collation                *options.Collation
+	Comment                  string
+	OnInvalidate             string
+	OnHistoryLost            string
+	ChangeEventHandler       ChangeEventHandler
+	AsyncChangeEventHandler  AsyncChangeEventHandler
+	MaxPending               int
+	MaxRetries               int
+	RetryBackoff             time.Duration
+	MaxRetryBackoff          time.Duration
+	DeadLetterHandler        DeadLetterHandler
+	Backoff                  Backoff
+	Snapshot                 string
+	StartAt                  *StartAt
+	ResetTokens              bool
 }
 
-var _ Client = &DefaultClient{}
+// SnapshotCollectionOptions describes an on-demand snapshot of the DbName.CollName collection, which belongs to the
+// namespace watched according to Watch: its snapshot events are published like the change events of the namespace,
+// and dead-lettered the same way if they cannot be published.
+// Only the documents matching the Filter are published, and at most RateLimit per second if RateLimit is greater than
+// zero. Unlike the snapshot started by WatchCollection, the progress is not stored: an interrupted snapshot must be
+// started again. The id of each snapshot event is made of the Id of the snapshot and of the _id of the document.
+// OnProgress, if given, is called with the number of published documents after each of them.
+type SnapshotCollectionOptions struct {
+	Id         string
+	DbName     string
+	CollName   string
+	Filter     bson.D
+	RateLimit  int
+	Watch      *WatchCollectionOptions
+	OnProgress func(published int64)
+}
 
-type DefaultClient struct {
-	uri    string
-	name   string
-	logger *slog.Logger
+var (
+	_ Client                 = &DefaultClient{}
+	_ server.DetailedMonitor = &DefaultClient{}
+)
 
-	onChangeEventProcessing func(collName, subj string, duration time.Duration)
-	onCmdStartedEvent       func(dbName, cmdName string)
-	onCmdSucceededEvent     func(dbName, cmdName string, duration time.Duration)
-	onCmdFailedEvent        func(dbName, cmdName string, duration time.Duration)
+type DefaultClient struct {
+	uri            string
+	name           string
+	logger         *slog.Logger
+	auth           *AuthOptions
+	tls            *TLSOptions
+	readPreference *ReadPreferenceOptions
+
+	onChangeEventProcessing   func(collName, subj string, duration time.Duration)
+	onChangeEventRetry        func(collName, subj string)
+	onChangeEventDeadLetter   func(collName, subj string)
+	onChangeStreamBackoff     func(namespace string, attempts int, backoff time.Duration)
+	onChangeStreamInvalidate  func(namespace, action string)
+	onChangeStreamHistoryLost func(namespace, action string)
+	onChangeStreamClusterTime func(namespace string, clusterTime time.Time)
+	onChunkMigration          func(namespace, fromShard, toShard string)
+	onShardChangeEvent        func(namespace, shard string)
+	onCmdStartedEvent         func(dbName, cmdName string)
+	onCmdSucceededEvent       func(dbName, cmdName string, duration time.Duration)
+	onCmdFailedEvent          func(dbName, cmdName string, duration time.Duration)
 
 	client *mongo.Client
+
+	mu                sync.Mutex
+	backoffStates     map[string]*BackoffState
+	historyLostStates map[string]*HistoryLostState
+	topology          *Topology
+
+	hashedShardKeysOnce  sync.Once
+	hashedShardKeysMatch bool
+}
+
+// BackoffState represents a change stream waiting to be reopened after consecutive failures.
+type BackoffState struct {
+	Attempts  int    `json:"attempts"`
+	Backoff   string `json:"backoff"`
+	LastError string `json:"lastError,omitempty"`
 }
 
 func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
@@ -114,6 +259,9 @@ func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
 	}
 
 	clientOpts := options.Client().ApplyURI(c.uri).SetMonitor(cmdMonitor)
+	if err = c.applyClientOptions(clientOpts); err != nil {
+		return nil, err
+	}
 
 	client, err := mongo.Connect(context.Background(), clientOpts)
 	if err != nil {
@@ -136,6 +284,48 @@ func (c *DefaultClient) Monitor(ctx context.Context) error {
 	return nil
 }
 
+// Details returns the detected topology, and the state of the change streams that are backing off, and of those whose
+// history was lost, by namespace.
+func (c *DefaultClient) Details() any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.backoffStates) == 0 && len(c.historyLostStates) == 0 && c.topology == nil {
+		return nil
+	}
+	details := make(map[string]any, 3)
+	if c.topology != nil {
+		details["topology"] = *c.topology
+	}
+	if len(c.backoffStates) > 0 {
+		backoff := make(map[string]BackoffState, len(c.backoffStates))
+		for ns, state := range c.backoffStates {
+			backoff[ns] = *state
+		}
+		details["backoff"] = backoff
+	}
+	if len(c.historyLostStates) > 0 {
+		historyLost := make(map[string]HistoryLostState, len(c.historyLostStates))
+		for ns, state := range c.historyLostStates {
+			historyLost[ns] = *state
+		}
+		details["historyLost"] = historyLost
+	}
+	return details
+}
+
+func (c *DefaultClient) setBackoffState(namespace string, state *BackoffState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state == nil {
+		delete(c.backoffStates, namespace)
+		return
+	}
+	if c.backoffStates == nil {
+		c.backoffStates = make(map[string]*BackoffState)
+	}
+	c.backoffStates[namespace] = state
+}
+
 func (c *DefaultClient) Close() error {
 	if err := c.client.Disconnect(context.Background()); err != nil {
 		return fmt.Errorf("could not close mongodb client: %v", err)
@@ -143,6 +333,33 @@ func (c *DefaultClient) Close() error {
 	return nil
 }
 
+// ServerVersion returns the version of the MongoDB server, e.g. '6.0.5'.
+func (c *DefaultClient) ServerVersion(ctx context.Context) (string, error) {
+	reply, err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "buildInfo", Value: 1}}).Raw()
+	if err != nil {
+		return "", fmt.Errorf("could not fetch mongodb server version: %v", err)
+	}
+	version, ok := reply.Lookup("version").StringValueOK()
+	if !ok {
+		return "", errors.New("could not fetch mongodb server version: missing from reply")
+	}
+	return version, nil
+}
+
+// ListCollectionNames returns the names of the collections of the given database, excluding views and system
+// collections.
+func (c *DefaultClient) ListCollectionNames(ctx context.Context, dbName string) ([]string, error) {
+	filter := bson.D{
+		{Key: "type", Value: "collection"},
+		{Key: "name", Value: bson.D{{Key: "$not", Value: primitive.Regex{Pattern: `^system\.`}}}},
+	}
+	collNames, err := c.client.Database(dbName).ListCollectionNames(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("could not list mongo collection names: %v", err)
+	}
+	return collNames, nil
+}
+
 func (c *DefaultClient) CreateCollection(ctx context.Context, opts *CreateCollectionOptions) error {
 	db := c.client.Database(opts.DbName)
 	collNames, err := db.ListCollectionNames(ctx, bson.D{{Key: "name", Value: opts.CollName}})
@@ -174,99 +391,453 @@ func (c *DefaultClient) CreateCollection(ctx context.Context, opts *CreateCollec
 	return nil
 }
 
+// watchable is implemented by mongo.Client, mongo.Database and mongo.Collection.
+type watchable interface {
+	Watch(ctx context.Context, pipeline any, opts ...*options.ChangeStreamOptions) (*mongo.ChangeStream, error)
+}
+
 func (c *DefaultClient) WatchCollection(ctx context.Context, opts *WatchCollectionOptions) error {
 
-	resumeTokensDb := c.client.Database(opts.ResumeTokensDbName)
-	resumeTokensColl := resumeTokensDb.Collection(opts.ResumeTokensCollName)
+	tokenStore := opts.TokenStore
+	if tokenStore == nil {
+		tokenStore = &collTokenStore{
+			// the last resume token must not be read from a lagging secondary
+			coll:   c.primaryCollection(opts.ResumeTokensDbName, opts.ResumeTokensCollName),
+			id:     namespace(opts.WatchedDbName, opts.WatchedCollName),
+			capped: opts.ResumeTokensCollCapped,
+		}
+	}
 
-	watchedDb := c.client.Database(opts.WatchedDbName)
-	watchedColl := watchedDb.Collection(opts.WatchedCollName)
+	var watched watchable
+	pipeline := mongo.Pipeline(opts.Pipeline)
+	switch {
+	case opts.WatchedDbName == "":
+		watched = c.client
+	case opts.WatchedCollName == "":
+		watched = c.client.Database(opts.WatchedDbName)
+	default:
+		watched = c.client.Database(opts.WatchedDbName).Collection(opts.WatchedCollName)
+	}
+	if opts.WatchedCollName == "" && opts.TokenStore == nil {
+		// the resume tokens collection may be part of the watched namespace, its change events must be skipped
+		// to avoid publishing an event for every stored token.
+		excludeResumeTokens := bson.D{{Key: "$match", Value: bson.D{{Key: "$nor", Value: bson.A{bson.D{
+			{Key: "ns.db", Value: opts.ResumeTokensDbName},
+			{Key: "ns.coll", Value: opts.ResumeTokensCollName},
+		}}}}}}
+		pipeline = append(mongo.Pipeline{excludeResumeTokens}, pipeline...)
+	}
 
-	resume := true
-	for resume {
-		findOneOpts := options.FindOne()
-		if opts.ResumeTokensCollCapped {
-			// use natural sort for capped collections to get the last inserted resume token
-			findOneOpts.SetSort(bson.D{{Key: "$natural", Value: -1}})
-		} else {
-			// cannot rely on natural sort for uncapped collections, sort by id instead
-			findOneOpts.SetSort(bson.D{{Key: "_id", Value: -1}})
+	operationTypes := publishableOperationTypes
+	if len(opts.OperationTypes) > 0 {
+		operationTypes = make(map[string]struct{}, len(opts.OperationTypes))
+		for _, operationType := range opts.OperationTypes {
+			operationTypes[operationType] = struct{}{}
 		}
+	}
 
-		lastResumeToken := &resumeToken{}
-		err := resumeTokensColl.FindOne(ctx, bson.D{}, findOneOpts).Decode(lastResumeToken)
-		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-			return fmt.Errorf("could not fetch or decode resume token: %v", err)
-		}
+	w := &watcher{
+		opts:           opts,
+		watched:        watched,
+		pipeline:       pipeline,
+		tokenStore:     tokenStore,
+		operationTypes: operationTypes,
+		namespace:      namespace(opts.WatchedDbName, opts.WatchedCollName),
+		backoff:        &backoff{policy: opts.Backoff},
+	}
+	logAttrs := []any{"dbName", opts.WatchedDbName, "collName", opts.WatchedCollName}
+	defer c.setBackoffState(w.namespace, nil)
+
+	if err := c.applyRename(ctx, w); err != nil {
+		return err
+	}
+	if err := c.startSnapshot(ctx, w); err != nil {
+		return err
+	}
+	if err := c.applyStartAt(ctx, w); err != nil {
+		return err
+	}
 
-		changeStreamOpts := options.ChangeStream().
-			SetFullDocument(options.UpdateLookup).
-			SetFullDocumentBeforeChange(options.WhenAvailable)
+	for {
+		retry, err := c.watchChangeStream(ctx, w)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrHistoryLost) {
+			// the change stream would keep failing after the same resume token
+			retry, err = c.historyLost(ctx, w, err)
+		}
+		if !retry {
+			return err
+		}
+		if err == nil {
+			// the change stream was invalidated or its history was lost, and it is reopened right away according to the
+			// OnInvalidate or OnHistoryLost policy
+			continue
+		}
 
-		if lastResumeToken.Value != "" {
-			c.logger.Debug("resuming after token", "token", lastResumeToken.Value)
-			changeStreamOpts.SetResumeAfter(bson.D{{Key: "_data", Value: lastResumeToken.Value}})
+		// the change stream is reopened after the previous resume token, waiting longer after each consecutive
+		// failure to avoid hot-looping against mongodb and nats during an outage.
+		delay, ok := w.backoff.next()
+		if !ok {
+			return fmt.Errorf("could not watch mongo namespace %v after %d attempts: %v",
+				w.namespace, w.backoff.policy.MaxAttempts, err)
+		}
+		c.logger.Warn("reopening change stream", append(logAttrs,
+			"attempt", w.backoff.attempts, "backoff", delay, "err", err)...)
+		c.setBackoffState(w.namespace, &BackoffState{Attempts: w.backoff.attempts, Backoff: delay.String(),
+			LastError: err.Error()})
+		if c.onChangeStreamBackoff != nil {
+			c.onChangeStreamBackoff(w.namespace, w.backoff.attempts, delay)
 		}
 
-		cs, err := watchedColl.Watch(ctx, mongo.Pipeline{}, changeStreamOpts)
-		if err != nil {
-			return fmt.Errorf("could not watch mongo collection %v: %v", watchedColl.Name(), err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
 		}
-		c.logger.Info("watching mongodb collection", "collName", watchedColl.Name())
+	}
+}
 
-		for cs.Next(ctx) {
-			start := time.Now()
-			currentResumeToken := cs.Current.Lookup("_id", "_data").StringValue()
-			operationType := cs.Current.Lookup("operationType").StringValue()
+// watcher holds the state of a change stream across reopenings.
+type watcher struct {
+	opts           *WatchCollectionOptions
+	watched        watchable
+	pipeline       mongo.Pipeline
+	tokenStore     TokenStore
+	operationTypes map[string]struct{} // the operation types of the change events to publish
+	namespace      string
+	backoff        *backoff
+	snapshot       *snapshotPosition        // the snapshot started by this watcher, until the first change event is processed
+	renamedTo      *renamedNamespace        // the namespace the watched collection was renamed to, if a rename was seen
+	followed       *renamedNamespace        // the namespace watched since a rename was followed, see collection
+	recovering     bool                     // whether the history was lost, until a change event is processed
+	startAt        *primitive.Timestamp     // the operation time to start at, until a change event is processed
+	startAfter     string                   // the resume token to start after, until a change event is processed
+	pending        []*pendingEvent          // the change events published asynchronously, in change stream order
+	checkpoint     checkpoint               // the resume token of the processed change events, until it is stored
+	resync         bool                     // whether the next change event is published synchronously, see pendingFailed
+	routingTables  map[string]*routingTable // the routing tables of the watched collections, by namespace
+}
+
+// watchChangeStream opens the change stream after the last stored resume token and handles its change events, until
+// an error occurs or the change stream is invalidated. It returns whether the change stream should be reopened.
+func (c *DefaultClient) watchChangeStream(ctx context.Context, w *watcher) (bool, error) {
+	opts := w.opts
+	logAttrs := []any{"dbName", opts.WatchedDbName, "collName", opts.WatchedCollName}
+
+	lastResumeToken, err := w.tokenStore.LastToken(ctx)
+	if err != nil {
+		return true, err
+	}
 
-			json, err := bson.MarshalExtJSON(cs.Current, false, false)
-			if err != nil {
-				return fmt.Errorf("could not marshal mongo change event from bson: %v", err)
+	changeStreamOpts := options.ChangeStream()
+	if fullDocument := cmp.Or(opts.FullDocument, FullDocumentUpdateLookup); fullDocument != FullDocumentDefault {
+		changeStreamOpts.SetFullDocument(options.FullDocument(fullDocument))
+	}
+	if fullDocument := cmp.Or(opts.FullDocumentBeforeChange, FullDocumentWhenAvailable); fullDocument != FullDocumentOff {
+		changeStreamOpts.SetFullDocumentBeforeChange(options.FullDocument(fullDocument))
+	}
+	if opts.ShowExpandedEvents {
+		changeStreamOpts.SetShowExpandedEvents(true)
+	}
+	if opts.BatchSize > 0 {
+		changeStreamOpts.SetBatchSize(opts.BatchSize)
+	}
+	if opts.MaxAwaitTime > 0 {
+		changeStreamOpts.SetMaxAwaitTime(opts.MaxAwaitTime)
+	}
+	if opts.Collation != nil {
+		changeStreamOpts.SetCollation(*opts.Collation)
+	}
+	if opts.Comment != "" {
+		changeStreamOpts.SetComment(opts.Comment)
+	}
+
+	snapshot, ok := parseSnapshotPosition(lastResumeToken)
+	if !ok && w.snapshot != nil {
+		// the token store did not keep the position of the snapshot, e.g. the collection is empty
+		snapshot, ok = w.snapshot, true
+	}
+	switch {
+	case w.startAt != nil:
+		c.logger.Debug("starting at operation time", "operationTime", *w.startAt)
+		changeStreamOpts.SetStartAtOperationTime(w.startAt)
+	case w.startAfter != "":
+		c.logger.Debug("starting after token", "token", w.startAfter)
+		changeStreamOpts.SetStartAfter(bson.D{{Key: "_data", Value: w.startAfter}})
+	case ok:
+		if w.snapshot == nil {
+			// the snapshot was interrupted, it is only resumed if the change stream can still start where it was taken
+			if err = c.checkSnapshotHistory(ctx, w, snapshot); err != nil {
+				return true, err
 			}
+		}
+		if err = c.scanSnapshot(ctx, w, snapshot); err != nil {
+			return true, err
+		}
+		c.logger.Debug("starting at operation time", "operationTime", snapshot.opTime)
+		changeStreamOpts.SetStartAtOperationTime(&snapshot.opTime)
+	case lastResumeToken != "":
+		// unlike resumeAfter, startAfter accepts the token of an invalidate event
+		c.logger.Debug("resuming after token", "token", lastResumeToken)
+		changeStreamOpts.SetStartAfter(bson.D{{Key: "_data", Value: lastResumeToken}})
+	}
 
-			if c.logger.Enabled(ctx, slog.LevelDebug) {
-				c.logger.Debug("received change event", "changeEvent", string(json))
+	cs, err := w.watched.Watch(ctx, w.pipeline, changeStreamOpts)
+	if err != nil {
+		if isHistoryLost(err) {
+			return true, fmt.Errorf("%w: could not watch mongo namespace %v: %v", ErrHistoryLost, w.namespace, err)
+		}
+		return true, fmt.Errorf("could not watch mongo namespace %v: %v", w.namespace, err)
+	}
+	c.logger.Info("watching mongodb namespace", logAttrs...)
+	defer func() {
+		c.logger.Info("stopped watching mongodb namespace", logAttrs...)
+		c.closeCheckpoint(ctx, w)
+		if err := cs.Close(context.Background()); err != nil {
+			c.logger.Error("could not close change stream", "err", err)
+		}
+	}()
+
+	w.pending = nil // the acknowledgements of a previous change stream are discarded along with it
+	for {
+		if len(w.pending) == 0 && w.checkpoint.token == "" {
+			if !cs.Next(ctx) {
+				break
+			}
+		} else if !cs.TryNext(ctx) {
+			if cs.Err() != nil || ctx.Err() != nil {
+				break
+			}
+			// no change event is available right now, commit the pending ones and store the last resume token instead
+			// of waiting for more
+			if reopen, err := c.commitPending(ctx, w, len(w.pending)); reopen {
+				return true, err
+			}
+			if err = c.flushCheckpoint(ctx, w); err != nil {
+				return true, err
 			}
+			continue
+		}
+		start := time.Now()
+
+		json, err := bson.MarshalExtJSON(cs.Current, false, false)
+		if err != nil {
+			return false, fmt.Errorf("could not marshal mongo change event from bson: %v", err)
+		}
+
+		if c.logger.Enabled(ctx, slog.LevelDebug) {
+			c.logger.Debug("received change event", "changeEvent", string(json))
+		}
 
-			if _, ok := publishableOperationTypes[operationType]; !ok {
-				if operationType == invalidateOperationType {
-					resume = false
-					break
+		event := newChangeEvent(cs.Current, json)
+		if event.OperationType == renameOperationType {
+			w.renamedTo = newRenamedNamespace(cs.Current)
+		}
+		c.observeShardingEvent(w, event, cs.Current)
+		c.observeShard(ctx, w, event, cs.Current)
+		invalidated := event.OperationType == invalidateOperationType
+		if _, ok := w.operationTypes[event.OperationType]; !ok {
+			if invalidated {
+				if reopen, err := c.commitPending(ctx, w, len(w.pending)); reopen {
+					return true, err
 				}
-				continue
+				return c.invalidated(ctx, w, event)
 			}
+			continue
+		}
 
-			subj := fmt.Sprintf("%s.%s", opts.StreamName, operationType)
-			if err = opts.ChangeEventHandler(ctx, subj, currentResumeToken, json); err != nil {
-				// current change event was not published.
-				// current resume token will not be stored.
-				// connector will resume after the previous token.
-				c.logger.Error("could not publish change event", "err", err)
-				break
+		var subjErr error
+		event.Subj, subjErr = eventSubject(opts, event, cs.Current)
+		if subjErr == nil && w.publishesAsync(event) {
+			w.publishAsync(ctx, event, cs.Current, start)
+			// wait for the oldest change event once the window is full, commit the acknowledged ones otherwise
+			waitFor := 0
+			if len(w.pending) >= max(opts.MaxPending, 1) {
+				waitFor = 1
 			}
+			if reopen, err := c.commitPending(ctx, w, waitFor); reopen {
+				return true, err
+			}
+			continue
+		}
+		if reopen, err := c.commitPending(ctx, w, len(w.pending)); reopen {
+			return true, err
+		}
 
-			if _, err = resumeTokensColl.InsertOne(ctx, &resumeToken{Value: currentResumeToken}); err != nil {
-				// change event has been published but token insertion failed.
-				// connector will resume after the previous token, publishing a duplicate change event.
-				// consumers should be able to detect and discard the duplicate change event by using the msg id.
-				c.logger.Error("could not insert resume token", "err", err)
-				break
+		if err = c.publishChangeEvent(ctx, opts, event, subjErr); err != nil {
+			// current change event was neither published nor dead-lettered.
+			// current resume token will not be stored.
+			// connector will resume after the previous token.
+			c.logger.Error("could not publish change event", "err", err)
+			return true, err
+		}
+
+		if invalidated {
+			c.onChangeEventProcessing(event.CollName, event.Subj, time.Since(start))
+			return c.invalidated(ctx, w, event)
+		}
+
+		if err = c.processed(ctx, w, event, 1); err != nil {
+			return true, err
+		}
+		w.resync = false
+		c.onChangeEventProcessing(event.CollName, event.Subj, time.Since(start))
+		c.observeClusterTime(w, cs.Current)
+	}
+
+	if err = cs.Err(); err != nil {
+		if ctx.Err() == nil {
+			// the acknowledged change events are committed, so that they are not published again
+			if reopen, commitErr := c.commitPending(ctx, w, len(w.pending)); reopen {
+				return true, commitErr
 			}
+		}
+		if isHistoryLost(err) {
+			return true, fmt.Errorf("%w: change stream failed: %v", ErrHistoryLost, err)
+		}
+		return true, fmt.Errorf("change stream failed: %v", err)
+	}
+	return true, errors.New("change stream closed")
+}
+
+// processed checkpoints the resume token of the given change event once it is published or dead-lettered, the last of
+// the given number of processed change events, and resets the backoff.
+func (c *DefaultClient) processed(ctx context.Context, w *watcher, event *ChangeEvent, events int) error {
+	if err := c.checkpoint(ctx, w, event.Id, events); err != nil {
+		return err
+	}
 
-			c.onChangeEventProcessing(watchedColl.Name(), subj, time.Since(start))
+	if w.recovering {
+		// the change stream recovered from its lost history
+		w.recovering = false
+		c.setHistoryLostState(w.namespace, nil)
+	}
+	if w.backoff.attempts > 0 {
+		w.backoff.reset()
+		c.setBackoffState(w.namespace, nil)
+		if c.onChangeStreamBackoff != nil {
+			c.onChangeStreamBackoff(w.namespace, 0, 0)
 		}
+	}
+	return nil
+}
+
+// publishChangeEvent handles the change event, unless the subject template could not be rendered for it: it is then
+// dead-lettered right away, as retrying would not help, or handled on its default subject if dead letters are not
+// enabled.
+func (c *DefaultClient) publishChangeEvent(ctx context.Context, opts *WatchCollectionOptions, event *ChangeEvent,
+	subjErr error) error {
+	if subjErr == nil {
+		return c.handleChangeEvent(ctx, opts, event)
+	}
+	if opts.DeadLetterHandler != nil {
+		return c.deadLetter(ctx, opts, event, subjErr)
+	}
+	c.logger.Warn("publishing change event on the default subject", "subj", event.Subj, "token", event.Id,
+		"err", subjErr)
+	return c.handleChangeEvent(ctx, opts, event)
+}
 
-		c.logger.Info("stopped watching mongodb collection", "collName", watchedColl.Name())
-		if err = cs.Close(context.Background()); err != nil {
-			return fmt.Errorf("could not close change stream: %v", err)
+// handleChangeEvent passes the change event to the ChangeEventHandler, retrying with an exponential backoff and
+// finally passing it to the DeadLetterHandler, if one is given.
+func (c *DefaultClient) handleChangeEvent(ctx context.Context, opts *WatchCollectionOptions, event *ChangeEvent) error {
+	err := opts.ChangeEventHandler(ctx, event)
+	if err == nil || opts.DeadLetterHandler == nil {
+		return err
+	}
+
+	backoff := opts.RetryBackoff
+	for attempt := 1; attempt <= opts.MaxRetries; attempt++ {
+		c.logger.Warn("retrying change event", "subj", event.Subj, "attempt", attempt, "backoff", backoff, "err", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if c.onChangeEventRetry != nil {
+			c.onChangeEventRetry(event.CollName, event.Subj)
 		}
+		if err = opts.ChangeEventHandler(ctx, event); err == nil {
+			return nil
+		}
+		backoff = min(2*backoff, opts.MaxRetryBackoff)
 	}
+	return c.deadLetter(ctx, opts, event, err)
+}
 
+// deadLetter passes the change event that could not be handled to the DeadLetterHandler, along with the cause.
+func (c *DefaultClient) deadLetter(ctx context.Context, opts *WatchCollectionOptions, event *ChangeEvent, err error) error {
+	if dlErr := opts.DeadLetterHandler(ctx, event, err); dlErr != nil {
+		return fmt.Errorf("could not dead-letter change event: %v, after: %v", dlErr, err)
+	}
+	c.logger.Error("dead-lettered change event", "subj", event.Subj, "token", event.Id, "err", err)
+	if c.onChangeEventDeadLetter != nil {
+		c.onChangeEventDeadLetter(event.CollName, event.Subj)
+	}
 	return nil
 }
 
-type resumeToken struct {
-	Value string `bson:"value"`
+func newChangeEvent(raw bson.Raw, json []byte) *ChangeEvent {
+	event := &ChangeEvent{Data: json}
+	event.Id, _ = raw.Lookup("_id", "_data").StringValueOK()
+	event.OperationType, _ = raw.Lookup("operationType").StringValueOK()
+	event.DbName, _ = raw.Lookup("ns", "db").StringValueOK()
+	event.CollName, _ = raw.Lookup("ns", "coll").StringValueOK()
+	if documentKey, ok := raw.Lookup("documentKey").DocumentOK(); ok {
+		if documentKeyJson, err := bson.MarshalExtJSON(documentKey, false, false); err == nil {
+			event.DocumentKey = string(documentKeyJson)
+		}
+	}
+	if t, i, ok := raw.Lookup("clusterTime").TimestampOK(); ok {
+		event.ClusterTime = fmt.Sprintf("%d.%d", t, i)
+	}
+	if wallTime, ok := raw.Lookup("wallTime").DateTimeOK(); ok {
+		event.WallTime = time.UnixMilli(wallTime).UTC()
+	}
+	return event
+}
+
+// eventSubject returns the subject where the given change event is published, built from the subject template if one
+// is given. If the template cannot be rendered, the default subject is returned along with the error.
+func eventSubject(opts *WatchCollectionOptions, event *ChangeEvent, raw bson.Raw) (string, error) {
+	defaultSubj := subject(opts, event.DbName, event.CollName, event.OperationType)
+	if opts.SubjectTemplate == nil {
+		return defaultSubj, nil
+	}
+	subj, err := opts.SubjectTemplate.Execute(opts.StreamName, raw)
+	if err != nil {
+		return defaultSubj, err
+	}
+	return subj, nil
+}
+
+// subject returns the subject where a change event is published: the stream name, followed by the change event's
+// namespace if a database or the whole deployment is being watched, and finally the operation type.
+// Namespace components missing from the change event, such as the collection of a dropDatabase event, are replaced
+// with underscores.
+func subject(opts *WatchCollectionOptions, dbName, collName, operationType string) string {
+	tokens := []string{opts.StreamName}
+	if opts.WatchedDbName == "" {
+		tokens = append(tokens, cmp.Or(subjectToken(dbName), "_"))
+	}
+	if opts.WatchedCollName == "" {
+		tokens = append(tokens, cmp.Or(subjectToken(collName), "_"))
+	}
+	tokens = append(tokens, operationType)
+	return strings.Join(tokens, ".")
+}
+
+func namespace(dbName, collName string) string {
+	switch {
+	case dbName == "":
+		return "*"
+	case collName == "":
+		return dbName
+	default:
+		return dbName + "." + collName
+	}
 }
 
 type ClientOption func(*DefaultClient)
@@ -287,6 +858,34 @@ func WithLogger(logger *slog.Logger) ClientOption {
 	}
 }
 
+// WithAuth sets the credentials used to authenticate to MongoDB, instead of the ones in the uri.
+func WithAuth(auth *AuthOptions) ClientOption {
+	return func(c *DefaultClient) {
+		if auth != nil {
+			c.auth = auth
+		}
+	}
+}
+
+// WithTLS enables TLS on the connection to MongoDB, instead of the tls options in the uri.
+func WithTLS(tls *TLSOptions) ClientOption {
+	return func(c *DefaultClient) {
+		if tls != nil {
+			c.tls = tls
+		}
+	}
+}
+
+// WithReadPreference sets the read preference of the change streams, instead of the one in the uri. Snapshots and
+// resume tokens are always read from the primary.
+func WithReadPreference(readPreference *ReadPreferenceOptions) ClientOption {
+	return func(c *DefaultClient) {
+		if readPreference != nil {
+			c.readPreference = readPreference
+		}
+	}
+}
+
 func WithEventListeners(listeners ...EventListener) ClientOption {
 	return func(c *DefaultClient) {
 		for _, listener := range listeners {
@@ -305,6 +904,70 @@ func OnChangeEventProcessingEvent(onChangeEventProcessing func(collName, subj st
 	}
 }
 
+func OnChangeEventRetryEvent(onChangeEventRetry func(collName, subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventRetry != nil {
+			c.onChangeEventRetry = onChangeEventRetry
+		}
+	}
+}
+
+func OnChangeEventDeadLetterEvent(onChangeEventDeadLetter func(collName, subj string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeEventDeadLetter != nil {
+			c.onChangeEventDeadLetter = onChangeEventDeadLetter
+		}
+	}
+}
+
+func OnChangeStreamBackoffEvent(onChangeStreamBackoff func(namespace string, attempts int, backoff time.Duration)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeStreamBackoff != nil {
+			c.onChangeStreamBackoff = onChangeStreamBackoff
+		}
+	}
+}
+
+func OnChangeStreamInvalidateEvent(onChangeStreamInvalidate func(namespace, action string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeStreamInvalidate != nil {
+			c.onChangeStreamInvalidate = onChangeStreamInvalidate
+		}
+	}
+}
+
+func OnChangeStreamHistoryLostEvent(onChangeStreamHistoryLost func(namespace, action string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeStreamHistoryLost != nil {
+			c.onChangeStreamHistoryLost = onChangeStreamHistoryLost
+		}
+	}
+}
+
+func OnChangeStreamClusterTimeEvent(onChangeStreamClusterTime func(namespace string, clusterTime time.Time)) EventListener {
+	return func(c *DefaultClient) {
+		if onChangeStreamClusterTime != nil {
+			c.onChangeStreamClusterTime = onChangeStreamClusterTime
+		}
+	}
+}
+
+func OnChunkMigrationEvent(onChunkMigration func(namespace, fromShard, toShard string)) EventListener {
+	return func(c *DefaultClient) {
+		if onChunkMigration != nil {
+			c.onChunkMigration = onChunkMigration
+		}
+	}
+}
+
+func OnShardChangeEventEvent(onShardChangeEvent func(namespace, shard string)) EventListener {
+	return func(c *DefaultClient) {
+		if onShardChangeEvent != nil {
+			c.onShardChangeEvent = onShardChangeEvent
+		}
+	}
+}
+
 func OnCmdStartedEvent(onCmdStartedEvent func(dbName, cmdName string)) EventListener {
 	return func(c *DefaultClient) {
 		if onCmdStartedEvent != nil {
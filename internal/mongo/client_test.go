@@ -0,0 +1,245 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestDefaultClient_handleChangeEvent(t *testing.T) {
+	var (
+		event      = &ChangeEvent{Subj: "COLL1.insert", Id: "token", CollName: "coll1"}
+		publishErr = errors.New("publish error")
+	)
+
+	t.Run("should retry and then dead-letter the change event", func(t *testing.T) {
+		var (
+			attempts    int
+			retries     int
+			deadLetters int
+			deadLetter  error
+		)
+		c := &DefaultClient{
+			logger:                  slog.Default(),
+			onChangeEventRetry:      func(_, _ string) { retries++ },
+			onChangeEventDeadLetter: func(_, _ string) { deadLetters++ },
+		}
+		opts := &WatchCollectionOptions{
+			ChangeEventHandler: func(_ context.Context, _ *ChangeEvent) error {
+				attempts++
+				return publishErr
+			},
+			MaxRetries:      2,
+			RetryBackoff:    time.Millisecond,
+			MaxRetryBackoff: time.Millisecond,
+			DeadLetterHandler: func(_ context.Context, _ *ChangeEvent, cause error) error {
+				deadLetter = cause
+				return nil
+			},
+		}
+
+		err := c.handleChangeEvent(context.Background(), opts, event)
+
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+		require.Equal(t, 2, retries)
+		require.Equal(t, 1, deadLetters)
+		require.Equal(t, publishErr, deadLetter)
+	})
+	t.Run("should stop retrying once the change event is handled", func(t *testing.T) {
+		var attempts int
+		c := &DefaultClient{logger: slog.Default()}
+		opts := &WatchCollectionOptions{
+			ChangeEventHandler: func(_ context.Context, _ *ChangeEvent) error {
+				if attempts++; attempts < 2 {
+					return publishErr
+				}
+				return nil
+			},
+			MaxRetries:      5,
+			RetryBackoff:    time.Millisecond,
+			MaxRetryBackoff: time.Millisecond,
+			DeadLetterHandler: func(_ context.Context, _ *ChangeEvent, _ error) error {
+				require.FailNow(t, "change event should not be dead-lettered")
+				return nil
+			},
+		}
+
+		err := c.handleChangeEvent(context.Background(), opts, event)
+
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+	})
+	t.Run("should return error if dead letters are not enabled", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default()}
+		opts := &WatchCollectionOptions{
+			ChangeEventHandler: func(_ context.Context, _ *ChangeEvent) error {
+				return publishErr
+			},
+		}
+
+		err := c.handleChangeEvent(context.Background(), opts, event)
+
+		require.ErrorIs(t, err, publishErr)
+	})
+	t.Run("should return error if the change event cannot be dead-lettered", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default()}
+		opts := &WatchCollectionOptions{
+			ChangeEventHandler: func(_ context.Context, _ *ChangeEvent) error {
+				return publishErr
+			},
+			DeadLetterHandler: func(_ context.Context, _ *ChangeEvent, _ error) error {
+				return errors.New("dead letter error")
+			},
+		}
+
+		err := c.handleChangeEvent(context.Background(), opts, event)
+
+		require.Error(t, err)
+	})
+}
+
+func TestDefaultClient_publishChangeEvent(t *testing.T) {
+	tmpl, err := NewSubjectTemplate("{{.Stream}}.{{.FullDocument.customer.country}}")
+	require.NoError(t, err)
+	// the customer is not a document, so the subject template cannot be rendered
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: "token"}}},
+		{Key: "operationType", Value: "insert"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "fullDocument", Value: bson.D{{Key: "customer", Value: "acme"}}},
+	})
+	require.NoError(t, err)
+	newEvent := func(opts *WatchCollectionOptions) (*ChangeEvent, error) {
+		event := newChangeEvent(raw, nil)
+		var subjErr error
+		event.Subj, subjErr = eventSubject(opts, event, raw)
+		require.Error(t, subjErr)
+		return event, subjErr
+	}
+
+	t.Run("should dead-letter the change event whose subject cannot be rendered, without retrying", func(t *testing.T) {
+		var deadLetter error
+		c := &DefaultClient{logger: slog.Default()}
+		opts := &WatchCollectionOptions{
+			WatchedDbName:   "test-db",
+			WatchedCollName: "coll1",
+			StreamName:      "COLL1",
+			SubjectTemplate: tmpl,
+			ChangeEventHandler: func(_ context.Context, _ *ChangeEvent) error {
+				require.FailNow(t, "change event should not be published")
+				return nil
+			},
+			MaxRetries: 2,
+			DeadLetterHandler: func(_ context.Context, event *ChangeEvent, cause error) error {
+				require.Equal(t, "COLL1.insert", event.Subj)
+				deadLetter = cause
+				return nil
+			},
+		}
+		event, subjErr := newEvent(opts)
+
+		err := c.publishChangeEvent(context.Background(), opts, event, subjErr)
+
+		require.NoError(t, err)
+		require.Equal(t, subjErr, deadLetter)
+	})
+	t.Run("should publish the change event on the default subject if dead letters are not enabled", func(t *testing.T) {
+		var published []string
+		c := &DefaultClient{logger: slog.Default()}
+		opts := &WatchCollectionOptions{
+			WatchedDbName:   "test-db",
+			WatchedCollName: "coll1",
+			StreamName:      "COLL1",
+			SubjectTemplate: tmpl,
+			ChangeEventHandler: func(_ context.Context, event *ChangeEvent) error {
+				published = append(published, event.Subj)
+				return nil
+			},
+		}
+		event, subjErr := newEvent(opts)
+
+		err := c.publishChangeEvent(context.Background(), opts, event, subjErr)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"COLL1.insert"}, published)
+	})
+}
+
+func TestDefaultClient_Details(t *testing.T) {
+	t.Run("should return the state of the change streams that are backing off", func(t *testing.T) {
+		c := &DefaultClient{}
+		c.setBackoffState("test-db.coll1", &BackoffState{Attempts: 2, Backoff: "2s", LastError: "publish error"})
+		c.setBackoffState("test-db.coll2", &BackoffState{Attempts: 1, Backoff: "1s"})
+		c.setBackoffState("test-db.coll2", nil)
+
+		details := c.Details()
+
+		require.Equal(t, map[string]any{"backoff": map[string]BackoffState{
+			"test-db.coll1": {Attempts: 2, Backoff: "2s", LastError: "publish error"},
+		}}, details)
+	})
+	t.Run("should return the state of the change streams whose history was lost", func(t *testing.T) {
+		c := &DefaultClient{}
+		lostAt := time.Date(2024, 6, 6, 12, 0, 0, 0, time.UTC)
+		c.setHistoryLostState("test-db.coll1", &HistoryLostState{Action: "resnapshot", LostAt: lostAt, Error: "lost"})
+
+		details := c.Details()
+
+		require.Equal(t, map[string]any{"historyLost": map[string]HistoryLostState{
+			"test-db.coll1": {Action: "resnapshot", LostAt: lostAt, Error: "lost"},
+		}}, details)
+	})
+	t.Run("should return nil if no change stream is backing off or lost its history", func(t *testing.T) {
+		c := &DefaultClient{}
+
+		require.Nil(t, c.Details())
+	})
+}
+
+func Test_subject(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          *WatchCollectionOptions
+		dbName        string
+		collName      string
+		operationType string
+		want          string
+	}{
+		{
+			name:          "should only include the operation type, if a collection is watched",
+			opts:          &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1", StreamName: "COLL1"},
+			dbName:        "test-db",
+			collName:      "coll1",
+			operationType: "insert",
+			want:          "COLL1.insert",
+		},
+		{
+			name:          "should include the namespace, if the cluster is watched",
+			opts:          &WatchCollectionOptions{StreamName: "CLUSTER"},
+			dbName:        "test-db",
+			collName:      "orders.eu",
+			operationType: "insert",
+			want:          "CLUSTER.test-db.orders_eu.insert",
+		},
+		{
+			name:          "should replace the missing collection with an underscore",
+			opts:          &WatchCollectionOptions{StreamName: "CLUSTER"},
+			dbName:        "test-db",
+			operationType: "dropDatabase",
+			want:          "CLUSTER.test-db._.dropDatabase",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subject(tt.opts, tt.dbName, tt.collName, tt.operationType)
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// InsertDeadLetterOptions describes a change event that could not be published, and the collection where it is stored.
+type InsertDeadLetterOptions struct {
+	DbName   string
+	CollName string
+	Event    *ChangeEvent
+	Err      error
+	Attempts int
+}
+
+// deadLetter is the document stored for a change event that could not be published.
+type deadLetter struct {
+	ResumeToken   string    `bson:"resumeToken"`
+	Subject       string    `bson:"subject"`
+	OperationType string    `bson:"operationType"`
+	Db            string    `bson:"db"`
+	Coll          string    `bson:"coll"`
+	ChangeEvent   bson.Raw  `bson:"changeEvent,omitempty"`
+	Error         string    `bson:"error"`
+	Attempts      int       `bson:"attempts"`
+	FailedAt      time.Time `bson:"failedAt"`
+}
+
+// InsertDeadLetter stores the given change event in the given collection, along with the error that prevented its
+// publication.
+func (c *DefaultClient) InsertDeadLetter(ctx context.Context, opts *InsertDeadLetterOptions) error {
+	doc := &deadLetter{
+		ResumeToken:   opts.Event.Id,
+		Subject:       opts.Event.Subj,
+		OperationType: opts.Event.OperationType,
+		Db:            opts.Event.DbName,
+		Coll:          opts.Event.CollName,
+		Attempts:      opts.Attempts,
+		FailedAt:      time.Now().UTC(),
+	}
+	if opts.Err != nil {
+		doc.Error = opts.Err.Error()
+	}
+	if err := bson.UnmarshalExtJSON(opts.Event.Data, false, &doc.ChangeEvent); err != nil {
+		return fmt.Errorf("could not unmarshal mongo change event from json: %v", err)
+	}
+
+	coll := c.client.Database(opts.DbName).Collection(opts.CollName)
+	if _, err := coll.InsertOne(ctx, doc); err != nil {
+		return fmt.Errorf("could not insert dead letter in mongo collection %v: %v", opts.CollName, err)
+	}
+	return nil
+}
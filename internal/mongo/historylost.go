@@ -0,0 +1,88 @@
+package mongo
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// HistoryLostFail fails the watcher once the change stream cannot be resumed from the oplog.
+	HistoryLostFail = "fail"
+	// HistoryLostRestartFromNow starts the change stream at the current operation time, skipping the lost changes.
+	HistoryLostRestartFromNow = "restart-from-now"
+	// HistoryLostResnapshot publishes the existing documents of the watched collection again, and then starts the
+	// change stream at the operation time captured before scanning them.
+	HistoryLostResnapshot = "resnapshot"
+)
+
+// the error codes reported by MongoDB when a change stream cannot be resumed after the given resume token
+const (
+	invalidResumeTokenCode      = 260
+	changeStreamHistoryLostCode = 286
+)
+
+var ErrHistoryLost = errors.New("change stream history lost")
+
+// HistoryLostState represents a change stream that could not be resumed from the oplog, and how it recovered.
+type HistoryLostState struct {
+	Action string    `json:"action"`
+	LostAt time.Time `json:"lostAt"`
+	Error  string    `json:"error"`
+}
+
+// isHistoryLost returns whether the given error reports that the resume token is no longer in the oplog, or cannot be
+// used to resume the change stream.
+func isHistoryLost(err error) bool {
+	var serverErr mongo.ServerError
+	return errors.As(err, &serverErr) &&
+		(serverErr.HasErrorCode(changeStreamHistoryLostCode) || serverErr.HasErrorCode(invalidResumeTokenCode))
+}
+
+// historyLost applies the OnHistoryLost policy once the change stream could not be resumed with the given error. It
+// returns whether the change stream should be reopened.
+func (c *DefaultClient) historyLost(ctx context.Context, w *watcher, cause error) (bool, error) {
+	action := cmp.Or(w.opts.OnHistoryLost, HistoryLostFail)
+	c.logger.Error("change stream history lost, changes may have been missed", "dbName", w.opts.WatchedDbName,
+		"collName", w.opts.WatchedCollName, "onHistoryLost", action, "err", cause)
+	c.setHistoryLostState(w.namespace, &HistoryLostState{Action: action, LostAt: time.Now().UTC(), Error: cause.Error()})
+	w.recovering = true
+	if c.onChangeStreamHistoryLost != nil {
+		c.onChangeStreamHistoryLost(w.namespace, action)
+	}
+
+	switch action {
+	case HistoryLostRestartFromNow:
+		opTime, err := c.operationTime(ctx)
+		if err != nil {
+			return true, err
+		}
+		w.snapshot, w.startAt, w.startAfter = nil, &opTime, ""
+		return true, nil
+	case HistoryLostResnapshot:
+		w.startAt, w.startAfter = nil, ""
+		if err := c.takeSnapshot(ctx, w); err != nil {
+			return true, err
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("could not resume mongo namespace %v: %w", w.namespace, cause)
+	}
+}
+
+func (c *DefaultClient) setHistoryLostState(namespace string, state *HistoryLostState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if state == nil {
+		delete(c.historyLostStates, namespace)
+		return
+	}
+	if c.historyLostStates == nil {
+		c.historyLostStates = make(map[string]*HistoryLostState)
+	}
+	c.historyLostStates[namespace] = state
+}
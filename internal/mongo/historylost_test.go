@@ -0,0 +1,83 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func Test_isHistoryLost(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "should detect change stream history lost",
+			err:  mongo.CommandError{Code: 286, Name: "ChangeStreamHistoryLost"},
+			want: true,
+		},
+		{
+			name: "should detect invalid resume token",
+			err:  fmt.Errorf("wrapped: %w", mongo.CommandError{Code: 260, Name: "InvalidResumeToken"}),
+			want: true,
+		},
+		{
+			name: "should not detect other server errors",
+			err:  mongo.CommandError{Code: 13, Name: "Unauthorized"},
+		},
+		{
+			name: "should not detect other errors",
+			err:  errors.New("connection refused"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, isHistoryLost(tt.err))
+		})
+	}
+}
+
+func TestDefaultClient_historyLost(t *testing.T) {
+	t.Run("should fail and report the lost history by default", func(t *testing.T) {
+		var action string
+		c := &DefaultClient{
+			logger:                    slog.Default(),
+			onChangeStreamHistoryLost: func(_, a string) { action = a },
+		}
+		w := &watcher{
+			opts:      &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1"},
+			namespace: "test-db.coll1",
+		}
+		cause := fmt.Errorf("%w: change stream failed", ErrHistoryLost)
+
+		reopen, err := c.historyLost(context.Background(), w, cause)
+
+		require.False(t, reopen)
+		require.ErrorIs(t, err, ErrHistoryLost)
+		require.Equal(t, "fail", action)
+		require.Equal(t, "fail", c.historyLostStates["test-db.coll1"].Action)
+	})
+	t.Run("should clear the lost history once a change event is processed", func(t *testing.T) {
+		c := &DefaultClient{logger: slog.Default()}
+		w := &watcher{
+			opts: &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1",
+				OnHistoryLost: HistoryLostFail},
+			tokenStore: &testTokenStore{},
+			namespace:  "test-db.coll1",
+			backoff:    &backoff{},
+		}
+		_, _ = c.historyLost(context.Background(), w, fmt.Errorf("%w: change stream failed", ErrHistoryLost))
+		require.Contains(t, c.historyLostStates, "test-db.coll1")
+
+		require.NoError(t, c.processed(context.Background(), w, &ChangeEvent{Id: "token"}, 1))
+
+		require.NotContains(t, c.historyLostStates, "test-db.coll1")
+		require.False(t, w.recovering)
+	})
+}
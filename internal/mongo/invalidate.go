@@ -0,0 +1,164 @@
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// InvalidateStop stops watching once the change stream is invalidated, e.g. after the watched collection is
+	// dropped or renamed.
+	InvalidateStop = "stop"
+	// InvalidateRestart reopens the change stream after the invalidate event, so that a recreated collection keeps
+	// being watched.
+	InvalidateRestart = "restart"
+	// InvalidateFollowRename watches the new name of a renamed collection, and behaves like InvalidateRestart after
+	// other invalidations.
+	InvalidateFollowRename = "follow-rename"
+)
+
+const renameOperationType = "rename"
+
+// renamedNamespace represents the namespace a watched collection was renamed to.
+type renamedNamespace struct {
+	dbName   string
+	collName string
+	opTime   primitive.Timestamp // the cluster time of the rename event
+}
+
+func newRenamedNamespace(raw bson.Raw) *renamedNamespace {
+	to := &renamedNamespace{}
+	to.dbName, _ = raw.Lookup("to", "db").StringValueOK()
+	to.collName, _ = raw.Lookup("to", "coll").StringValueOK()
+	to.opTime.T, to.opTime.I, _ = raw.Lookup("clusterTime").TimestampOK()
+	return to
+}
+
+// RenameStore is implemented by the token stores that also persist the rename followed by a watcher, so that the
+// renamed collection keeps being watched after a restart. The rename is opaque to the store, like the resume tokens.
+type RenameStore interface {
+	// LastRename returns the last stored rename, or an empty string if no rename was followed.
+	LastRename(ctx context.Context) (string, error)
+	// StoreRename stores the given rename, or forgets the stored one if it is empty.
+	StoreRename(ctx context.Context, rename string) error
+}
+
+// followedRename represents a rename followed by a watcher, as stored in its RenameStore.
+type followedRename struct {
+	DbName   string              `json:"dbName"`
+	CollName string              `json:"collName"`
+	OpTime   primitive.Timestamp `json:"opTime"`
+	// LastToken is the last resume token stored before the rename, which cannot be used to watch the new name.
+	LastToken string `json:"lastToken"`
+}
+
+// collection returns the names of the watched collection, which differ from the configured ones once a rename is
+// followed.
+func (w *watcher) collection() (string, string) {
+	if w.followed != nil {
+		return w.followed.dbName, w.followed.collName
+	}
+	return w.opts.WatchedDbName, w.opts.WatchedCollName
+}
+
+// applyRename watches the new name of the watched collection if a rename was followed before the watcher started. It
+// is watched after the last stored resume token if it was stored after the rename, from the time of the rename
+// otherwise. Resetting the stored resume tokens forgets the rename.
+func (c *DefaultClient) applyRename(ctx context.Context, w *watcher) error {
+	store, ok := w.tokenStore.(RenameStore)
+	if !ok || w.opts.OnInvalidate != InvalidateFollowRename {
+		return nil
+	}
+	if w.opts.ResetTokens {
+		return store.StoreRename(ctx, "")
+	}
+	stored, err := store.LastRename(ctx)
+	if err != nil || stored == "" {
+		return err
+	}
+	rename := &followedRename{}
+	if err = json.Unmarshal([]byte(stored), rename); err != nil {
+		return fmt.Errorf("could not decode followed rename: %v", err)
+	}
+	lastToken, err := w.tokenStore.LastToken(ctx)
+	if err != nil {
+		return err
+	}
+	w.followed = &renamedNamespace{dbName: rename.DbName, collName: rename.CollName, opTime: rename.OpTime}
+	w.watched = c.client.Database(rename.DbName).Collection(rename.CollName)
+	if lastToken == rename.LastToken {
+		w.startAt = &rename.OpTime
+	}
+	c.logger.Info("following renamed collection", "dbName", w.opts.WatchedDbName, "collName", w.opts.WatchedCollName,
+		"toDbName", rename.DbName, "toCollName", rename.CollName)
+	return nil
+}
+
+// storeRename stores the rename followed by the watcher, along with the last resume token stored before it. Token
+// stores that are not a RenameStore only follow the rename until the watcher restarts.
+func (c *DefaultClient) storeRename(ctx context.Context, w *watcher, to *renamedNamespace) error {
+	store, ok := w.tokenStore.(RenameStore)
+	if !ok {
+		c.logger.Warn("token store cannot store renames, the rename is only followed until restarting",
+			"dbName", w.opts.WatchedDbName, "collName", w.opts.WatchedCollName)
+		return nil
+	}
+	lastToken, err := w.tokenStore.LastToken(ctx)
+	if err != nil {
+		return err
+	}
+	rename, err := json.Marshal(&followedRename{DbName: to.dbName, CollName: to.collName, OpTime: to.opTime,
+		LastToken: lastToken})
+	if err != nil {
+		return err
+	}
+	return store.StoreRename(ctx, string(rename))
+}
+
+// invalidated applies the OnInvalidate policy once the change stream has been invalidated by the given event. It
+// returns whether the change stream should be reopened.
+func (c *DefaultClient) invalidated(ctx context.Context, w *watcher, event *ChangeEvent) (bool, error) {
+	logAttrs := []any{"dbName", w.opts.WatchedDbName, "collName", w.opts.WatchedCollName}
+	// the change events processed before the invalidate event are checkpointed first, so that their resume token does
+	// not overwrite the ones stored below
+	if err := c.flushCheckpoint(ctx, w); err != nil {
+		return true, err
+	}
+	w.snapshot = nil
+
+	action := InvalidateStop
+	switch {
+	case w.opts.OnInvalidate == InvalidateFollowRename && w.renamedTo != nil:
+		// the renamed collection is watched from the time of the rename, as the resume tokens of its former name
+		// cannot be used to watch it
+		action = InvalidateFollowRename
+		to := w.renamedTo
+		if err := c.storeRename(ctx, w, to); err != nil {
+			return true, err
+		}
+		w.watched = c.client.Database(to.dbName).Collection(to.collName)
+		w.startAt, w.startAfter, w.renamedTo, w.followed = &to.opTime, "", nil, to
+		c.logger.Warn("following renamed collection", append(logAttrs,
+			"toDbName", to.dbName, "toCollName", to.collName)...)
+	case w.opts.OnInvalidate == InvalidateRestart || w.opts.OnInvalidate == InvalidateFollowRename:
+		// the invalidate event is kept in memory as well, since the token store may not have stored it, e.g. if tokens
+		// are stored in the stream and the invalidate event was not published
+		action = InvalidateRestart
+		if err := w.tokenStore.StoreToken(ctx, event.Id); err != nil {
+			return true, err
+		}
+		w.startAt, w.startAfter = nil, event.Id
+		c.logger.Warn("restarting invalidated change stream", logAttrs...)
+	default:
+		c.logger.Warn("stopped watching invalidated change stream", logAttrs...)
+	}
+
+	if c.onChangeStreamInvalidate != nil {
+		c.onChangeStreamInvalidate(w.namespace, action)
+	}
+	return action != InvalidateStop, nil
+}
@@ -0,0 +1,198 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestDefaultClient_invalidated(t *testing.T) {
+	// the client is never used to reach mongodb
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+	})
+
+	rename, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "rename"},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: 1683637178, I: 2}},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "to", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll3"}}},
+	})
+	require.NoError(t, err)
+	invalidate := &ChangeEvent{Id: "invalidate-token", OperationType: "invalidate"}
+
+	tests := []struct {
+		name           string
+		onInvalidate   string
+		renamed        bool
+		wantReopen     bool
+		wantAction     string
+		wantStartAfter string
+		wantStartAt    *primitive.Timestamp
+		wantToken      string
+		wantWatched    string
+	}{
+		{
+			name:        "should stop watching by default",
+			wantAction:  "stop",
+			wantWatched: "coll1",
+		},
+		{
+			name:           "should restart after the invalidate event",
+			onInvalidate:   InvalidateRestart,
+			renamed:        true,
+			wantReopen:     true,
+			wantAction:     "restart",
+			wantStartAfter: "invalidate-token",
+			wantToken:      "invalidate-token",
+			wantWatched:    "coll1",
+		},
+		{
+			name:         "should follow the renamed collection from the time of the rename",
+			onInvalidate: InvalidateFollowRename,
+			renamed:      true,
+			wantReopen:   true,
+			wantAction:   "follow-rename",
+			wantStartAt:  &primitive.Timestamp{T: 1683637178, I: 2},
+			wantWatched:  "coll3",
+		},
+		{
+			name:           "should restart after the invalidate event, if the collection was not renamed",
+			onInvalidate:   InvalidateFollowRename,
+			wantReopen:     true,
+			wantAction:     "restart",
+			wantStartAfter: "invalidate-token",
+			wantToken:      "invalidate-token",
+			wantWatched:    "coll1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var action string
+			c := &DefaultClient{
+				logger:                   slog.Default(),
+				client:                   client,
+				onChangeStreamInvalidate: func(_, a string) { action = a },
+			}
+			tokenStore := &testTokenStore{}
+			w := &watcher{
+				opts:       &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1", OnInvalidate: tt.onInvalidate},
+				watched:    client.Database("test-db").Collection("coll1"),
+				tokenStore: tokenStore,
+				namespace:  "test-db.coll1",
+			}
+			if tt.renamed {
+				w.renamedTo = newRenamedNamespace(rename)
+			}
+
+			reopen, err := c.invalidated(context.Background(), w, invalidate)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantReopen, reopen)
+			require.Equal(t, tt.wantAction, action)
+			require.Equal(t, tt.wantStartAfter, w.startAfter)
+			require.Equal(t, tt.wantStartAt, w.startAt)
+			require.Equal(t, tt.wantToken, tokenStore.token)
+			require.Equal(t, tt.wantWatched, w.watched.(*mongo.Collection).Name())
+		})
+	}
+}
+
+type testTokenStore struct {
+	token string
+}
+
+func (s *testTokenStore) LastToken(_ context.Context) (string, error) {
+	return s.token, nil
+}
+
+func (s *testTokenStore) StoreToken(_ context.Context, token string) error {
+	s.token = token
+	return nil
+}
+
+func TestDefaultClient_applyRename(t *testing.T) {
+	// the client is never used to reach mongodb
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:27017"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+	})
+
+	rename, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "rename"},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: 1683637178, I: 2}},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "to", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll3"}}},
+	})
+	require.NoError(t, err)
+	c := &DefaultClient{logger: slog.Default(), client: client}
+	tokenStore := &testRenameStore{testTokenStore: testTokenStore{token: "before-rename-token"}}
+	newWatcher := func(resetTokens bool) *watcher {
+		return &watcher{
+			opts: &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1",
+				OnInvalidate: InvalidateFollowRename, ResetTokens: resetTokens},
+			watched:    client.Database("test-db").Collection("coll1"),
+			tokenStore: tokenStore,
+			namespace:  "test-db.coll1",
+		}
+	}
+
+	w := newWatcher(false)
+	w.renamedTo = newRenamedNamespace(rename)
+	reopen, err := c.invalidated(context.Background(), w, &ChangeEvent{Id: "invalidate-token", OperationType: "invalidate"})
+	require.NoError(t, err)
+	require.True(t, reopen)
+	require.NotEmpty(t, tokenStore.rename)
+
+	t.Run("should watch the renamed collection from the time of the rename once restarted", func(t *testing.T) {
+		w := newWatcher(false)
+
+		require.NoError(t, c.applyRename(context.Background(), w))
+
+		require.Equal(t, "coll3", w.watched.(*mongo.Collection).Name())
+		require.Equal(t, &primitive.Timestamp{T: 1683637178, I: 2}, w.startAt)
+		dbName, collName := w.collection()
+		require.Equal(t, "test-db.coll3", dbName+"."+collName)
+	})
+	t.Run("should watch the renamed collection after its last resume token once restarted", func(t *testing.T) {
+		require.NoError(t, tokenStore.StoreToken(context.Background(), "after-rename-token"))
+		w := newWatcher(false)
+
+		require.NoError(t, c.applyRename(context.Background(), w))
+
+		require.Equal(t, "coll3", w.watched.(*mongo.Collection).Name())
+		require.Nil(t, w.startAt)
+	})
+	t.Run("should forget the rename once the resume tokens are reset", func(t *testing.T) {
+		w := newWatcher(true)
+
+		require.NoError(t, c.applyRename(context.Background(), w))
+
+		require.Equal(t, "coll1", w.watched.(*mongo.Collection).Name())
+		require.Nil(t, w.startAt)
+		require.Empty(t, tokenStore.rename)
+	})
+}
+
+type testRenameStore struct {
+	testTokenStore
+	rename string
+}
+
+func (s *testRenameStore) LastRename(_ context.Context) (string, error) {
+	return s.rename, nil
+}
+
+func (s *testRenameStore) StoreRename(_ context.Context, rename string) error {
+	s.rename = rename
+	return nil
+}
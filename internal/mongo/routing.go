@@ -0,0 +1,416 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UnknownShard is reported as the shard of the change events whose document cannot be routed, e.g. because the
+// routing table cannot be read, or the shard key holds values that cannot be compared.
+const UnknownShard = "unknown"
+
+// routingTableTTL bounds how long the routing table of a collection is used before being read again, since the
+// balancer also moves chunks between shards that already own data of the collection, without any change event.
+const routingTableTTL = 30 * time.Second
+
+// routingTable represents the shards owning the documents of a collection, as stored in the config database.
+type routingTable struct {
+	loadedAt time.Time
+	shard    string  // the shard owning the whole collection, if it is not sharded
+	key      bson.D  // the shard key pattern
+	chunks   []chunk // sorted by min
+}
+
+// chunk represents a range of shard key values, from min included to max excluded, owned by a shard.
+type chunk struct {
+	Min   bson.Raw `bson:"min"`
+	Max   bson.Raw `bson:"max"`
+	Shard string   `bson:"shard"`
+}
+
+// observeShard reports the shard owning the document of the given change event, read from the routing table of its
+// collection. Change events without a document, such as DDL ones, are not reported.
+func (c *DefaultClient) observeShard(ctx context.Context, w *watcher, event *ChangeEvent, raw bson.Raw) {
+	if c.onShardChangeEvent == nil || !c.sharded() {
+		return
+	}
+	documentKey, ok := raw.Lookup("documentKey").DocumentOK()
+	if !ok {
+		return
+	}
+	shard := UnknownShard
+	if table := c.routingTable(ctx, w, event.DbName, event.CollName); table != nil {
+		if owner, ok := table.route(documentKey, table.hashed() && c.hashedShardKeys(ctx)); ok {
+			shard = owner
+		}
+	}
+	c.onShardChangeEvent(w.namespace, shard)
+}
+
+// sharded returns whether the client is connected to a sharded cluster.
+func (c *DefaultClient) sharded() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topology != nil && c.topology.Kind == TopologySharded
+}
+
+// routingTable returns the routing table of the given collection, read again once it expires, or nil if it cannot be
+// read.
+func (c *DefaultClient) routingTable(ctx context.Context, w *watcher, dbName, collName string) *routingTable {
+	ns := dbName + "." + collName
+	if table, ok := w.routingTables[ns]; ok && time.Since(table.loadedAt) < routingTableTTL {
+		return table
+	}
+	table, err := c.loadRoutingTable(ctx, dbName, collName)
+	if err != nil {
+		// the routing table is read again with the next change event only once it expires
+		c.logger.Warn("could not read routing table", "namespace", ns, "err", err)
+		table = &routingTable{}
+	}
+	table.loadedAt = time.Now()
+	if w.routingTables == nil {
+		w.routingTables = make(map[string]*routingTable)
+	}
+	w.routingTables[ns] = table
+	if err != nil {
+		return nil
+	}
+	return table
+}
+
+// loadRoutingTable reads the shard key and the chunks of the given collection from the config database, or the primary
+// shard of its database if it is not sharded.
+func (c *DefaultClient) loadRoutingTable(ctx context.Context, dbName, collName string) (*routingTable, error) {
+	configDb := c.client.Database("config")
+	var coll struct {
+		Uuid bson.RawValue `bson:"uuid"`
+		Key  bson.D        `bson:"key"`
+	}
+	err := configDb.Collection("collections").FindOne(ctx, bson.D{{Key: "_id", Value: dbName + "." + collName}}).
+		Decode(&coll)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the documents of an unsharded collection belong to the primary shard of its database
+		var db struct {
+			Primary string `bson:"primary"`
+		}
+		if err = configDb.Collection("databases").FindOne(ctx, bson.D{{Key: "_id", Value: dbName}}).Decode(&db); err != nil {
+			return nil, err
+		}
+		return &routingTable{shard: db.Primary}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// chunks are referenced by the collection uuid since MongoDB 5.0, and by its namespace before
+	filter := bson.D{{Key: "ns", Value: dbName + "." + collName}}
+	if coll.Uuid.Type != 0 {
+		filter = bson.D{{Key: "uuid", Value: coll.Uuid}}
+	}
+	findOpts := options.Find().SetSort(bson.D{{Key: "min", Value: 1}}).
+		SetProjection(bson.D{{Key: "min", Value: 1}, {Key: "max", Value: 1}, {Key: "shard", Value: 1}})
+	cur, err := configDb.Collection("chunks").Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	table := &routingTable{key: coll.Key}
+	if err = cur.All(ctx, &table.chunks); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// hashed returns whether the collection is sharded by a hashed shard key.
+func (t *routingTable) hashed() bool {
+	return slices.ContainsFunc(t.key, func(field bson.E) bool {
+		return field.Value == "hashed"
+	})
+}
+
+// route returns the shard owning the document with the given key. Hashed shard key values are only routed if hashed
+// is set, i.e. if the hashes computed by the client match those of the server.
+func (t *routingTable) route(documentKey bson.Raw, hashed bool) (string, bool) {
+	if t.shard != "" {
+		return t.shard, true
+	}
+	values := make([]bson.RawValue, 0, len(t.key))
+	for _, field := range t.key {
+		value := lookupShardKey(documentKey, field.Key)
+		if field.Value == "hashed" {
+			if !hashed {
+				return "", false
+			}
+			h, ok := hashShardKey(value)
+			if !ok {
+				return "", false
+			}
+			value = bson.RawValue{Type: bsontype.Int64, Value: binary.LittleEndian.AppendUint64(nil, uint64(h))}
+		}
+		values = append(values, value)
+	}
+
+	// the owning chunk is the last one whose min is not greater than the values
+	var cmpErr bool
+	i, found := slices.BinarySearchFunc(t.chunks, values, func(ch chunk, values []bson.RawValue) int {
+		c, ok := compareShardKey(ch.Min, values)
+		cmpErr = cmpErr || !ok
+		return c
+	})
+	if !found {
+		i--
+	}
+	if cmpErr || i < 0 {
+		return "", false
+	}
+	if c, ok := compareShardKey(t.chunks[i].Max, values); !ok || c <= 0 {
+		return "", false
+	}
+	return t.chunks[i].Shard, true
+}
+
+// lookupShardKey returns the value of the given shard key field in the document key, which may be dotted, or null if
+// the document does not have it.
+func lookupShardKey(documentKey bson.Raw, field string) bson.RawValue {
+	if value, err := documentKey.LookupErr(field); err == nil {
+		return value
+	}
+	if value, err := documentKey.LookupErr(strings.Split(field, ".")...); err == nil {
+		return value
+	}
+	return bson.RawValue{Type: bsontype.Null}
+}
+
+// compareShardKey compares the shard key bound of a chunk with the given values, field by field.
+func compareShardKey(bound bson.Raw, values []bson.RawValue) (int, bool) {
+	elems, err := bound.Elements()
+	if err != nil || len(elems) != len(values) {
+		return 0, false
+	}
+	for i, elem := range elems {
+		if c, ok := compareValues(elem.Value(), values[i]); !ok || c != 0 {
+			return c, ok
+		}
+	}
+	return 0, true
+}
+
+// canonicalTypes orders the BSON types as MongoDB does when comparing values of different types.
+var canonicalTypes = map[bsontype.Type]int32{
+	bsontype.MinKey:           -1,
+	bsontype.Type(0):          0, // the end of a document, only hashed
+	bsontype.Undefined:        0,
+	bsontype.Null:             5,
+	bsontype.Double:           10,
+	bsontype.Int32:            10,
+	bsontype.Int64:            10,
+	bsontype.Decimal128:       10,
+	bsontype.String:           15,
+	bsontype.Symbol:           15,
+	bsontype.EmbeddedDocument: 20,
+	bsontype.Array:            25,
+	bsontype.Binary:           30,
+	bsontype.ObjectID:         35,
+	bsontype.Boolean:          40,
+	bsontype.DateTime:         45,
+	bsontype.Timestamp:        47,
+	bsontype.Regex:            50,
+	bsontype.DBPointer:        55,
+	bsontype.JavaScript:       60,
+	bsontype.CodeWithScope:    65,
+	bsontype.MaxKey:           127,
+}
+
+// compareValues compares two BSON values as MongoDB does with the simple collation. It returns false for the values
+// that cannot be compared by the client, such as embedded documents or decimals.
+func compareValues(a, b bson.RawValue) (int, bool) {
+	ta, okA := canonicalTypes[a.Type]
+	tb, okB := canonicalTypes[b.Type]
+	if !okA || !okB {
+		return 0, false
+	}
+	if ta != tb {
+		return cmpInt(int64(ta), int64(tb)), true
+	}
+	switch ta {
+	case canonicalTypes[bsontype.MinKey], canonicalTypes[bsontype.Undefined], canonicalTypes[bsontype.Null],
+		canonicalTypes[bsontype.MaxKey]:
+		return 0, true
+	case canonicalTypes[bsontype.Int64]:
+		if a.Type == bsontype.Decimal128 || b.Type == bsontype.Decimal128 {
+			return 0, false
+		}
+		if a.Type != bsontype.Double && b.Type != bsontype.Double {
+			return cmpInt(a.AsInt64(), b.AsInt64()), true
+		}
+		fa, fb := toFloat(a), toFloat(b)
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, fa == fb
+	case canonicalTypes[bsontype.String]:
+		sa, _ := a.StringValueOK()
+		if a.Type == bsontype.Symbol {
+			sa, _ = a.SymbolOK()
+		}
+		sb, _ := b.StringValueOK()
+		if b.Type == bsontype.Symbol {
+			sb, _ = b.SymbolOK()
+		}
+		return strings.Compare(sa, sb), true
+	case canonicalTypes[bsontype.Binary]:
+		// binary data is compared by length, then by subtype, then byte by byte
+		subA, dataA := a.Binary()
+		subB, dataB := b.Binary()
+		if c := cmpInt(int64(len(dataA)), int64(len(dataB))); c != 0 {
+			return c, true
+		}
+		if c := cmpInt(int64(subA), int64(subB)); c != 0 {
+			return c, true
+		}
+		return bytes.Compare(dataA, dataB), true
+	case canonicalTypes[bsontype.ObjectID]:
+		oa, ob := a.ObjectID(), b.ObjectID()
+		return bytes.Compare(oa[:], ob[:]), true
+	case canonicalTypes[bsontype.Boolean]:
+		return cmpBool(a.Boolean(), b.Boolean()), true
+	case canonicalTypes[bsontype.DateTime]:
+		return cmpInt(a.DateTime(), b.DateTime()), true
+	case canonicalTypes[bsontype.Timestamp]:
+		ta, ia := a.Timestamp()
+		tb, ib := b.Timestamp()
+		if c := cmpInt(int64(ta), int64(tb)); c != 0 {
+			return c, true
+		}
+		return cmpInt(int64(ia), int64(ib)), true
+	}
+	return 0, false
+}
+
+func cmpInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func cmpBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+func toFloat(v bson.RawValue) float64 {
+	if v.Type == bsontype.Double {
+		return v.Double()
+	}
+	return float64(v.AsInt64())
+}
+
+// hashShardKey computes the hash of a hashed shard key value as MongoDB does: the first 8 bytes of the MD5 digest of
+// the value, with numbers squashed to 64-bit integers, read as a little-endian integer.
+func hashShardKey(value bson.RawValue) (int64, bool) {
+	h := md5.New()
+	_ = binary.Write(h, binary.LittleEndian, int32(0)) // the default seed
+	if !hashValue(h, "", value, false) {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(h.Sum(nil)[:8])), true
+}
+
+func hashValue(h hash.Hash, name string, value bson.RawValue, includeName bool) bool {
+	canonicalType, ok := canonicalTypes[value.Type]
+	if !ok {
+		return false
+	}
+	_ = binary.Write(h, binary.LittleEndian, canonicalType)
+	if includeName {
+		h.Write(append([]byte(name), 0))
+	}
+	switch value.Type {
+	case bsontype.Decimal128, bsontype.CodeWithScope:
+		return false
+	case bsontype.Double, bsontype.Int32, bsontype.Int64:
+		_ = binary.Write(h, binary.LittleEndian, safeNumberLong(value))
+	case bsontype.EmbeddedDocument, bsontype.Array:
+		elems, err := bson.Raw(value.Value).Elements()
+		if err != nil {
+			return false
+		}
+		for _, elem := range elems {
+			if !hashValue(h, elem.Key(), elem.Value(), true) {
+				return false
+			}
+		}
+		// the terminating element, without name nor value
+		return hashValue(h, "", bson.RawValue{}, true)
+	default:
+		h.Write(value.Value)
+	}
+	return true
+}
+
+// safeNumberLong converts a number to a 64-bit integer as MongoDB does, truncating and clamping doubles.
+func safeNumberLong(value bson.RawValue) int64 {
+	if value.Type != bsontype.Double {
+		return value.AsInt64()
+	}
+	d := value.Double()
+	switch {
+	case math.IsNaN(d):
+		return 0
+	case d >= math.MaxInt64:
+		return math.MaxInt64
+	case d < math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(d)
+}
+
+// hashedShardKeys returns whether the hashed shard key values computed by the client match those computed by the
+// server, which is checked once with a sample value. Otherwise, the change events of collections sharded by a hashed
+// key are reported with an unknown shard.
+func (c *DefaultClient) hashedShardKeys(ctx context.Context) bool {
+	c.hashedShardKeysOnce.Do(func() {
+		sampleType, sampleValue, _ := bson.MarshalValue("mongodb-nats-connector")
+		sample := bson.RawValue{Type: sampleType, Value: sampleValue}
+		var reply struct {
+			OutputValue int64 `bson:"outputValue"`
+		}
+		err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "convertShardKeyToHashed", Value: sample}}).
+			Decode(&reply)
+		hashed, _ := hashShardKey(sample)
+		switch {
+		case err != nil:
+			c.logger.Warn("could not check hashed shard keys", "err", err)
+		case reply.OutputValue != hashed:
+			c.logger.Warn("hashed shard keys do not match those of the server, their shard will be unknown",
+				"hashed", hashed, "want", reply.OutputValue)
+		default:
+			c.hashedShardKeysMatch = true
+		}
+	})
+	return c.hashedShardKeysMatch
+}
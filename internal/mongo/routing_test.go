@@ -0,0 +1,159 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRoutingTable_route(t *testing.T) {
+	table := &routingTable{
+		key: bson.D{{Key: "_id", Value: 1}},
+		chunks: []chunk{
+			newChunk(t, primitive.MinKey{}, 0, "shard1"),
+			newChunk(t, 0, 100, "shard2"),
+			newChunk(t, 100, primitive.MaxKey{}, "shard1"),
+		},
+	}
+
+	tests := []struct {
+		name      string
+		id        any
+		wantShard string
+		wantOk    bool
+	}{
+		{name: "should route a document below the first bound", id: int32(-5), wantShard: "shard1", wantOk: true},
+		{name: "should route a document on a chunk min", id: int64(0), wantShard: "shard2", wantOk: true},
+		{name: "should route a double", id: 99.5, wantShard: "shard2", wantOk: true},
+		{name: "should route a document on a chunk max", id: int32(100), wantShard: "shard1", wantOk: true},
+		{name: "should route a string, greater than any number", id: "id", wantShard: "shard1", wantOk: true},
+		{name: "should not route a decimal", id: primitive.NewDecimal128(0, 1), wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documentKey, err := bson.Marshal(bson.D{{Key: "_id", Value: tt.id}})
+			require.NoError(t, err)
+
+			shard, ok := table.route(documentKey, true)
+
+			require.Equal(t, tt.wantOk, ok)
+			require.Equal(t, tt.wantShard, shard)
+		})
+	}
+
+	t.Run("should route any document of an unsharded collection to the primary shard", func(t *testing.T) {
+		documentKey, err := bson.Marshal(bson.D{{Key: "_id", Value: primitive.NewObjectID()}})
+		require.NoError(t, err)
+
+		shard, ok := (&routingTable{shard: "shard2"}).route(documentKey, false)
+
+		require.True(t, ok)
+		require.Equal(t, "shard2", shard)
+	})
+	t.Run("should route a document by a hashed shard key", func(t *testing.T) {
+		documentKey, err := bson.Marshal(bson.D{{Key: "_id", Value: "id"}, {Key: "user", Value: bson.D{{Key: "id", Value: 7}}}})
+		require.NoError(t, err)
+		_, userId, _ := bson.MarshalValue(int32(7))
+		hash, ok := hashShardKey(bson.RawValue{Type: bsontype.Int32, Value: userId})
+		require.True(t, ok)
+		hashed := &routingTable{
+			key: bson.D{{Key: "user.id", Value: "hashed"}},
+			chunks: []chunk{
+				newChunk(t, primitive.MinKey{}, hash, "shard1"),
+				newChunk(t, hash, primitive.MaxKey{}, "shard2"),
+			},
+		}
+
+		shard, ok := hashed.route(documentKey, true)
+		require.True(t, ok)
+		require.Equal(t, "shard2", shard)
+
+		_, ok = hashed.route(documentKey, false)
+		require.False(t, ok)
+	})
+}
+
+func TestHashShardKey(t *testing.T) {
+	hashOf := func(value any) int64 {
+		valueType, data, err := bson.MarshalValue(value)
+		require.NoError(t, err)
+		hash, ok := hashShardKey(bson.RawValue{Type: valueType, Value: data})
+		require.True(t, ok)
+		return hash
+	}
+
+	t.Run("should hash numbers as 64-bit integers", func(t *testing.T) {
+		require.Equal(t, hashOf(int64(42)), hashOf(int32(42)))
+		require.Equal(t, hashOf(int64(42)), hashOf(42.9))
+		require.NotEqual(t, hashOf(int64(42)), hashOf(int64(43)))
+	})
+	t.Run("should hash values of different types differently", func(t *testing.T) {
+		require.NotEqual(t, hashOf("42"), hashOf(int64(42)))
+		require.NotEqual(t, hashOf(bson.D{{Key: "a", Value: 1}}), hashOf(bson.D{{Key: "b", Value: 1}}))
+	})
+}
+
+func TestDefaultClient_observeShard(t *testing.T) {
+	var events []string
+	c := &DefaultClient{
+		logger:             slog.Default(),
+		topology:           &Topology{Kind: TopologySharded},
+		onShardChangeEvent: func(namespace, shard string) { events = append(events, namespace+":"+shard) },
+	}
+	w := &watcher{
+		namespace: "test-db.coll1",
+		routingTables: map[string]*routingTable{
+			"test-db.coll1": {
+				loadedAt: time.Now(),
+				key:      bson.D{{Key: "_id", Value: 1}},
+				chunks: []chunk{
+					newChunk(t, primitive.MinKey{}, 0, "shard1"),
+					newChunk(t, 0, primitive.MaxKey{}, "shard2"),
+				},
+			},
+		},
+	}
+	observe := func(event bson.D) {
+		raw, err := bson.Marshal(event)
+		require.NoError(t, err)
+		c.observeShard(context.Background(), w, newChangeEvent(raw, nil), raw)
+	}
+
+	observe(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: 1}}},
+	})
+	observe(bson.D{
+		{Key: "operationType", Value: "delete"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: -1}}},
+	})
+	observe(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: primitive.NewDecimal128(0, 1)}}},
+	})
+	// the change events without a document are not reported
+	observe(bson.D{
+		{Key: "operationType", Value: "createIndexes"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+	})
+
+	require.Equal(t, []string{"test-db.coll1:shard2", "test-db.coll1:shard1", "test-db.coll1:" + UnknownShard}, events)
+}
+
+func newChunk(t *testing.T, min, max any, shard string) chunk {
+	t.Helper()
+	minRaw, err := bson.Marshal(bson.D{{Key: "_id", Value: min}})
+	require.NoError(t, err)
+	maxRaw, err := bson.Marshal(bson.D{{Key: "_id", Value: max}})
+	require.NoError(t, err)
+	return chunk{Min: minRaw, Max: maxRaw, Shard: shard}
+}
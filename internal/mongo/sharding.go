@@ -0,0 +1,114 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// TopologySharded represents a sharded cluster, reached through mongos.
+	TopologySharded = "sharded"
+	// TopologyReplicaSet represents a replica set.
+	TopologyReplicaSet = "replicaSet"
+	// TopologyStandalone represents a standalone server, which does not support change streams.
+	TopologyStandalone = "standalone"
+)
+
+// the operation types of the change events reporting that the data of a collection moved across shards, only reported
+// with showExpandedEvents
+const (
+	migrateChunkToNewShardOperationType = "migrateChunkToNewShard"
+	reshardCollectionOperationType      = "reshardCollection"
+	shardCollectionOperationType        = "shardCollection"
+	refineShardKeyOperationType         = "refineCollectionShardKey"
+)
+
+// mongosHelloMsg is the msg field of the hello reply of mongos.
+const mongosHelloMsg = "isdbgrid"
+
+// Topology represents the MongoDB deployment the client is connected to.
+type Topology struct {
+	Kind    string   `json:"kind"`
+	SetName string   `json:"setName,omitempty"`
+	Shards  []string `json:"shards,omitempty"`
+}
+
+// Topology detects whether the client is connected to a sharded cluster through mongos, to a replica set or to a
+// standalone server. The shards of a sharded cluster are listed if the user is allowed to, otherwise they are omitted.
+func (c *DefaultClient) Topology(ctx context.Context) (*Topology, error) {
+	reply, err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("could not detect mongodb topology: %v", err)
+	}
+	topology := &Topology{Kind: TopologyStandalone}
+	if msg, _ := reply.Lookup("msg").StringValueOK(); msg == mongosHelloMsg {
+		topology.Kind = TopologySharded
+		if topology.Shards, err = c.listShards(ctx); err != nil {
+			c.logger.Warn("could not list shards, is the clusterMonitor role granted?", "err", err)
+		}
+	} else if setName, ok := reply.Lookup("setName").StringValueOK(); ok {
+		topology.Kind = TopologyReplicaSet
+		topology.SetName = setName
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.topology = topology
+	return topology, nil
+}
+
+func (c *DefaultClient) listShards(ctx context.Context) ([]string, error) {
+	var reply struct {
+		Shards []struct {
+			Id string `bson:"_id"`
+		} `bson:"shards"`
+	}
+	if err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "listShards", Value: 1}}).Decode(&reply); err != nil {
+		return nil, err
+	}
+	shards := make([]string, 0, len(reply.Shards))
+	for _, shard := range reply.Shards {
+		shards = append(shards, shard.Id)
+	}
+	return shards, nil
+}
+
+// observeShardingEvent reports the change events of the watcher that relate to the data of a collection moving across
+// shards, whether they are published or not.
+func (c *DefaultClient) observeShardingEvent(w *watcher, event *ChangeEvent, raw bson.Raw) {
+	switch event.OperationType {
+	case migrateChunkToNewShardOperationType:
+		// the first chunk of a collection was migrated to a shard that did not own any of its data
+		fromShard, _ := raw.Lookup("from").StringValueOK()
+		toShard, _ := raw.Lookup("to").StringValueOK()
+		c.logger.Info("chunk migrated to new shard", "namespace", w.namespace, "dbName", event.DbName,
+			"collName", event.CollName, "from", fromShard, "to", toShard)
+		if c.onChunkMigration != nil {
+			c.onChunkMigration(w.namespace, fromShard, toShard)
+		}
+		// the routing table of the collection is read again with its next change event
+		delete(w.routingTables, event.DbName+"."+event.CollName)
+	case reshardCollectionOperationType:
+		// the change stream goes on after resharding, the documents are not published again
+		c.logger.Info("collection resharded", "namespace", w.namespace, "dbName", event.DbName,
+			"collName", event.CollName, "shardKey", raw.Lookup("operationDescription", "shardKey").String())
+		delete(w.routingTables, event.DbName+"."+event.CollName)
+	case shardCollectionOperationType, refineShardKeyOperationType:
+		delete(w.routingTables, event.DbName+"."+event.CollName)
+	}
+}
+
+// observeClusterTime reports the cluster time of the last change event processed by the watcher. Through mongos, the
+// change events of all the shards are merged in cluster time order, so the stream cannot get ahead of the slowest
+// shard.
+func (c *DefaultClient) observeClusterTime(w *watcher, raw bson.Raw) {
+	if c.onChangeStreamClusterTime == nil {
+		return
+	}
+	if t, _, ok := raw.Lookup("clusterTime").TimestampOK(); ok {
+		c.onChangeStreamClusterTime(w.namespace, time.Unix(int64(t), 0).UTC())
+	}
+}
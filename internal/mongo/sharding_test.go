@@ -0,0 +1,65 @@
+package mongo
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDefaultClient_observeShardingEvent(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "migrateChunkToNewShard"},
+		{Key: "clusterTime", Value: primitive.Timestamp{T: 1683637178, I: 1}},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		{Key: "from", Value: "shard1"},
+		{Key: "to", Value: "shard2"},
+	})
+	require.NoError(t, err)
+
+	var (
+		migrations  []string
+		clusterTime time.Time
+	)
+	c := &DefaultClient{
+		logger: slog.Default(),
+		onChunkMigration: func(namespace, fromShard, toShard string) {
+			migrations = append(migrations, namespace+":"+fromShard+"->"+toShard)
+		},
+		onChangeStreamClusterTime: func(_ string, t time.Time) { clusterTime = t },
+	}
+	w := &watcher{namespace: "test-db"}
+
+	c.observeShardingEvent(w, newChangeEvent(raw, nil), raw)
+	c.observeClusterTime(w, raw)
+
+	require.Equal(t, []string{"test-db:shard1->shard2"}, migrations)
+	require.Equal(t, time.Date(2023, 5, 9, 12, 59, 38, 0, time.UTC), clusterTime)
+}
+
+func TestDefaultClient_observeShardingEvent_routingTables(t *testing.T) {
+	for _, operationType := range []string{"migrateChunkToNewShard", "reshardCollection", "shardCollection",
+		"refineCollectionShardKey"} {
+		raw, err := bson.Marshal(bson.D{
+			{Key: "operationType", Value: operationType},
+			{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "coll1"}}},
+		})
+		require.NoError(t, err)
+		c := &DefaultClient{logger: slog.Default()}
+		w := &watcher{
+			namespace: "test-db",
+			routingTables: map[string]*routingTable{
+				"test-db.coll1": {loadedAt: time.Now(), shard: "shard1"},
+				"test-db.coll2": {loadedAt: time.Now(), shard: "shard1"},
+			},
+		}
+
+		c.observeShardingEvent(w, newChangeEvent(raw, nil), raw)
+
+		require.NotContains(t, w.routingTables, "test-db.coll1", operationType)
+		require.Contains(t, w.routingTables, "test-db.coll2", operationType)
+	}
+}
@@ -0,0 +1,301 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	// SnapshotNever never publishes the existing documents of the watched collection.
+	SnapshotNever = "never"
+	// SnapshotInitial publishes the existing documents of the watched collection if no resume token is stored yet.
+	SnapshotInitial = "initial"
+	// SnapshotAlways publishes the existing documents of the watched collection every time it starts being watched.
+	SnapshotAlways = "always"
+)
+
+const (
+	snapshotOperationType    = "snapshot"
+	snapshotTokenPrefix      = "snapshot:"
+	defaultSnapshotChunkSize = 1000
+)
+
+// snapshotPosition represents the progress of a snapshot: the operation time captured before the scan, where the
+// change stream starts once the scan is complete, and the _id of the last scanned document.
+// It is stored in place of a resume token until the first change event is processed.
+type snapshotPosition struct {
+	opTime primitive.Timestamp
+	lastId bson.RawValue // zero value if no document was scanned yet
+}
+
+// String formats the position as 'snapshot:<seconds>.<increment>:<last id as extended json>'.
+func (p *snapshotPosition) String() string {
+	token := fmt.Sprintf("%s%d.%d:", snapshotTokenPrefix, p.opTime.T, p.opTime.I)
+	if p.lastId.Type == 0 {
+		return token
+	}
+	lastId, err := extJsonId(p.lastId)
+	if err != nil {
+		return token
+	}
+	return token + lastId
+}
+
+// parseSnapshotPosition parses a token formatted by snapshotPosition.String, it returns false if the token is a
+// regular resume token.
+func parseSnapshotPosition(token string) (*snapshotPosition, bool) {
+	rest, ok := strings.CutPrefix(token, snapshotTokenPrefix)
+	if !ok {
+		return nil, false
+	}
+	opTime, lastId, _ := strings.Cut(rest, ":")
+	t, i, _ := strings.Cut(opTime, ".")
+	seconds, err := strconv.ParseUint(t, 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	increment, err := strconv.ParseUint(i, 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	pos := &snapshotPosition{opTime: primitive.Timestamp{T: uint32(seconds), I: uint32(increment)}}
+	if lastId != "" {
+		var doc bson.Raw
+		if err = bson.UnmarshalExtJSON([]byte(lastId), true, &doc); err != nil {
+			return nil, false
+		}
+		pos.lastId = doc.Lookup("_id")
+	}
+	return pos, true
+}
+
+// startSnapshot takes a new snapshot, if one is due according to the snapshot mode and the last stored token, unless
+// the stored tokens are reset.
+func (c *DefaultClient) startSnapshot(ctx context.Context, w *watcher) error {
+	if w.opts.Snapshot != SnapshotInitial && w.opts.Snapshot != SnapshotAlways {
+		return nil
+	}
+	lastToken, err := w.tokenStore.LastToken(ctx)
+	if err != nil {
+		return err
+	}
+	if w.opts.ResetTokens {
+		lastToken = ""
+	}
+	if _, ok := parseSnapshotPosition(lastToken); ok {
+		// an interrupted snapshot will be resumed from its position
+		return nil
+	}
+	if w.opts.Snapshot == SnapshotInitial && lastToken != "" {
+		return nil
+	}
+	return c.takeSnapshot(ctx, w)
+}
+
+// takeSnapshot captures the operation time where the change stream will start once the watched collection is
+// scanned, and stores the position of the new snapshot.
+func (c *DefaultClient) takeSnapshot(ctx context.Context, w *watcher) error {
+	opTime, err := c.operationTime(ctx)
+	if err != nil {
+		return err
+	}
+	w.snapshot = &snapshotPosition{opTime: opTime}
+	if err = w.tokenStore.StoreToken(ctx, w.snapshot.String()); err != nil {
+		return err
+	}
+	dbName, collName := w.collection()
+	c.logger.Info("starting snapshot", "dbName", dbName, "collName", collName)
+	return nil
+}
+
+// checkSnapshotHistory returns ErrHistoryLost if the operation time of the given snapshot, where the change stream
+// starts once the scan is complete, is no longer in the oplog, so that the OnHistoryLost policy applies before the
+// scan is resumed in vain. The oplog cannot be read through mongos, or without access to the local database, in which
+// case the change stream reports the lost history once opened instead.
+func (c *DefaultClient) checkSnapshotHistory(ctx context.Context, w *watcher, pos *snapshotPosition) error {
+	if c.sharded() {
+		return nil
+	}
+	earliest, err := c.earliestOplogTime(ctx)
+	if err != nil {
+		c.logger.Debug("could not check the operation time of the snapshot", "namespace", w.namespace, "err", err)
+		return nil
+	}
+	if pos.opTime.Before(earliest) {
+		return fmt.Errorf("%w: snapshot of mongo namespace %v taken at %v.%v, the oplog starts at %v.%v",
+			ErrHistoryLost, w.namespace, pos.opTime.T, pos.opTime.I, earliest.T, earliest.I)
+	}
+	return nil
+}
+
+// scanSnapshot publishes a synthetic snapshot event for each document of the watched collection following the given
+// position, in chunks sorted by _id, and checkpoints the position after each document like a resume token. The last
+// position is stored once the scan completes or is interrupted.
+func (c *DefaultClient) scanSnapshot(ctx context.Context, w *watcher, pos *snapshotPosition) error {
+	dbName, collName := w.collection()
+	coll := c.primaryCollection(dbName, collName)
+	err := scanCollection(ctx, coll, bson.D{}, pos.lastId, func(doc bson.Raw) error {
+		pos.lastId = doc.Lookup("_id")
+		return c.handleSnapshotEvent(ctx, w, pos, doc)
+	})
+	if err != nil {
+		c.closeCheckpoint(ctx, w)
+		return err
+	}
+	if err = c.flushCheckpoint(ctx, w); err != nil {
+		return err
+	}
+	c.logger.Info("completed snapshot", "dbName", dbName, "collName", collName)
+	return nil
+}
+
+func (c *DefaultClient) handleSnapshotEvent(ctx context.Context, w *watcher, pos *snapshotPosition, doc bson.Raw) error {
+	token := pos.String()
+	dbName, collName := w.collection()
+	event, subjErr, err := newSnapshotEvent(w.opts, token, dbName, collName, doc)
+	if err != nil {
+		return err
+	}
+	if err = c.publishChangeEvent(ctx, w.opts, event, subjErr); err != nil {
+		return err
+	}
+	return c.checkpoint(ctx, w, token, 1)
+}
+
+// SnapshotCollection publishes a synthetic snapshot event for each document of the collection matching the filter,
+// while the collection keeps being watched.
+func (c *DefaultClient) SnapshotCollection(ctx context.Context, opts *SnapshotCollectionOptions) error {
+	logAttrs := []any{"id", opts.Id, "dbName", opts.DbName, "collName", opts.CollName}
+	c.logger.Info("starting on-demand snapshot", logAttrs...)
+
+	var throttle <-chan time.Time
+	if opts.RateLimit > 0 {
+		ticker := time.NewTicker(max(time.Second/time.Duration(opts.RateLimit), time.Microsecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	coll := c.primaryCollection(opts.DbName, opts.CollName)
+	published := int64(0)
+	err := scanCollection(ctx, coll, opts.Filter, bson.RawValue{}, func(doc bson.Raw) error {
+		if throttle != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-throttle:
+			}
+		}
+		id, err := extJsonId(doc.Lookup("_id"))
+		if err != nil {
+			return fmt.Errorf("could not marshal mongo document id: %v", err)
+		}
+		event, subjErr, err := newSnapshotEvent(opts.Watch, opts.Id+":"+id, opts.DbName, opts.CollName, doc)
+		if err != nil {
+			return err
+		}
+		if err = c.publishChangeEvent(ctx, opts.Watch, event, subjErr); err != nil {
+			return err
+		}
+		published++
+		if opts.OnProgress != nil {
+			opts.OnProgress(published)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.logger.Info("completed on-demand snapshot", append(logAttrs, "published", published)...)
+	return nil
+}
+
+// primaryCollection returns the given collection, read from the primary whatever the read preference of the client.
+// A lagging secondary could miss documents written before the operation time where the change stream starts, leaving
+// a gap between the snapshot and the change stream.
+func (c *DefaultClient) primaryCollection(dbName, collName string) *mongo.Collection {
+	return c.client.Database(dbName).Collection(collName, options.Collection().SetReadPreference(readpref.Primary()))
+}
+
+// scanCollection calls fn with each document of the collection matching the filter and following the given _id, in
+// chunks sorted by _id, so that no cursor is kept open for long.
+func scanCollection(ctx context.Context, coll *mongo.Collection, filter bson.D, lastId bson.RawValue,
+	fn func(doc bson.Raw) error) error {
+	for {
+		chunkFilter := filter
+		if lastId.Type != 0 {
+			afterLastId := bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: lastId}}}}
+			chunkFilter = afterLastId
+			if len(filter) > 0 {
+				chunkFilter = bson.D{{Key: "$and", Value: bson.A{filter, afterLastId}}}
+			}
+		}
+		findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(defaultSnapshotChunkSize)
+		cur, err := coll.Find(ctx, chunkFilter, findOpts)
+		if err != nil {
+			return fmt.Errorf("could not scan mongo collection %v: %v", coll.Name(), err)
+		}
+
+		scanned := 0
+		for cur.Next(ctx) {
+			scanned++
+			lastId = cur.Current.Lookup("_id")
+			if err = fn(cur.Current); err != nil {
+				_ = cur.Close(context.Background())
+				return err
+			}
+		}
+		if err = cur.Err(); err != nil {
+			_ = cur.Close(context.Background())
+			return fmt.Errorf("could not scan mongo collection %v: %v", coll.Name(), err)
+		}
+		_ = cur.Close(context.Background())
+
+		if scanned < defaultSnapshotChunkSize {
+			return nil
+		}
+	}
+}
+
+// newSnapshotEvent returns a synthetic snapshot event for the given document, shaped like a change event whose resume
+// token is the given id, and sets the subject where it is published. If the subject template cannot be rendered, the
+// snapshot event is returned on its default subject, along with the subject template error.
+func newSnapshotEvent(opts *WatchCollectionOptions, id, dbName, collName string, doc bson.Raw) (*ChangeEvent, error,
+	error) {
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: id}}},
+		{Key: "operationType", Value: snapshotOperationType},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: dbName}, {Key: "coll", Value: collName}}},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: doc.Lookup("_id")}}},
+		{Key: "fullDocument", Value: doc},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal mongo snapshot event to bson: %v", err)
+	}
+	json, err := bson.MarshalExtJSON(bson.Raw(raw), false, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not marshal mongo snapshot event from bson: %v", err)
+	}
+
+	event := newChangeEvent(raw, json)
+	var subjErr error
+	event.Subj, subjErr = eventSubject(opts, event, raw)
+	return event, subjErr, nil
+}
+
+// extJsonId formats the given _id as a canonical extended json document, e.g. '{"_id":{"$numberInt":"42"}}'.
+func extJsonId(id bson.RawValue) (string, error) {
+	json, err := bson.MarshalExtJSON(bson.D{{Key: "_id", Value: id}}, true, false)
+	if err != nil {
+		return "", err
+	}
+	return string(json), nil
+}
@@ -0,0 +1,136 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSnapshotPosition(t *testing.T) {
+	oid := primitive.NewObjectID()
+	_, oidValue, _ := bson.MarshalValue(oid)
+
+	tests := []struct {
+		name string
+		pos  *snapshotPosition
+	}{
+		{
+			name: "should format and parse a position without scanned documents",
+			pos:  &snapshotPosition{opTime: primitive.Timestamp{T: 1683637178, I: 1}},
+		},
+		{
+			name: "should format and parse a position with an object id",
+			pos: &snapshotPosition{
+				opTime: primitive.Timestamp{T: 1683637178, I: 1},
+				lastId: bson.RawValue{Type: bsontype.ObjectID, Value: oidValue},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.pos.String()
+
+			got, ok := parseSnapshotPosition(token)
+
+			require.True(t, ok)
+			require.Equal(t, tt.pos.opTime, got.opTime)
+			require.True(t, tt.pos.lastId.Equal(got.lastId))
+		})
+	}
+
+	t.Run("should not parse a regular resume token", func(t *testing.T) {
+		_, ok := parseSnapshotPosition("82645A43BA000000012B022C0100296E5A1004")
+
+		require.False(t, ok)
+	})
+	t.Run("should format the last id as extended json", func(t *testing.T) {
+		_, value, _ := bson.MarshalValue(int32(42))
+		pos := &snapshotPosition{
+			opTime: primitive.Timestamp{T: 1683637178, I: 1},
+			lastId: bson.RawValue{Type: bsontype.Int32, Value: value},
+		}
+
+		require.Equal(t, `snapshot:1683637178.1:{"_id":{"$numberInt":"42"}}`, pos.String())
+	})
+}
+
+func Test_newSnapshotEvent(t *testing.T) {
+	doc, err := bson.Marshal(bson.D{{Key: "_id", Value: int32(42)}, {Key: "name", Value: "test"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		opts     *WatchCollectionOptions
+		wantSubj string
+	}{
+		{
+			name:     "should publish the snapshot event of a watched collection on the snapshot subject",
+			opts:     &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1", StreamName: "COLL1"},
+			wantSubj: "COLL1.snapshot",
+		},
+		{
+			name: "should include the collection in the subject, if a database is watched",
+			opts: &WatchCollectionOptions{WatchedDbName: "test-db", StreamName: "TEST-DB",
+				TokenStore: &collTokenStore{}},
+			wantSubj: "TEST-DB.coll1.snapshot",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, subjErr, err := newSnapshotEvent(tt.opts, "snap-1:42", "test-db", "coll1", doc)
+
+			require.NoError(t, err)
+			require.NoError(t, subjErr)
+			require.Equal(t, tt.wantSubj, event.Subj)
+			require.Equal(t, "snap-1:42", event.Id)
+			require.Equal(t, "snapshot", event.OperationType)
+			require.Equal(t, "test-db", event.DbName)
+			require.Equal(t, "coll1", event.CollName)
+			require.Equal(t, `{"_id":42}`, event.DocumentKey)
+			require.JSONEq(t, `{"_id":{"_data":"snap-1:42"},"operationType":"snapshot",
+				"ns":{"db":"test-db","coll":"coll1"},"documentKey":{"_id":42},"fullDocument":{"_id":42,"name":"test"}}`,
+				string(event.Data))
+		})
+	}
+}
+
+func TestDefaultClient_handleSnapshotEvent(t *testing.T) {
+	t.Run("should checkpoint the snapshot positions like resume tokens", func(t *testing.T) {
+		var published []string
+		c := &DefaultClient{logger: slog.Default()}
+		tokenStore := &testTokenStore{}
+		w := &watcher{
+			opts: &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1", StreamName: "COLL1",
+				CheckpointEvery: 3,
+				ChangeEventHandler: func(_ context.Context, event *ChangeEvent) error {
+					published = append(published, event.Id)
+					return nil
+				}},
+			tokenStore: tokenStore,
+			namespace:  "test-db.coll1",
+		}
+		pos := &snapshotPosition{opTime: primitive.Timestamp{T: 1683637178, I: 1}}
+		handle := func(id int32) {
+			doc, err := bson.Marshal(bson.D{{Key: "_id", Value: id}})
+			require.NoError(t, err)
+			pos.lastId = bson.Raw(doc).Lookup("_id")
+			require.NoError(t, c.handleSnapshotEvent(context.Background(), w, pos, doc))
+		}
+
+		handle(1)
+		handle(2)
+		require.Empty(t, tokenStore.token)
+		handle(3)
+		require.Equal(t, `snapshot:1683637178.1:{"_id":{"$numberInt":"3"}}`, tokenStore.token)
+		handle(4)
+		require.Equal(t, `snapshot:1683637178.1:{"_id":{"$numberInt":"3"}}`, tokenStore.token)
+		require.NoError(t, c.flushCheckpoint(context.Background(), w))
+		require.Equal(t, `snapshot:1683637178.1:{"_id":{"$numberInt":"4"}}`, tokenStore.token)
+		require.Len(t, published, 4)
+	})
+}
@@ -0,0 +1,141 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// StartAtLatest starts the change stream at the current operation time.
+	StartAtLatest = "latest"
+	// StartAtEarliest starts the change stream at the earliest operation still available in the oplog.
+	StartAtEarliest = "earliest-available-oplog"
+)
+
+// resumeTokenRegex matches the '_data' of a resume token, a hex string starting with the cluster time of its change
+// event: the 82 type byte of a timestamp, followed by its 8 bytes, so that a mistyped timestamp is not taken for one.
+var resumeTokenRegex = regexp.MustCompile(`^82(?:[0-9A-Fa-f]{2}){8,}$`)
+
+// StartAt represents where a change stream starts if no resume token is stored, or if the stored ones are reset.
+type StartAt struct {
+	earliest      bool
+	operationTime *primitive.Timestamp
+	token         string
+}
+
+// Earliest returns whether the change stream starts at the earliest operation still available in the oplog.
+func (s *StartAt) Earliest() bool {
+	return s != nil && s.earliest
+}
+
+// ParseStartAt parses a start position: StartAtLatest, StartAtEarliest, an RFC 3339 timestamp, which is mapped to
+// the operation time of that second, or the '_data' of a resume token, which the change stream starts after.
+func ParseStartAt(startAt string) (*StartAt, error) {
+	switch startAt {
+	case StartAtLatest:
+		return &StartAt{}, nil
+	case StartAtEarliest:
+		return &StartAt{earliest: true}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, startAt); err == nil {
+		if t.Unix() < 0 || t.Unix() > int64(^uint32(0)) {
+			return nil, fmt.Errorf("timestamp out of range: %v", startAt)
+		}
+		return &StartAt{operationTime: &primitive.Timestamp{T: uint32(t.Unix())}}, nil
+	}
+	if resumeTokenRegex.MatchString(startAt) {
+		return &StartAt{token: startAt}, nil
+	}
+	return nil, fmt.Errorf("neither an RFC 3339 timestamp nor a resume token: %v", startAt)
+}
+
+// applyStartAt sets where the change stream starts according to the StartAt option, if no resume token is stored or
+// the stored ones are reset, and no snapshot is due. The position is kept until the first change event is processed.
+func (c *DefaultClient) applyStartAt(ctx context.Context, w *watcher) error {
+	if w.snapshot != nil {
+		// the change stream starts where the snapshot was taken
+		return nil
+	}
+	startAt := w.opts.StartAt
+	if !w.opts.ResetTokens {
+		if startAt == nil {
+			return nil
+		}
+		lastToken, err := w.tokenStore.LastToken(ctx)
+		if err != nil {
+			return err
+		}
+		if lastToken != "" {
+			return nil
+		}
+	}
+	if startAt == nil {
+		startAt = &StartAt{}
+	}
+
+	logAttrs := []any{"dbName", w.opts.WatchedDbName, "collName", w.opts.WatchedCollName,
+		"resetTokens", w.opts.ResetTokens}
+	switch {
+	case startAt.token != "":
+		w.startAfter = startAt.token
+	case startAt.operationTime != nil:
+		w.startAt = startAt.operationTime
+	case startAt.earliest:
+		opTime, err := c.earliestOplogTime(ctx)
+		if err != nil {
+			return err
+		}
+		w.startAt = &opTime
+	case w.opts.ResetTokens:
+		// the stored resume tokens must not be used, start from now
+		opTime, err := c.operationTime(ctx)
+		if err != nil {
+			return err
+		}
+		w.startAt = &opTime
+	default:
+		return nil
+	}
+	c.logger.Info("overriding change stream start position", append(logAttrs,
+		"startAt", w.startAt, "startAfter", w.startAfter)...)
+	return nil
+}
+
+// operationTime returns the current operation time of the deployment.
+func (c *DefaultClient) operationTime(ctx context.Context) (primitive.Timestamp, error) {
+	reply, err := c.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Raw()
+	if err != nil {
+		return primitive.Timestamp{}, fmt.Errorf("could not capture mongo operation time: %v", err)
+	}
+	t, i, ok := reply.Lookup("operationTime").TimestampOK()
+	if !ok {
+		// fall back to the time of the last write on the replica set member
+		if t, i, ok = reply.Lookup("lastWrite", "opTime", "ts").TimestampOK(); !ok {
+			return primitive.Timestamp{}, fmt.Errorf("could not capture mongo operation time: missing from reply")
+		}
+	}
+	return primitive.Timestamp{T: t, I: i}, nil
+}
+
+// earliestOplogTime returns the operation time of the oldest entry of the oplog, which requires read access to the
+// local database of a replica set member.
+func (c *DefaultClient) earliestOplogTime(ctx context.Context) (primitive.Timestamp, error) {
+	findOneOpts := options.FindOne().
+		SetSort(bson.D{{Key: "$natural", Value: 1}}).
+		SetProjection(bson.D{{Key: "ts", Value: 1}})
+	entry, err := c.client.Database("local").Collection("oplog.rs").FindOne(ctx, bson.D{}, findOneOpts).Raw()
+	if err != nil {
+		return primitive.Timestamp{}, fmt.Errorf("could not fetch earliest oplog entry: %v", err)
+	}
+	t, i, ok := entry.Lookup("ts").TimestampOK()
+	if !ok {
+		return primitive.Timestamp{}, fmt.Errorf("could not fetch earliest oplog entry: missing timestamp")
+	}
+	return primitive.Timestamp{T: t, I: i}, nil
+}
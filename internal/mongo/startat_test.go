@@ -0,0 +1,133 @@
+package mongo
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseStartAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		startAt string
+		want    *StartAt
+		wantErr bool
+	}{
+		{
+			name:    "should parse latest",
+			startAt: "latest",
+			want:    &StartAt{},
+		},
+		{
+			name:    "should parse earliest available oplog",
+			startAt: "earliest-available-oplog",
+			want:    &StartAt{earliest: true},
+		},
+		{
+			name:    "should map timestamp to operation time",
+			startAt: "2023-05-09T12:59:38Z",
+			want:    &StartAt{operationTime: &primitive.Timestamp{T: 1683637178}},
+		},
+		{
+			name:    "should parse resume token",
+			startAt: "82645A43BA000000012B022C0100296E5A1004",
+			want:    &StartAt{token: "82645A43BA000000012B022C0100296E5A1004"},
+		},
+		{
+			name:    "should return error, if timestamp is out of range",
+			startAt: "1969-12-31T23:59:59Z",
+			wantErr: true,
+		},
+		{
+			name:    "should return error, if neither a timestamp nor a resume token",
+			startAt: "yesterday",
+			wantErr: true,
+		},
+		{
+			name:    "should return error, if a mistyped timestamp looks like hex",
+			startAt: "2024",
+			wantErr: true,
+		},
+		{
+			name:    "should return error, if a resume token does not start with its cluster time",
+			startAt: "645A43BA000000012B022C0100296E5A1004",
+			wantErr: true,
+		},
+		{
+			name:    "should return error, if a resume token is truncated",
+			startAt: "82645A43BA0000000",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStartAt(tt.startAt)
+
+			require.Equal(t, tt.wantErr, err != nil)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDefaultClient_applyStartAt(t *testing.T) {
+	opTime := &primitive.Timestamp{T: 1683637178}
+
+	tests := []struct {
+		name           string
+		startAt        *StartAt
+		resetTokens    bool
+		storedToken    string
+		snapshot       *snapshotPosition
+		wantStartAt    *primitive.Timestamp
+		wantStartAfter string
+	}{
+		{
+			name:        "should start at operation time, if no token is stored",
+			startAt:     &StartAt{operationTime: opTime},
+			wantStartAt: opTime,
+		},
+		{
+			name:           "should start after resume token, if no token is stored",
+			startAt:        &StartAt{token: "token"},
+			wantStartAfter: "token",
+		},
+		{
+			name:        "should resume after stored token",
+			startAt:     &StartAt{operationTime: opTime},
+			storedToken: "stored-token",
+		},
+		{
+			name:        "should start at operation time, if stored tokens are reset",
+			startAt:     &StartAt{operationTime: opTime},
+			resetTokens: true,
+			storedToken: "stored-token",
+			wantStartAt: opTime,
+		},
+		{
+			name:        "should start where the snapshot was taken",
+			startAt:     &StartAt{operationTime: opTime},
+			resetTokens: true,
+			snapshot:    &snapshotPosition{opTime: primitive.Timestamp{T: 1683637200}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &DefaultClient{logger: slog.Default()}
+			w := &watcher{
+				opts: &WatchCollectionOptions{WatchedDbName: "test-db", WatchedCollName: "coll1",
+					StartAt: tt.startAt, ResetTokens: tt.resetTokens},
+				tokenStore: &testTokenStore{token: tt.storedToken},
+				snapshot:   tt.snapshot,
+			}
+
+			err := c.applyStartAt(context.Background(), w)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.wantStartAt, w.startAt)
+			require.Equal(t, tt.wantStartAfter, w.startAfter)
+		})
+	}
+}
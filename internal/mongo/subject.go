@@ -0,0 +1,167 @@
+package mongo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// SubjectTemplate renders the subject of each change event from a text/template, for example:
+//
+//	{{.Stream}}.{{.Db}}.{{.Coll}}.{{.OperationType}}.{{.FullDocument.tenantId}}
+//
+// The values available to the template are sanitised, so that they always fit in a single subject token.
+type SubjectTemplate struct {
+	tmpl *template.Template
+	// paths contains the full document fields referenced by the template, e.g. [a b] for .FullDocument.a.b
+	paths [][]string
+}
+
+type subjectTemplateData struct {
+	Stream        string
+	Db            string
+	Coll          string
+	OperationType string
+	FullDocument  map[string]any
+}
+
+// NewSubjectTemplate parses the given subject template.
+func NewSubjectTemplate(text string) (*SubjectTemplate, error) {
+	tmpl, err := template.New("subject").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse subject template: %v", err)
+	}
+	t := &SubjectTemplate{tmpl: tmpl}
+	t.collectPaths(tmpl.Root)
+	return t, nil
+}
+
+// collectPaths collects the full document fields referenced by the given template node. Fields referenced within
+// 'with' and 'range' blocks are skipped, as they are relative to a different dot.
+func (t *SubjectTemplate) collectPaths(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			t.collectPaths(child)
+		}
+	case *parse.ActionNode:
+		t.collectPaths(n.Pipe)
+	case *parse.IfNode:
+		t.collectPaths(n.Pipe)
+		t.collectPaths(n.List)
+		t.collectPaths(n.ElseList)
+	case *parse.WithNode:
+		t.collectPaths(n.Pipe)
+	case *parse.RangeNode:
+		t.collectPaths(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				t.collectPaths(arg)
+			}
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 2 && n.Ident[0] == "FullDocument" {
+			t.paths = append(t.paths, n.Ident[1:len(n.Ident)-1])
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 3 && n.Ident[0] == "$" && n.Ident[1] == "FullDocument" {
+			t.paths = append(t.paths, n.Ident[2:len(n.Ident)-1])
+		}
+	}
+}
+
+// Execute renders the subject of the given change event, which is published on the given stream.
+func (t *SubjectTemplate) Execute(streamName string, changeEvent bson.Raw) (string, error) {
+	data := &subjectTemplateData{Stream: streamName, FullDocument: map[string]any{}}
+	if changeEvent != nil {
+		data.Db = subjectToken(changeEvent.Lookup("ns", "db").StringValue())
+		data.Coll = subjectToken(changeEvent.Lookup("ns", "coll").StringValue())
+		data.OperationType = subjectToken(changeEvent.Lookup("operationType").StringValue())
+		if fullDocument, ok := changeEvent.Lookup("fullDocument").DocumentOK(); ok {
+			data.FullDocument = templateDocument(fullDocument)
+		}
+	}
+
+	// nested fields missing from the full document would make the template execution fail, replace them with empty
+	// documents so that they render as missing values instead
+	for _, path := range t.paths {
+		doc := data.FullDocument
+		for _, key := range path {
+			next, ok := doc[key].(map[string]any)
+			if !ok {
+				if doc[key] != nil {
+					break // not a document, let the template execution report the error
+				}
+				next = map[string]any{}
+				doc[key] = next
+			}
+			doc = next
+		}
+	}
+
+	sb := &strings.Builder{}
+	if err := t.tmpl.Execute(sb, data); err != nil {
+		return "", fmt.Errorf("could not execute subject template: %v", err)
+	}
+
+	// missing values and empty tokens would result in an invalid subject
+	tokens := strings.Split(sb.String(), ".")
+	for i, token := range tokens {
+		if token == "" || token == "<no value>" {
+			tokens[i] = "_"
+		} else {
+			tokens[i] = subjectToken(token)
+		}
+	}
+	return strings.Join(tokens, "."), nil
+}
+
+func templateDocument(doc bson.Raw) map[string]any {
+	elements, _ := doc.Elements()
+	values := make(map[string]any, len(elements))
+	for _, element := range elements {
+		values[element.Key()] = templateValue(element.Value())
+	}
+	return values
+}
+
+func templateValue(value bson.RawValue) any {
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		return templateDocument(value.Document())
+	case bsontype.String:
+		return subjectToken(value.StringValue())
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case bsontype.Null, bsontype.Undefined:
+		return nil
+	default:
+		return subjectToken(value.String())
+	}
+}
+
+// subjectToken replaces the characters that are not allowed in a NATS subject token with underscores.
+func subjectToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
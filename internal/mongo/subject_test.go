@@ -0,0 +1,79 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSubjectTemplate_Execute(t *testing.T) {
+	oid := primitive.NewObjectID()
+	changeEvent, _ := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "insert"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "orders.eu"}}},
+		{Key: "fullDocument", Value: bson.D{
+			{Key: "_id", Value: oid},
+			{Key: "tenantId", Value: "acme corp"},
+			{Key: "count", Value: int32(42)},
+			{Key: "customer", Value: bson.D{{Key: "country", Value: "IT"}}},
+		}},
+	})
+	deleteEvent, _ := bson.Marshal(bson.D{
+		{Key: "operationType", Value: "delete"},
+		{Key: "ns", Value: bson.D{{Key: "db", Value: "test-db"}, {Key: "coll", Value: "orders"}}},
+	})
+
+	tests := []struct {
+		name        string
+		text        string
+		changeEvent bson.Raw
+		want        string
+	}{
+		{
+			name:        "should render namespace, operation type and full document fields",
+			text:        "{{.Stream}}.{{.Db}}.{{.Coll}}.{{.OperationType}}.{{.FullDocument.tenantId}}",
+			changeEvent: changeEvent,
+			want:        "ORDERS.test-db.orders_eu.insert.acme_corp",
+		},
+		{
+			name:        "should render non string and nested full document fields",
+			text:        "{{.Stream}}.{{.FullDocument._id}}.{{.FullDocument.count}}.{{.FullDocument.customer.country}}",
+			changeEvent: changeEvent,
+			want:        "ORDERS." + oid.Hex() + ".42.IT",
+		},
+		{
+			name:        "should render missing fields as underscores",
+			text:        "{{.Stream}}.{{.OperationType}}.{{.FullDocument.tenantId}}.{{.FullDocument.customer.country}}",
+			changeEvent: deleteEvent,
+			want:        "ORDERS.delete._._",
+		},
+		{
+			name:        "should render missing fields with the given default",
+			text:        `{{.Stream}}.{{.OperationType}}.{{or .FullDocument.tenantId "none"}}`,
+			changeEvent: deleteEvent,
+			want:        "ORDERS.delete.none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewSubjectTemplate(tt.text)
+			require.NoError(t, err)
+
+			got, err := tmpl.Execute("ORDERS", tt.changeEvent)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewSubjectTemplate(t *testing.T) {
+	t.Run("should return error cause template cannot be parsed", func(t *testing.T) {
+		tmpl, err := NewSubjectTemplate("{{.Stream")
+
+		require.Nil(t, tmpl)
+		require.Error(t, err)
+	})
+}
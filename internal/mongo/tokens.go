@@ -0,0 +1,119 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenStore persists the resume tokens of a change stream, so that it can be resumed after an interruption.
+type TokenStore interface {
+	// LastToken returns the last stored resume token, or an empty string if no token was stored yet.
+	LastToken(ctx context.Context) (string, error)
+	// StoreToken stores the given resume token.
+	StoreToken(ctx context.Context, token string) error
+}
+
+var (
+	_ TokenStore  = &collTokenStore{}
+	_ RenameStore = &collTokenStore{}
+)
+
+// collTokenStore stores resume tokens in a MongoDB collection, in a single document upserted under the given id, along
+// with the followed rename. Capped collections cannot hold documents growing in size, one document per token is
+// inserted in them instead, carrying the followed rename, so that it is not lost once the oldest documents are removed.
+type collTokenStore struct {
+	coll   *mongo.Collection
+	id     string
+	capped bool
+	rename string // the followed rename, carried by the tokens inserted in a capped collection
+}
+
+func (s *collTokenStore) LastToken(ctx context.Context) (string, error) {
+	if s.capped {
+		// use natural sort for capped collections to get the last inserted resume token
+		return s.lastInsertedToken(ctx, bson.D{{Key: "$natural", Value: -1}})
+	}
+
+	lastResumeToken := &resumeToken{}
+	err := s.coll.FindOne(ctx, bson.D{{Key: "_id", Value: s.id}}).Decode(lastResumeToken)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// the resume tokens may have been inserted one per document by a previous version, their ids are object ids
+		// sorted by insertion time
+		return s.lastInsertedToken(ctx, bson.D{{Key: "_id", Value: -1}})
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not fetch or decode resume token: %v", err)
+	}
+	return lastResumeToken.Value, nil
+}
+
+func (s *collTokenStore) lastInsertedToken(ctx context.Context, sort bson.D) (string, error) {
+	lastResumeToken := &resumeToken{}
+	filter := bson.D{{Key: "value", Value: bson.D{{Key: "$exists", Value: true}}}}
+	err := s.coll.FindOne(ctx, filter, options.FindOne().SetSort(sort)).Decode(lastResumeToken)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("could not fetch or decode resume token: %v", err)
+	}
+	return lastResumeToken.Value, nil
+}
+
+func (s *collTokenStore) StoreToken(ctx context.Context, token string) error {
+	if s.capped {
+		if _, err := s.coll.InsertOne(ctx, &resumeToken{Value: token, Rename: s.rename}); err != nil {
+			return fmt.Errorf("could not insert resume token: %v", err)
+		}
+		return nil
+	}
+	if err := s.upsert(ctx, bson.D{{Key: "value", Value: token}}); err != nil {
+		return fmt.Errorf("could not upsert resume token %v: %v", s.id, err)
+	}
+	return nil
+}
+
+func (s *collTokenStore) LastRename(ctx context.Context) (string, error) {
+	lastRename := &resumeToken{}
+	filter, findOpts := bson.D{{Key: "_id", Value: s.id}}, options.FindOne()
+	if s.capped {
+		// the last inserted document carries the followed rename
+		filter = bson.D{}
+		findOpts.SetSort(bson.D{{Key: "$natural", Value: -1}})
+	}
+	err := s.coll.FindOne(ctx, filter, findOpts).Decode(lastRename)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("could not fetch or decode followed rename: %v", err)
+	}
+	s.rename = lastRename.Rename
+	return lastRename.Rename, nil
+}
+
+func (s *collTokenStore) StoreRename(ctx context.Context, rename string) error {
+	if s.capped {
+		// the document is skipped when reading the last resume token, since it has none
+		if _, err := s.coll.InsertOne(ctx, &resumeToken{Rename: rename}); err != nil {
+			return fmt.Errorf("could not insert followed rename: %v", err)
+		}
+		s.rename = rename
+		return nil
+	}
+	if err := s.upsert(ctx, bson.D{{Key: "rename", Value: rename}}); err != nil {
+		return fmt.Errorf("could not upsert followed rename %v: %v", s.id, err)
+	}
+	return nil
+}
+
+// upsert sets the given fields of the document stored under the id of the store, leaving the other fields as they are.
+func (s *collTokenStore) upsert(ctx context.Context, fields bson.D) error {
+	filter := bson.D{{Key: "_id", Value: s.id}}
+	_, err := s.coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: fields}}, options.Update().SetUpsert(true))
+	return err
+}
+
+type resumeToken struct {
+	Value  string `bson:"value,omitempty"`
+	Rename string `bson:"rename,omitempty"`
+}
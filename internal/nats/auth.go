@@ -0,0 +1,80 @@
+package nats
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/damianiandrea/mongodb-nats-connector/internal/secret"
+)
+
+var ErrInvalidAuth = errors.New("invalid nats authentication")
+
+// AuthOptions represents the credentials used to authenticate to NATS. Only one of CredsFile, NkeySeedFile, Token and
+// User can be set. Token and Password can be read from TokenFile and PasswordFile instead, so that they are not part
+// of the configuration.
+type AuthOptions struct {
+	CredsFile    string // the user JWT and NKey seed, for decentralised authentication
+	NkeySeedFile string
+	Token        string
+	TokenFile    string
+	User         string
+	Password     string
+	PasswordFile string
+}
+
+// TLSOptions represents the TLS configuration of the connection to NATS. CAFile verifies the server certificate instead
+// of the system roots, CertFile and KeyFile authenticate the client with mutual TLS, and ServerName overrides the
+// host name expected in the server certificate.
+type TLSOptions struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// natsOptions returns the options setting the tls config. The server name must be set first, as the other options
+// modify the tls config.
+func (t *TLSOptions) natsOptions() []nats.Option {
+	opts := []nats.Option{nats.Secure(&tls.Config{ServerName: t.ServerName, MinVersion: tls.VersionTLS12})}
+	if t.CAFile != "" {
+		opts = append(opts, nats.RootCAs(t.CAFile))
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		opts = append(opts, nats.ClientCert(t.CertFile, t.KeyFile))
+	}
+	return opts
+}
+
+// natsOption returns the option authenticating with the first of the configured methods, or nil if none is set.
+func (a *AuthOptions) natsOption() (nats.Option, error) {
+	switch {
+	case a.CredsFile != "":
+		if _, err := os.Stat(a.CredsFile); err != nil {
+			return nil, fmt.Errorf("%w: could not read creds file: %v", ErrInvalidAuth, err)
+		}
+		return nats.UserCredentials(a.CredsFile), nil
+	case a.NkeySeedFile != "":
+		opt, err := nats.NkeyOptionFromSeed(a.NkeySeedFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not read nkey seed file: %v", ErrInvalidAuth, err)
+		}
+		return opt, nil
+	case a.Token != "" || a.TokenFile != "":
+		token, err := secret.Read(a.Token, a.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not read token file: %v", ErrInvalidAuth, err)
+		}
+		return nats.Token(token), nil
+	case a.User != "":
+		password, err := secret.Read(a.Password, a.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: could not read password file: %v", ErrInvalidAuth, err)
+		}
+		return nats.UserInfo(a.User, password), nil
+	}
+	return nil, nil
+}
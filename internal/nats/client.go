@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -15,10 +17,33 @@ import (
 
 const (
 	defaultName = "nats"
+	// publishAsyncTimeout is the time to wait for the acknowledgement of a message published asynchronously, the same
+	// as the default timeout of synchronous publications.
+	publishAsyncTimeout = 5 * time.Second
+)
+
+// Headers of the messages published for MongoDB change events.
+const (
+	ResumeTokenHdr   = "Mongo-Resume-Token"
+	OperationTypeHdr = "Mongo-Operation-Type"
+	DbHdr            = "Mongo-Db"
+	CollHdr          = "Mongo-Coll"
+	DocumentKeyHdr   = "Mongo-Document-Key"
+	ClusterTimeHdr   = "Mongo-Cluster-Time"
+	WallTimeHdr      = "Mongo-Wall-Time"
+)
+
+// Headers of the messages published for MongoDB change events that could not be published on their own subject.
+const (
+	DeadLetterSubjectHdr  = "Mongo-Dead-Letter-Subject"
+	DeadLetterErrorHdr    = "Mongo-Dead-Letter-Error"
+	DeadLetterAttemptsHdr = "Mongo-Dead-Letter-Attempts"
 )
 
 var (
-	ErrClientDisconnected = errors.New("could not reach nats: connection closed")
+	ErrClientDisconnected       = errors.New("could not reach nats: connection closed")
+	ErrIncompatibleStreamConfig = errors.New("incompatible nats stream config")
+	ErrMaxPayload               = nats.ErrMaxPayload
 )
 
 type Client interface {
@@ -27,62 +52,81 @@ type Client interface {
 
 	AddStream(ctx context.Context, opts *AddStreamOptions) error
 	Publish(ctx context.Context, opts *PublishOptions) error
+	PublishAsync(ctx context.Context, opts *PublishOptions) (<-chan error, error)
+	CreateTokenStore(ctx context.Context, opts *CreateTokenStoreOptions) (TokenStore, error)
+	CreateStreamTokenStore(ctx context.Context, opts *CreateStreamTokenStoreOptions) (TokenStore, error)
 }
 
+// AddStreamOptions represents the configuration of a stream.
+// Zero values fall back to the NATS server defaults, except for Storage which defaults to file storage, and Subjects
+// which defaults to all the subjects one token deep under the stream name, e.g. 'STREAM.*'.
 type AddStreamOptions struct {
+	StreamName      string
+	Subjects        []string
+	Storage         string // 'file' or 'memory'
+	Retention       string // 'limits', 'interest' or 'workqueue'
+	Replicas        int
+	MaxAge          time.Duration
+	MaxBytes        int64
+	MaxMsgs         int64
+	Discard         string // 'old' or 'new'
+	DuplicateWindow time.Duration
+}
+
+type CreateTokenStoreOptions struct {
+	BucketName string
+	Key        string
+}
+
+type CreateStreamTokenStoreOptions struct {
 	StreamName string
+	Subject    string
 }
 
 type PublishOptions struct {
 	Subj  string
 	MsgId string
 	Data  []byte
+	// ResumeToken is set as the ResumeTokenHdr header of the message, if not empty.
+	ResumeToken string
+	// Headers are set as the headers of the message.
+	Headers map[string]string
 }
 
 var _ Client = &DefaultClient{}
 
 type DefaultClient struct {
-	url    string
-	name   string
-	logger *slog.Logger
+	url        string
+	name       string
+	logger     *slog.Logger
+	auth       *AuthOptions
+	tls        *TLSOptions
+	connection *ConnectionOptions
 
 	onMsgPublishedEvent func(subj string, duration time.Duration)
 	onMsgFailedEvent    func(subj string, duration time.Duration)
 
-	conn *nats.Conn
-	js   nats.JetStreamContext
+	mu     sync.RWMutex
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	closed bool
+	done   chan struct{} // closed once the client is closed, to stop re-creating the connection
 }
 
 func NewDefaultClient(opts ...ClientOption) (*DefaultClient, error) {
 	c := &DefaultClient{
 		name:   defaultName,
 		logger: slog.Default(),
+		done:   make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
 
-	conn, err := nats.Connect(c.url,
-		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
-			c.logger.Error("disconnected from nats", "err", err)
-		}),
-		nats.ReconnectHandler(func(conn *nats.Conn) {
-			c.logger.Info("reconnected to nats", "url", conn.ConnectedUrlRedacted())
-		}),
-		nats.ClosedHandler(func(conn *nats.Conn) {
-			c.logger.Info("nats connection closed")
-		}),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("could not connect to nats: %v", err)
+	if err := c.connect(); err != nil {
+		return nil, err
 	}
-	c.conn = conn
-
-	js, _ := conn.JetStream()
-	c.js = js
-
-	c.logger.Info("connected to nats", "url", conn.ConnectedUrlRedacted())
 	return c, nil
 }
 
@@ -91,45 +135,153 @@ func (c *DefaultClient) Name() string {
 }
 
 func (c *DefaultClient) Monitor(_ context.Context) error {
-	if closed := c.conn.IsClosed(); closed {
+	conn, _ := c.current()
+	if closed := conn.IsClosed(); closed {
 		return ErrClientDisconnected
 	}
 	return nil
 }
 
 func (c *DefaultClient) Close() error {
-	c.conn.Close()
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	conn := c.conn
+	c.mu.Unlock()
+	conn.Close()
 	return nil
 }
 
+// AddStream creates the stream if it does not already exist, otherwise it updates it if its configuration differs from
+// the given one. Changing the storage type or the retention policy of an existing stream is not supported.
 func (c *DefaultClient) AddStream(ctx context.Context, opts *AddStreamOptions) error {
-	addStreamCfg := &nats.StreamConfig{
-		Name:     opts.StreamName,
-		Subjects: []string{fmt.Sprintf("%s.*", opts.StreamName)},
-		Storage:  nats.FileStorage,
+	_, js := c.current()
+	info, err := js.StreamInfo(opts.StreamName, nats.Context(ctx))
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		if _, err = js.AddStream(streamConfig(&nats.StreamConfig{}, opts), nats.Context(ctx)); err != nil {
+			return fmt.Errorf("could not add nats stream %v: %v", opts.StreamName, err)
+		}
+		c.logger.Debug("added nats stream", "streamName", opts.StreamName)
+		return nil
 	}
-	_, err := c.js.AddStream(addStreamCfg, nats.Context(ctx))
 	if err != nil {
-		return fmt.Errorf("could not add nats stream %v: %v", opts.StreamName, err)
+		return fmt.Errorf("could not get nats stream %v: %v", opts.StreamName, err)
 	}
 
-	c.logger.Debug("added nats stream", "streamName", opts.StreamName)
+	current := info.Config
+	desired := streamConfig(&info.Config, opts)
+	if desired.Storage != current.Storage {
+		return fmt.Errorf("%w: cannot change storage of nats stream %v from %v to %v",
+			ErrIncompatibleStreamConfig, opts.StreamName, current.Storage, desired.Storage)
+	}
+	if desired.Retention != current.Retention {
+		return fmt.Errorf("%w: cannot change retention of nats stream %v from %v to %v",
+			ErrIncompatibleStreamConfig, opts.StreamName, current.Retention, desired.Retention)
+	}
+	if reflect.DeepEqual(current, *desired) {
+		return nil
+	}
+	if _, err = js.UpdateStream(desired, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("could not update nats stream %v: %v", opts.StreamName, err)
+	}
+
+	c.logger.Info("updated nats stream", "streamName", opts.StreamName)
 	return nil
 }
 
+// streamConfig returns a copy of the given stream config, with the configurable fields overridden by the given options.
+func streamConfig(base *nats.StreamConfig, opts *AddStreamOptions) *nats.StreamConfig {
+	cfg := *base
+	cfg.Name = opts.StreamName
+	cfg.Subjects = opts.Subjects
+	if len(cfg.Subjects) == 0 {
+		cfg.Subjects = []string{fmt.Sprintf("%s.*", opts.StreamName)}
+	}
+	cfg.Storage = nats.FileStorage
+	if opts.Storage == "memory" {
+		cfg.Storage = nats.MemoryStorage
+	}
+	switch opts.Retention {
+	case "interest":
+		cfg.Retention = nats.InterestPolicy
+	case "workqueue":
+		cfg.Retention = nats.WorkQueuePolicy
+	default:
+		cfg.Retention = nats.LimitsPolicy
+	}
+	cfg.Discard = nats.DiscardOld
+	if opts.Discard == "new" {
+		cfg.Discard = nats.DiscardNew
+	}
+	cfg.Replicas = max(opts.Replicas, 1)
+	cfg.MaxAge = opts.MaxAge
+	cfg.MaxBytes = -1
+	if opts.MaxBytes > 0 {
+		cfg.MaxBytes = opts.MaxBytes
+	}
+	cfg.MaxMsgs = -1
+	if opts.MaxMsgs > 0 {
+		cfg.MaxMsgs = opts.MaxMsgs
+	}
+	// the server computes the default duplicate window, keep it unless explicitly set
+	if opts.DuplicateWindow > 0 {
+		cfg.Duplicates = opts.DuplicateWindow
+	}
+	return &cfg
+}
+
 func (c *DefaultClient) Publish(ctx context.Context, opts *PublishOptions) error {
+	_, js := c.current()
 	start := time.Now()
-	_, err := c.js.Publish(opts.Subj, opts.Data,
+	_, err := js.PublishMsg(newMsg(opts),
 		nats.Context(ctx),
 		nats.MsgId(opts.MsgId),
 	)
+	return c.published(opts, time.Since(start), err)
+}
+
+// PublishAsync publishes the message without waiting for its acknowledgement. The returned channel receives nil once the
+// message is acknowledged, or the error that prevented it, at the latest after publishAsyncTimeout.
+func (c *DefaultClient) PublishAsync(_ context.Context, opts *PublishOptions) (<-chan error, error) {
+	_, js := c.current()
+	start := time.Now()
+	future, err := js.PublishMsgAsync(newMsg(opts), nats.MsgId(opts.MsgId))
+	if err != nil {
+		return nil, c.published(opts, time.Since(start), err)
+	}
+	ack := make(chan error, 1) // never blocks, the acknowledgement may be discarded
+	go func() {
+		select {
+		case <-future.Ok():
+			ack <- c.published(opts, time.Since(start), nil)
+		case err := <-future.Err():
+			ack <- c.published(opts, time.Since(start), err)
+		}
+	}()
+	return ack, nil
+}
+
+func newMsg(opts *PublishOptions) *nats.Msg {
+	msg := nats.NewMsg(opts.Subj)
+	msg.Data = opts.Data
+	for key, value := range opts.Headers {
+		msg.Header.Set(key, value)
+	}
+	if opts.ResumeToken != "" {
+		msg.Header.Set(ResumeTokenHdr, opts.ResumeToken)
+	}
+	return msg
+}
 
-	duration := time.Since(start)
+// published reports the outcome of the publication of a message, and returns the error that prevented it, if any.
+func (c *DefaultClient) published(opts *PublishOptions, duration time.Duration, err error) error {
 	if err != nil {
 		if c.onMsgFailedEvent != nil {
 			c.onMsgFailedEvent(opts.Subj, duration)
 		}
-		return fmt.Errorf("could not publish message %v to nats stream %v: %v", opts.Data, opts.Subj, err)
+		return fmt.Errorf("could not publish message %v to nats stream %v: %w", opts.MsgId, opts.Subj, err)
 	}
 
 	c.logger.Debug("published message", "subj", opts.Subj, "data", string(opts.Data))
@@ -139,6 +291,32 @@ func (c *DefaultClient) Publish(ctx context.Context, opts *PublishOptions) error
 	return nil
 }
 
+// CreateTokenStore returns a TokenStore backed by the given key-value bucket, creating the bucket if it does not
+// already exist.
+func (c *DefaultClient) CreateTokenStore(_ context.Context, opts *CreateTokenStoreOptions) (TokenStore, error) {
+	_, js := c.current()
+	kv, err := js.KeyValue(opts.BucketName)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket:  opts.BucketName,
+			Storage: nats.FileStorage,
+		})
+		if err == nil {
+			c.logger.Debug("created nats key-value bucket", "bucketName", opts.BucketName)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get nats key-value bucket %v: %v", opts.BucketName, err)
+	}
+	return &kvTokenStore{client: c, bucketName: opts.BucketName, key: kvKey(opts.Key), js: js, kv: kv}, nil
+}
+
+// CreateStreamTokenStore returns a TokenStore that reads the resume tokens from the last message published on the given
+// stream subject, which may contain wildcards.
+func (c *DefaultClient) CreateStreamTokenStore(_ context.Context, opts *CreateStreamTokenStoreOptions) (TokenStore, error) {
+	return &streamTokenStore{client: c, streamName: opts.StreamName, subject: opts.Subject}, nil
+}
+
 type ClientOption func(*DefaultClient)
 
 func WithNatsUrl(url string) ClientOption {
@@ -157,6 +335,33 @@ func WithLogger(logger *slog.Logger) ClientOption {
 	}
 }
 
+// WithAuth sets the credentials used to authenticate to NATS, in addition to the ones in the url.
+func WithAuth(auth *AuthOptions) ClientOption {
+	return func(c *DefaultClient) {
+		if auth != nil {
+			c.auth = auth
+		}
+	}
+}
+
+// WithTLS enables TLS on the connection to NATS.
+func WithTLS(tls *TLSOptions) ClientOption {
+	return func(c *DefaultClient) {
+		if tls != nil {
+			c.tls = tls
+		}
+	}
+}
+
+// WithConnection sets the tuning of the connection to NATS.
+func WithConnection(connection *ConnectionOptions) ClientOption {
+	return func(c *DefaultClient) {
+		if connection != nil {
+			c.connection = connection
+		}
+	}
+}
+
 func WithEventListeners(listeners ...EventListener) ClientOption {
 	return func(c *DefaultClient) {
 		for _, listener := range listeners {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -48,6 +49,87 @@ func TestNewDefaultClient(t *testing.T) {
 		require.NotNil(t, client.conn)
 		require.NotNil(t, client.js)
 	})
+	t.Run("should create client with the configured connection options", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+
+		client, err := NewDefaultClient(
+			WithNatsUrl("nats://127.0.0.1:4223"), // unreachable, the seed url is used instead
+			WithConnection(&ConnectionOptions{
+				Name:             "mongodb-nats-connector",
+				Servers:          []string{nats.DefaultURL},
+				MaxReconnects:    -5,
+				ReconnectWait:    500 * time.Millisecond,
+				ReconnectJitter:  100 * time.Millisecond,
+				PingInterval:     30 * time.Second,
+				MaxPingsOut:      5,
+				ReconnectBufSize: -1,
+			}),
+		)
+
+		require.NoError(t, err)
+		require.True(t, client.conn.IsConnected())
+		require.Equal(t, "mongodb-nats-connector", client.conn.Opts.Name)
+		require.Equal(t, -1, client.conn.Opts.MaxReconnect)
+		require.Equal(t, 500*time.Millisecond, client.conn.Opts.ReconnectWait)
+		require.Equal(t, 100*time.Millisecond, client.conn.Opts.ReconnectJitter)
+		require.Equal(t, 30*time.Second, client.conn.Opts.PingInterval)
+		require.Equal(t, 5, client.conn.Opts.MaxPingsOut)
+		require.Equal(t, -1, client.conn.Opts.ReconnectBufSize)
+		_ = client.Close()
+	})
+	t.Run("should create client authenticating with the token read from file", func(t *testing.T) {
+		opts := natstest.DefaultTestOptions
+		opts.Authorization = "s3cr3t"
+		s := natstest.RunServer(&opts)
+		defer s.Shutdown()
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600))
+
+		client, err := NewDefaultClient(WithAuth(&AuthOptions{TokenFile: tokenFile}))
+
+		require.NoError(t, err)
+		require.True(t, client.conn.IsConnected())
+		_ = client.Close()
+	})
+	t.Run("should create client authenticating with user and password", func(t *testing.T) {
+		opts := natstest.DefaultTestOptions
+		opts.Username, opts.Password = "connector", "s3cr3t"
+		s := natstest.RunServer(&opts)
+		defer s.Shutdown()
+
+		client, err := NewDefaultClient(WithAuth(&AuthOptions{User: "connector", Password: "s3cr3t"}))
+
+		require.NoError(t, err)
+		require.True(t, client.conn.IsConnected())
+		_ = client.Close()
+	})
+	t.Run("should return error cause the credentials are wrong", func(t *testing.T) {
+		opts := natstest.DefaultTestOptions
+		opts.Username, opts.Password = "connector", "s3cr3t"
+		s := natstest.RunServer(&opts)
+		defer s.Shutdown()
+
+		client, err := NewDefaultClient(WithAuth(&AuthOptions{User: "connector", Password: "wrong"}))
+
+		require.Nil(t, client)
+		require.Error(t, err)
+	})
+	t.Run("should return error cause the password file cannot be read", func(t *testing.T) {
+		client, err := NewDefaultClient(WithAuth(&AuthOptions{
+			User:         "connector",
+			PasswordFile: filepath.Join(t.TempDir(), "missing"),
+		}))
+
+		require.Nil(t, client)
+		require.ErrorIs(t, err, ErrInvalidAuth)
+	})
+	t.Run("should return error cause the ca file cannot be read", func(t *testing.T) {
+		client, err := NewDefaultClient(WithTLS(&TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing")}))
+
+		require.Nil(t, client)
+		require.Error(t, err)
+	})
 	t.Run("should return error cause nats is not available", func(t *testing.T) {
 		client, err := NewDefaultClient()
 
@@ -91,6 +173,34 @@ func TestClient_Monitor(t *testing.T) {
 
 		require.EqualError(t, err, ErrClientDisconnected.Error())
 	})
+	t.Run("should re-create the connection once it is permanently closed", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient(WithConnection(&ConnectionOptions{ReconnectWait: 10 * time.Millisecond}))
+		defer client.Close()
+		closedConn, _ := client.current()
+		closedConn.Close()
+
+		require.Eventually(t, func() bool {
+			return client.Monitor(context.Background()) == nil
+		}, 5*time.Second, 10*time.Millisecond)
+		conn, _ := client.current()
+		require.NotSame(t, closedConn, conn)
+		require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"}))
+	})
+	t.Run("should not re-create the connection once the client is closed", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient(WithConnection(&ConnectionOptions{ReconnectWait: 10 * time.Millisecond}))
+
+		require.NoError(t, client.Close())
+
+		require.Never(t, func() bool {
+			return client.Monitor(context.Background()) == nil
+		}, 100*time.Millisecond, 10*time.Millisecond)
+	})
 }
 
 func TestClient_Close(t *testing.T) {
@@ -123,6 +233,93 @@ func TestClient_AddStream(t *testing.T) {
 		require.Contains(t, stream.Config.Subjects, "TEST.*")
 		require.Equal(t, nats.FileStorage, stream.Config.Storage)
 	})
+	t.Run("should add stream with the given subjects", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{
+			StreamName: "TEST",
+			Subjects:   []string{"TEST.*.*"},
+		})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, []string{"TEST.*.*"}, stream.Config.Subjects)
+	})
+	t.Run("should add stream with the given config", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{
+			StreamName:      "TEST",
+			Storage:         "memory",
+			Retention:       "interest",
+			Replicas:        1,
+			MaxAge:          time.Hour,
+			MaxBytes:        1024 * 1024,
+			MaxMsgs:         1000,
+			Discard:         "new",
+			DuplicateWindow: time.Minute,
+		})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, nats.MemoryStorage, stream.Config.Storage)
+		require.Equal(t, nats.InterestPolicy, stream.Config.Retention)
+		require.Equal(t, 1, stream.Config.Replicas)
+		require.Equal(t, time.Hour, stream.Config.MaxAge)
+		require.Equal(t, int64(1024*1024), stream.Config.MaxBytes)
+		require.Equal(t, int64(1000), stream.Config.MaxMsgs)
+		require.Equal(t, nats.DiscardNew, stream.Config.Discard)
+		require.Equal(t, time.Minute, stream.Config.Duplicates)
+	})
+	t.Run("should update existing stream if its config differs", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"}))
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{
+			StreamName: "TEST",
+			Subjects:   []string{"TEST.*.*"},
+			MaxAge:     time.Hour,
+		})
+
+		require.NoError(t, err)
+		stream, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, []string{"TEST.*.*"}, stream.Config.Subjects)
+		require.Equal(t, time.Hour, stream.Config.MaxAge)
+	})
+	t.Run("should return error cause stream storage cannot be changed", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"}))
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST", Storage: "memory"})
+
+		require.ErrorIs(t, err, ErrIncompatibleStreamConfig)
+	})
+	t.Run("should return error cause stream retention cannot be changed", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		require.NoError(t, client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"}))
+
+		err := client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST", Retention: "workqueue"})
+
+		require.ErrorIs(t, err, ErrIncompatibleStreamConfig)
+	})
 	t.Run("should return error cause nats is not available", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -163,6 +360,27 @@ func TestClient_Publish(t *testing.T) {
 		require.Contains(t, msg.Header[nats.MsgIdHdr], "123")
 		require.Equal(t, []byte("test"), msg.Data)
 	})
+	t.Run("should publish message with the given headers", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"})
+
+		err := client.Publish(context.Background(), &PublishOptions{
+			Subj:    "TEST.insert",
+			MsgId:   "123",
+			Data:    []byte("test"),
+			Headers: map[string]string{OperationTypeHdr: "insert", "Source": "connector"},
+		})
+
+		require.NoError(t, err)
+		msg, err := client.js.GetLastMsg("TEST", "TEST.insert")
+		require.NoError(t, err)
+		require.Equal(t, "insert", msg.Header.Get(OperationTypeHdr))
+		require.Equal(t, "connector", msg.Header.Get("Source"))
+		require.Equal(t, "123", msg.Header.Get(nats.MsgIdHdr))
+	})
 	t.Run("should run hook after publishing the message", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -207,6 +425,21 @@ func TestClient_Publish(t *testing.T) {
 
 		require.Error(t, err)
 	})
+	t.Run("should return error cause message exceeds the max payload", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"})
+
+		err := client.Publish(context.Background(), &PublishOptions{
+			Subj:  "TEST.insert",
+			MsgId: "123",
+			Data:  make([]byte, client.conn.MaxPayload()+1),
+		})
+
+		require.ErrorIs(t, err, ErrMaxPayload)
+	})
 	t.Run("should run hook after message publishing failed", func(t *testing.T) {
 		s := natstest.RunDefaultServer()
 		defer s.Shutdown()
@@ -232,3 +465,135 @@ func TestClient_Publish(t *testing.T) {
 		require.Equal(t, 1, count)
 	})
 }
+
+func TestClient_PublishAsync(t *testing.T) {
+	t.Run("should publish message and acknowledge it asynchronously", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"})
+
+		var acks []<-chan error
+		for _, id := range []string{"1", "2", "3"} {
+			ack, err := client.PublishAsync(context.Background(), &PublishOptions{
+				Subj:        "TEST.insert",
+				MsgId:       id,
+				Data:        []byte("test"),
+				ResumeToken: "token" + id,
+			})
+			require.NoError(t, err)
+			acks = append(acks, ack)
+		}
+
+		for _, ack := range acks {
+			select {
+			case err := <-ack:
+				require.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "message not acknowledged")
+			}
+		}
+		msg, err := client.js.GetLastMsg("TEST", "TEST.insert")
+		require.NoError(t, err)
+		require.Equal(t, "token3", msg.Header.Get(ResumeTokenHdr))
+		info, err := client.js.StreamInfo("TEST")
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), info.State.Msgs)
+	})
+	t.Run("should return error cause nats is not available", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{})
+		client, _ := NewDefaultClient()
+		client.conn.Close()
+
+		ack, err := client.PublishAsync(context.Background(), &PublishOptions{
+			Subj:  "TEST.insert",
+			MsgId: "123",
+			Data:  []byte("test"),
+		})
+
+		require.Nil(t, ack)
+		require.Error(t, err)
+	})
+}
+
+func TestClient_CreateTokenStore(t *testing.T) {
+	t.Run("should create bucket and store the last resume token under the given key", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+
+		store, err := client.CreateTokenStore(context.Background(), &CreateTokenStoreOptions{
+			BucketName: "resume-tokens",
+			Key:        "coll 1",
+		})
+		require.NoError(t, err)
+
+		token, err := store.LastToken(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, token)
+
+		require.NoError(t, store.StoreToken(context.Background(), "token1"))
+		require.NoError(t, store.StoreToken(context.Background(), "token2"))
+
+		token, err = store.LastToken(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token2", token)
+
+		kv, err := client.js.KeyValue("resume-tokens")
+		require.NoError(t, err)
+		entry, err := kv.Get("coll_1")
+		require.NoError(t, err)
+		require.Equal(t, []byte("token2"), entry.Value())
+	})
+	t.Run("should return error cause nats is not available", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		client.conn.Close()
+
+		store, err := client.CreateTokenStore(context.Background(), &CreateTokenStoreOptions{
+			BucketName: "resume-tokens",
+			Key:        "coll1",
+		})
+
+		require.Nil(t, store)
+		require.Error(t, err)
+	})
+}
+
+func TestClient_CreateStreamTokenStore(t *testing.T) {
+	t.Run("should read the resume token of the last message published on the stream", func(t *testing.T) {
+		s := natstest.RunDefaultServer()
+		defer s.Shutdown()
+		_ = s.EnableJetStream(&natsserver.JetStreamConfig{StoreDir: t.TempDir()})
+		client, _ := NewDefaultClient()
+		_ = client.AddStream(context.Background(), &AddStreamOptions{StreamName: "TEST"})
+
+		store, err := client.CreateStreamTokenStore(context.Background(), &CreateStreamTokenStoreOptions{
+			StreamName: "TEST",
+			Subject:    "TEST.*",
+		})
+		require.NoError(t, err)
+
+		token, err := store.LastToken(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, token)
+
+		for _, opts := range []*PublishOptions{
+			{Subj: "TEST.insert", MsgId: "1", Data: []byte("test"), ResumeToken: "token1"},
+			{Subj: "TEST.update", MsgId: "2", Data: []byte("test"), ResumeToken: "token2"},
+		} {
+			require.NoError(t, client.Publish(context.Background(), opts))
+			require.NoError(t, store.StoreToken(context.Background(), opts.ResumeToken))
+		}
+
+		token, err = store.LastToken(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token2", token)
+	})
+}
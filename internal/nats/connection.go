@@ -0,0 +1,159 @@
+package nats
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var errClientClosed = errors.New("nats client closed")
+
+// ConnectionOptions represents the tuning of the connection to NATS.
+// Zero values fall back to the NATS client defaults.
+type ConnectionOptions struct {
+	Name             string   // the connection name reported by the server
+	Servers          []string // the seed urls, tried along with the client url
+	MaxReconnects    int      // negative for infinite reconnect attempts
+	ReconnectWait    time.Duration
+	ReconnectJitter  time.Duration
+	PingInterval     time.Duration
+	MaxPingsOut      int
+	ReconnectBufSize int // negative to disable buffering while reconnecting
+}
+
+// servers returns the comma separated urls of the servers to connect to.
+func (c *DefaultClient) servers() string {
+	var servers []string
+	if c.url != "" {
+		servers = append(servers, c.url)
+	}
+	if c.connection != nil {
+		servers = append(servers, c.connection.Servers...)
+	}
+	return strings.Join(servers, ",")
+}
+
+// natsOptions returns the options used to connect to NATS, reading the secrets from their files.
+func (c *DefaultClient) natsOptions() ([]nats.Option, error) {
+	var opts []nats.Option
+	if conn := c.connection; conn != nil {
+		if conn.Name != "" {
+			opts = append(opts, nats.Name(conn.Name))
+		}
+		if conn.MaxReconnects != 0 {
+			opts = append(opts, nats.MaxReconnects(max(conn.MaxReconnects, -1)))
+		}
+		if conn.ReconnectWait > 0 {
+			opts = append(opts, nats.ReconnectWait(conn.ReconnectWait))
+		}
+		if conn.ReconnectJitter > 0 {
+			opts = append(opts, nats.ReconnectJitter(conn.ReconnectJitter, conn.ReconnectJitter))
+		}
+		if conn.PingInterval > 0 {
+			opts = append(opts, nats.PingInterval(conn.PingInterval))
+		}
+		if conn.MaxPingsOut > 0 {
+			opts = append(opts, nats.MaxPingsOutstanding(conn.MaxPingsOut))
+		}
+		if conn.ReconnectBufSize != 0 {
+			opts = append(opts, nats.ReconnectBufSize(max(conn.ReconnectBufSize, -1)))
+		}
+	}
+	if auth := c.auth; auth != nil {
+		authOpt, err := auth.natsOption()
+		if err != nil {
+			return nil, err
+		}
+		if authOpt != nil {
+			opts = append(opts, authOpt)
+		}
+	}
+	if tlsOpts := c.tls; tlsOpts != nil {
+		opts = append(opts, tlsOpts.natsOptions()...)
+	}
+	return append(opts,
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			c.logger.Error("disconnected from nats", "err", err)
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			c.logger.Info("reconnected to nats", "url", conn.ConnectedUrlRedacted())
+		}),
+		nats.ClosedHandler(func(conn *nats.Conn) {
+			c.logger.Info("nats connection closed")
+			c.connectionClosed(conn)
+		}),
+	), nil
+}
+
+// connect creates a new connection to NATS, which replaces the current one.
+func (c *DefaultClient) connect() error {
+	natsOpts, err := c.natsOptions()
+	if err != nil {
+		return err
+	}
+	conn, err := nats.Connect(c.servers(), natsOpts...)
+	if err != nil {
+		return fmt.Errorf("could not connect to nats: %v", err)
+	}
+	js, _ := conn.JetStream(nats.PublishAsyncTimeout(publishAsyncTimeout))
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return errClientClosed
+	}
+	c.conn, c.js = conn, js
+	c.mu.Unlock()
+
+	c.logger.Info("connected to nats", "url", conn.ConnectedUrlRedacted())
+	return nil
+}
+
+// connectionClosed re-creates the connection once the current one is permanently closed, either because the reconnect
+// attempts were exhausted or because the server closed it, unless the client itself was closed.
+func (c *DefaultClient) connectionClosed(conn *nats.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || conn != c.conn {
+		return
+	}
+	go c.recreate()
+}
+
+// recreate tries to connect to NATS every reconnect wait, until it succeeds or the client is closed.
+func (c *DefaultClient) recreate() {
+	wait := nats.DefaultReconnectWait
+	if c.connection != nil {
+		wait = cmp.Or(max(c.connection.ReconnectWait, 0), wait)
+	}
+	ticker := time.NewTicker(wait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		err := c.connect()
+		if err == nil {
+			c.logger.Info("re-created nats connection")
+			return
+		}
+		if errors.Is(err, errClientClosed) {
+			return
+		}
+		c.logger.Error("could not re-create nats connection", "err", err)
+	}
+}
+
+// current returns the current connection and its JetStream context.
+func (c *DefaultClient) current() (*nats.Conn, nats.JetStreamContext) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn, c.js
+}
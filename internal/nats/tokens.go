@@ -0,0 +1,142 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TokenStore persists the resume tokens of a MongoDB change stream.
+type TokenStore interface {
+	// LastToken returns the last stored resume token, or an empty string if no token was stored yet.
+	LastToken(ctx context.Context) (string, error)
+	// StoreToken stores the given resume token.
+	StoreToken(ctx context.Context, token string) error
+}
+
+var _ TokenStore = &kvTokenStore{}
+
+// kvTokenStore stores resume tokens in a JetStream key-value bucket, only the last token is kept under the given key,
+// and the followed rename under the given key suffixed with renameKeySuffix.
+type kvTokenStore struct {
+	client     *DefaultClient
+	bucketName string
+	key        string
+
+	mu sync.Mutex
+	js nats.JetStreamContext // the context the bucket was bound with
+	kv nats.KeyValue
+}
+
+// keyValue returns the bucket, bound again once the client connection is re-created.
+func (s *kvTokenStore) keyValue() (nats.KeyValue, error) {
+	_, js := s.client.current()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.kv == nil || s.js != js {
+		kv, err := js.KeyValue(s.bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("could not get nats key-value bucket %v: %v", s.bucketName, err)
+		}
+		s.js, s.kv = js, kv
+	}
+	return s.kv, nil
+}
+
+func (s *kvTokenStore) LastToken(_ context.Context) (string, error) {
+	kv, err := s.keyValue()
+	if err != nil {
+		return "", err
+	}
+	entry, err := kv.Get(s.key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not fetch resume token %v: %v", s.key, err)
+	}
+	return string(entry.Value()), nil
+}
+
+func (s *kvTokenStore) StoreToken(_ context.Context, token string) error {
+	kv, err := s.keyValue()
+	if err != nil {
+		return err
+	}
+	if _, err := kv.PutString(s.key, token); err != nil {
+		return fmt.Errorf("could not store resume token %v: %v", s.key, err)
+	}
+	return nil
+}
+
+func (s *kvTokenStore) LastRename(_ context.Context) (string, error) {
+	kv, err := s.keyValue()
+	if err != nil {
+		return "", err
+	}
+	entry, err := kv.Get(s.key + renameKeySuffix)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not fetch followed rename %v: %v", s.key, err)
+	}
+	return string(entry.Value()), nil
+}
+
+func (s *kvTokenStore) StoreRename(_ context.Context, rename string) error {
+	kv, err := s.keyValue()
+	if err != nil {
+		return err
+	}
+	if _, err := kv.PutString(s.key+renameKeySuffix, rename); err != nil {
+		return fmt.Errorf("could not store followed rename %v: %v", s.key, err)
+	}
+	return nil
+}
+
+var _ TokenStore = &streamTokenStore{}
+
+// streamTokenStore reads resume tokens from the ResumeTokenHdr header of the last message published on the stream
+// subject. Tokens are stored along with each published message, so storing them again is a no-op. The tokens of the
+// change events that are not published on the stream, e.g. filtered out or dead-lettered, are thus never stored, and
+// those change events are received again after a restart, until a following change event is published.
+type streamTokenStore struct {
+	client     *DefaultClient
+	streamName string
+	subject    string
+}
+
+func (s *streamTokenStore) LastToken(ctx context.Context) (string, error) {
+	_, js := s.client.current()
+	msg, err := js.GetLastMsg(s.streamName, s.subject, nats.Context(ctx))
+	if err != nil {
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("could not fetch last message of nats stream %v: %v", s.streamName, err)
+	}
+	return msg.Header.Get(ResumeTokenHdr), nil
+}
+
+func (s *streamTokenStore) StoreToken(_ context.Context, _ string) error {
+	return nil
+}
+
+// renameKeySuffix is appended to the key of the resume tokens to store the followed rename.
+const renameKeySuffix = "/rename"
+
+// kvKey replaces the characters that are not allowed in a key-value key with underscores.
+func kvKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '/' || r == '_' || r == '=' || r == '.' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
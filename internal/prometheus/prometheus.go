@@ -11,6 +11,17 @@ import (
 
 type ConnectorRegisterer struct {
 	changeEventProcessingDuration *prometheus.HistogramVec
+	changeEventRetries            *prometheus.CounterVec
+	changeEventDeadLetters        *prometheus.CounterVec
+	changeStreamBackoffAttempts   *prometheus.GaugeVec
+	changeStreamBackoffDuration   *prometheus.GaugeVec
+	changeStreamInvalidations     *prometheus.CounterVec
+	changeStreamHistoryLost       *prometheus.CounterVec
+	changeStreamClusterTime       *prometheus.GaugeVec
+	chunkMigrations               *prometheus.CounterVec
+	shardChangeEvents             *prometheus.CounterVec
+	collectionDiscoveryEvents     *prometheus.CounterVec
+	discoveredCollections         *prometheus.GaugeVec
 }
 
 func NewConnectorRegisterer(registerer prometheus.Registerer) *ConnectorRegisterer {
@@ -23,6 +34,83 @@ func NewConnectorRegisterer(registerer prometheus.Registerer) *ConnectorRegister
 			},
 			[]string{"collection", "subject"},
 		),
+		changeEventRetries: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_change_event_retries_total",
+				Help: "Total number of change event publication retries.",
+			},
+			[]string{"collection", "subject"},
+		),
+		changeEventDeadLetters: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_change_event_dead_letters_total",
+				Help: "Total number of dead-lettered change events.",
+			},
+			[]string{"collection", "subject"},
+		),
+		changeStreamBackoffAttempts: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "connector_change_stream_backoff_attempts",
+				Help: "Number of consecutive failures of the change stream, zero if it is healthy.",
+			},
+			[]string{"namespace"},
+		),
+		changeStreamBackoffDuration: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "connector_change_stream_backoff_seconds",
+				Help: "Current backoff of the change stream in seconds, zero if it is healthy.",
+			},
+			[]string{"namespace"},
+		),
+		changeStreamInvalidations: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_change_stream_invalidations_total",
+				Help: "Total number of invalidated change streams, by action taken.",
+			},
+			[]string{"namespace", "action"},
+		),
+		changeStreamHistoryLost: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_change_stream_history_lost_total",
+				Help: "Total number of change streams that could not be resumed from the oplog, by action taken.",
+			},
+			[]string{"namespace", "action"},
+		),
+		changeStreamClusterTime: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "connector_change_stream_cluster_time_seconds",
+				Help: "Cluster time of the last change event processed by the change stream, in seconds since the epoch.",
+			},
+			[]string{"namespace"},
+		),
+		chunkMigrations: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_chunk_migrations_total",
+				Help: "Total number of chunks migrated to a new shard, by donor and recipient shard.",
+			},
+			[]string{"namespace", "from_shard", "to_shard"},
+		),
+		shardChangeEvents: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_shard_change_events_total",
+				Help: "Total number of change events of documents, by shard owning the document.",
+			},
+			[]string{"namespace", "shard"},
+		),
+		collectionDiscoveryEvents: promauto.With(registerer).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "connector_collection_discovery_events_total",
+				Help: "Total number of collections discovered or dropped, and of failed discoveries, by collection pattern.",
+			},
+			[]string{"pattern", "event"},
+		),
+		discoveredCollections: promauto.With(registerer).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "connector_discovered_collections",
+				Help: "Number of watched collections matching the collection pattern.",
+			},
+			[]string{"pattern"},
+		),
 	}
 }
 
@@ -30,6 +118,44 @@ func (r *ConnectorRegisterer) ObserveChangeEventProcessing(collName, subj string
 	r.changeEventProcessingDuration.WithLabelValues(collName, subj).Observe(duration.Seconds())
 }
 
+func (r *ConnectorRegisterer) IncChangeEventRetries(collName, subj string) {
+	r.changeEventRetries.WithLabelValues(collName, subj).Inc()
+}
+
+func (r *ConnectorRegisterer) IncChangeEventDeadLetters(collName, subj string) {
+	r.changeEventDeadLetters.WithLabelValues(collName, subj).Inc()
+}
+
+func (r *ConnectorRegisterer) SetChangeStreamBackoff(namespace string, attempts int, backoff time.Duration) {
+	r.changeStreamBackoffAttempts.WithLabelValues(namespace).Set(float64(attempts))
+	r.changeStreamBackoffDuration.WithLabelValues(namespace).Set(backoff.Seconds())
+}
+
+func (r *ConnectorRegisterer) IncChangeStreamInvalidations(namespace, action string) {
+	r.changeStreamInvalidations.WithLabelValues(namespace, action).Inc()
+}
+
+func (r *ConnectorRegisterer) IncChangeStreamHistoryLost(namespace, action string) {
+	r.changeStreamHistoryLost.WithLabelValues(namespace, action).Inc()
+}
+
+func (r *ConnectorRegisterer) SetChangeStreamClusterTime(namespace string, clusterTime time.Time) {
+	r.changeStreamClusterTime.WithLabelValues(namespace).Set(float64(clusterTime.Unix()))
+}
+
+func (r *ConnectorRegisterer) IncChunkMigrations(namespace, fromShard, toShard string) {
+	r.chunkMigrations.WithLabelValues(namespace, fromShard, toShard).Inc()
+}
+
+func (r *ConnectorRegisterer) IncShardChangeEvents(namespace, shard string) {
+	r.shardChangeEvents.WithLabelValues(namespace, shard).Inc()
+}
+
+func (r *ConnectorRegisterer) ObserveCollectionDiscovery(pattern, event string, discovered int) {
+	r.collectionDiscoveryEvents.WithLabelValues(pattern, event).Inc()
+	r.discoveredCollections.WithLabelValues(pattern).Set(float64(discovered))
+}
+
 type MongoRegisterer struct {
 	mongoCommandsStarted   *prometheus.CounterVec
 	mongoCommandsSucceeded *prometheus.CounterVec
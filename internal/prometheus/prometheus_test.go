@@ -30,6 +30,170 @@ func TestConnectorRegisterer_ObserveChangeEventProcessing(t *testing.T) {
 	requireMetricHasLabel(t, duration, "subject", expectedSubj)
 }
 
+func TestConnectorRegisterer_IncChangeEventRetries(t *testing.T) {
+	var (
+		registerer       = prometheus.NewPedanticRegistry()
+		expectedCollName = "coll1"
+		expectedSubj     = "COLL1.insert"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.IncChangeEventRetries(expectedCollName, expectedSubj)
+
+	retriesTotal := getMetric(t, registerer, "connector_change_event_retries_total")
+	require.NotNil(t, retriesTotal)
+	require.Equal(t, 1.0, retriesTotal.Counter.GetValue())
+	requireMetricHasLabel(t, retriesTotal, "collection", expectedCollName)
+	requireMetricHasLabel(t, retriesTotal, "subject", expectedSubj)
+}
+
+func TestConnectorRegisterer_IncChangeEventDeadLetters(t *testing.T) {
+	var (
+		registerer       = prometheus.NewPedanticRegistry()
+		expectedCollName = "coll1"
+		expectedSubj     = "COLL1.insert"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.IncChangeEventDeadLetters(expectedCollName, expectedSubj)
+
+	deadLettersTotal := getMetric(t, registerer, "connector_change_event_dead_letters_total")
+	require.NotNil(t, deadLettersTotal)
+	require.Equal(t, 1.0, deadLettersTotal.Counter.GetValue())
+	requireMetricHasLabel(t, deadLettersTotal, "collection", expectedCollName)
+	requireMetricHasLabel(t, deadLettersTotal, "subject", expectedSubj)
+}
+
+func TestConnectorRegisterer_SetChangeStreamBackoff(t *testing.T) {
+	var (
+		registerer        = prometheus.NewPedanticRegistry()
+		expectedNamespace = "test-db.coll1"
+		expectedAttempts  = 3
+		expectedBackoff   = 4 * time.Second
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.SetChangeStreamBackoff(expectedNamespace, expectedAttempts, expectedBackoff)
+
+	attempts := getMetric(t, registerer, "connector_change_stream_backoff_attempts")
+	require.NotNil(t, attempts)
+	require.Equal(t, float64(expectedAttempts), attempts.Gauge.GetValue())
+	requireMetricHasLabel(t, attempts, "namespace", expectedNamespace)
+
+	backoff := getMetric(t, registerer, "connector_change_stream_backoff_seconds")
+	require.NotNil(t, backoff)
+	require.Equal(t, expectedBackoff.Seconds(), backoff.Gauge.GetValue())
+	requireMetricHasLabel(t, backoff, "namespace", expectedNamespace)
+}
+
+func TestConnectorRegisterer_IncChangeStreamInvalidations(t *testing.T) {
+	var (
+		registerer        = prometheus.NewPedanticRegistry()
+		expectedNamespace = "test-db.coll1"
+		expectedAction    = "restart"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.IncChangeStreamInvalidations(expectedNamespace, expectedAction)
+
+	invalidationsTotal := getMetric(t, registerer, "connector_change_stream_invalidations_total")
+	require.NotNil(t, invalidationsTotal)
+	require.Equal(t, 1.0, invalidationsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, invalidationsTotal, "namespace", expectedNamespace)
+	requireMetricHasLabel(t, invalidationsTotal, "action", expectedAction)
+}
+
+func TestConnectorRegisterer_IncChangeStreamHistoryLost(t *testing.T) {
+	var (
+		registerer        = prometheus.NewPedanticRegistry()
+		expectedNamespace = "test-db.coll1"
+		expectedAction    = "resnapshot"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.IncChangeStreamHistoryLost(expectedNamespace, expectedAction)
+
+	historyLostTotal := getMetric(t, registerer, "connector_change_stream_history_lost_total")
+	require.NotNil(t, historyLostTotal)
+	require.Equal(t, 1.0, historyLostTotal.Counter.GetValue())
+	requireMetricHasLabel(t, historyLostTotal, "namespace", expectedNamespace)
+	requireMetricHasLabel(t, historyLostTotal, "action", expectedAction)
+}
+
+func TestConnectorRegisterer_SetChangeStreamClusterTime(t *testing.T) {
+	var (
+		registerer          = prometheus.NewPedanticRegistry()
+		expectedNamespace   = "test-db.coll1"
+		expectedClusterTime = time.Unix(1683637178, 0)
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.SetChangeStreamClusterTime(expectedNamespace, expectedClusterTime)
+
+	clusterTime := getMetric(t, registerer, "connector_change_stream_cluster_time_seconds")
+	require.NotNil(t, clusterTime)
+	require.Equal(t, 1683637178.0, clusterTime.Gauge.GetValue())
+	requireMetricHasLabel(t, clusterTime, "namespace", expectedNamespace)
+}
+
+func TestConnectorRegisterer_IncChunkMigrations(t *testing.T) {
+	var (
+		registerer        = prometheus.NewPedanticRegistry()
+		expectedNamespace = "test-db.coll1"
+		expectedFromShard = "shard1"
+		expectedToShard   = "shard2"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.IncChunkMigrations(expectedNamespace, expectedFromShard, expectedToShard)
+
+	migrationsTotal := getMetric(t, registerer, "connector_chunk_migrations_total")
+	require.NotNil(t, migrationsTotal)
+	require.Equal(t, 1.0, migrationsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, migrationsTotal, "namespace", expectedNamespace)
+	requireMetricHasLabel(t, migrationsTotal, "from_shard", expectedFromShard)
+	requireMetricHasLabel(t, migrationsTotal, "to_shard", expectedToShard)
+}
+
+func TestConnectorRegisterer_IncShardChangeEvents(t *testing.T) {
+	var (
+		registerer        = prometheus.NewPedanticRegistry()
+		expectedNamespace = "test-db.coll1"
+		expectedShard     = "shard1"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.IncShardChangeEvents(expectedNamespace, expectedShard)
+
+	eventsTotal := getMetric(t, registerer, "connector_shard_change_events_total")
+	require.NotNil(t, eventsTotal)
+	require.Equal(t, 1.0, eventsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, eventsTotal, "namespace", expectedNamespace)
+	requireMetricHasLabel(t, eventsTotal, "shard", expectedShard)
+}
+
+func TestConnectorRegisterer_ObserveCollectionDiscovery(t *testing.T) {
+	var (
+		registerer      = prometheus.NewPedanticRegistry()
+		expectedPattern = "shop.customer_*"
+		expectedEvent   = "added"
+	)
+
+	cr := NewConnectorRegisterer(registerer)
+	cr.ObserveCollectionDiscovery(expectedPattern, expectedEvent, 3)
+
+	discoveryEventsTotal := getMetric(t, registerer, "connector_collection_discovery_events_total")
+	require.NotNil(t, discoveryEventsTotal)
+	require.Equal(t, 1.0, discoveryEventsTotal.Counter.GetValue())
+	requireMetricHasLabel(t, discoveryEventsTotal, "pattern", expectedPattern)
+	requireMetricHasLabel(t, discoveryEventsTotal, "event", expectedEvent)
+
+	discovered := getMetric(t, registerer, "connector_discovered_collections")
+	require.NotNil(t, discovered)
+	require.Equal(t, 3.0, discovered.Gauge.GetValue())
+	requireMetricHasLabel(t, discovered, "pattern", expectedPattern)
+}
+
 func TestMongoRegisterer_IncMongoCmdStarted(t *testing.T) {
 	var (
 		registerer     = prometheus.NewPedanticRegistry()
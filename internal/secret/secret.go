@@ -0,0 +1,19 @@
+package secret
+
+import (
+	"os"
+	"strings"
+)
+
+// Read returns the given value, or the content of the given file without surrounding whitespaces if the value is
+// empty.
+func Read(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
@@ -0,0 +1,38 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(file, []byte(" s3cr3t\n"), 0o600))
+
+	tests := []struct {
+		name  string
+		value string
+		file  string
+		want  string
+	}{
+		{name: "should return the value", value: "value", file: file, want: "value"},
+		{name: "should return the trimmed content of the file", file: file, want: "s3cr3t"},
+		{name: "should return an empty secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(tt.value, tt.file)
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("should return error cause the file cannot be read", func(t *testing.T) {
+		_, err := Read("", filepath.Join(t.TempDir(), "missing"))
+
+		require.Error(t, err)
+	})
+}
@@ -10,15 +10,25 @@ type NamedMonitor interface {
 	Monitor(ctx context.Context) error
 }
 
+// DetailedMonitor is a NamedMonitor that also reports the details of its status, such as the state of its workers.
+type DetailedMonitor interface {
+	NamedMonitor
+	// Details returns a json serializable value, or nil if there are no details to report.
+	Details() any
+}
+
 func healthCheck(monitors ...NamedMonitor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		components := make(map[string]monitoredComponents, 0)
 		for _, monitor := range monitors {
-			if err := monitor.Monitor(r.Context()); err == nil {
-				components[monitor.Name()] = monitoredComponents{Status: UP}
-			} else {
-				components[monitor.Name()] = monitoredComponents{Status: DOWN}
+			component := monitoredComponents{Status: UP}
+			if err := monitor.Monitor(r.Context()); err != nil {
+				component.Status = DOWN
+			}
+			if detailedMonitor, ok := monitor.(DetailedMonitor); ok {
+				component.Details = detailedMonitor.Details()
 			}
+			components[monitor.Name()] = component
 		}
 		response := &healthResponse{
 			Status:     UP,
@@ -41,5 +51,6 @@ const (
 )
 
 type monitoredComponents struct {
-	Status health `json:"status"`
+	Status  health `json:"status"`
+	Details any    `json:"details,omitempty"`
 }
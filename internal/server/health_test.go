@@ -60,6 +60,25 @@ func Test_healthCheck(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "should write a json response with component details, if it reported any",
+			fields: fields{monitors: []NamedMonitor{&testDetailedComponent{
+				testComponent: testComponent{name: "test", err: nil},
+				details:       map[string]any{"db.coll": "backingOff"},
+			}}},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest(http.MethodGet, "/healthz", nil),
+			},
+			wantCode:        200,
+			wantContentType: "application/json",
+			wantBody: healthResponse{
+				Status: UP,
+				Components: map[string]monitoredComponents{
+					"test": {Status: UP, Details: map[string]any{"db.coll": "backingOff"}},
+				},
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -87,3 +106,12 @@ func (t *testComponent) Name() string {
 func (t *testComponent) Monitor(_ context.Context) error {
 	return t.err
 }
+
+type testDetailedComponent struct {
+	testComponent
+	details any
+}
+
+func (t *testDetailedComponent) Details() any {
+	return t.details
+}
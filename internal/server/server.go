@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net"
 	"net/http"
@@ -11,12 +12,15 @@ const defaultAddr = "127.0.0.1:8080"
 
 type Server struct {
 	addr           string
+	adminAddr      string
 	ctx            context.Context
 	monitors       []NamedMonitor
 	logger         *slog.Logger
 	metricsHandler http.Handler
+	snapshotter    Snapshotter
 
-	http *http.Server
+	http  *http.Server
+	admin *http.Server
 }
 
 func New(opts ...Option) *Server {
@@ -36,26 +40,52 @@ func New(opts ...Option) *Server {
 	if s.metricsHandler != nil {
 		mux.Handle("GET /metrics", s.metricsHandler)
 	}
+	s.http = s.newHTTPServer(s.addr, mux)
+
+	// the admin endpoints do not authenticate requests, so they are only served on their own address, if one is given
+	if s.adminAddr != "" && s.snapshotter != nil {
+		adminMux := http.NewServeMux()
+		adminMux.HandleFunc("POST /collections/{db}/{coll}/snapshot", startSnapshot(s.snapshotter))
+		adminMux.HandleFunc("GET /collections/{db}/{coll}/snapshot", snapshotStatus(s.snapshotter))
+		s.admin = s.newHTTPServer(s.adminAddr, adminMux)
+	}
 
-	s.http = &http.Server{
-		Addr:    s.addr,
+	return s
+}
+
+func (s *Server) newHTTPServer(addr string, mux *http.ServeMux) *http.Server {
+	return &http.Server{
+		Addr:    addr,
 		Handler: recoverer(mux),
 		BaseContext: func(l net.Listener) context.Context {
 			return s.ctx
 		},
 	}
-
-	return s
 }
 
+// Run serves the HTTP server, and the admin one if any, until either of them fails or is closed.
 func (s *Server) Run() error {
+	errCh := make(chan error, 2)
+	if s.admin != nil {
+		s.logger.Info("admin server started", "addr", s.adminAddr)
+		go func() {
+			errCh <- s.admin.ListenAndServe()
+		}()
+	}
 	s.logger.Info("server started", "addr", s.addr)
-	return s.http.ListenAndServe()
+	go func() {
+		errCh <- s.http.ListenAndServe()
+	}()
+	return <-errCh
 }
 
 func (s *Server) Close() error {
 	s.logger.Info("server gracefully shutting down", "addr", s.addr)
-	return s.http.Shutdown(context.Background())
+	err := s.http.Shutdown(context.Background())
+	if s.admin != nil {
+		err = errors.Join(err, s.admin.Shutdown(context.Background()))
+	}
+	return err
 }
 
 type Option func(*Server)
@@ -68,6 +98,16 @@ func WithAddr(addr string) Option {
 	}
 }
 
+// WithAdminAddr sets the address of the admin HTTP server, which serves the on-demand snapshots. They are not served
+// unless it is set.
+func WithAdminAddr(adminAddr string) Option {
+	return func(s *Server) {
+		if adminAddr != "" {
+			s.adminAddr = adminAddr
+		}
+	}
+}
+
 func WithContext(ctx context.Context) Option {
 	return func(s *Server) {
 		if ctx != nil {
@@ -99,3 +139,11 @@ func WithMetricsHandler(metricsHandler http.Handler) Option {
 		}
 	}
 }
+
+func WithSnapshotter(snapshotter Snapshotter) Option {
+	return func(s *Server) {
+		if snapshotter != nil {
+			s.snapshotter = snapshotter
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,6 +21,8 @@ func TestNew(t *testing.T) {
 		srv := New()
 
 		require.Equal(t, "127.0.0.1:8080", srv.addr)
+		require.Empty(t, srv.adminAddr)
+		require.Nil(t, srv.admin)
 		require.Equal(t, context.Background(), srv.ctx)
 		require.Empty(t, srv.monitors)
 		require.Equal(t, slog.Default(), srv.logger)
@@ -28,27 +31,33 @@ func TestNew(t *testing.T) {
 	t.Run("should create server with the configured options", func(t *testing.T) {
 		var (
 			addr           = "127.0.0.1:8085"
+			adminAddr      = "127.0.0.1:8086"
 			ctx            = context.TODO()
 			cmpUp          = &testComponent{name: "cmp_up", err: nil}
 			cmpDown        = &testComponent{name: "cmp_down", err: errors.New("not reachable")}
 			logger         = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 			metricsHandler = &testMetricsHandler{}
+			snapshotter    = &testSnapshotter{}
 		)
 
 		srv := New(
 			WithAddr(addr),
+			WithAdminAddr(adminAddr),
 			WithContext(ctx),
 			WithNamedMonitors(cmpUp, cmpDown),
 			WithLogger(logger),
 			WithMetricsHandler(metricsHandler),
+			WithSnapshotter(snapshotter),
 		)
 
 		require.Equal(t, addr, srv.addr)
+		require.Equal(t, adminAddr, srv.adminAddr)
 		require.Equal(t, ctx, srv.ctx)
 		require.Contains(t, srv.monitors, cmpUp)
 		require.Contains(t, srv.monitors, cmpDown)
 		require.Equal(t, logger, srv.logger)
 		require.Equal(t, metricsHandler, srv.metricsHandler)
+		require.Equal(t, snapshotter, srv.snapshotter)
 	})
 }
 
@@ -57,11 +66,14 @@ func TestServer_Run(t *testing.T) {
 		cmpUp          = &testComponent{name: "cmp_up", err: nil}
 		cmpDown        = &testComponent{name: "cmp_down", err: errors.New("not reachable")}
 		metricsHandler = &testMetricsHandler{}
+		snapshotter    = &testSnapshotter{}
 	)
 
 	srv := New(
+		WithAdminAddr("127.0.0.1:8081"),
 		WithNamedMonitors(cmpUp, cmpDown),
 		WithMetricsHandler(metricsHandler),
+		WithSnapshotter(snapshotter),
 	)
 
 	go func() {
@@ -106,6 +118,28 @@ func TestServer_Run(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, []byte("test metrics"), body)
 	})
+
+	t.Run("should successfully call snapshot endpoints on the admin address only", func(t *testing.T) {
+		waitForHealthyServer()
+
+		res, err := http.Post(fmt.Sprintf("http://%s/collections/test-db/coll1/snapshot", srv.addr), "application/json",
+			strings.NewReader(`{}`))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+		url := fmt.Sprintf("http://%s/collections/test-db/coll1/snapshot", srv.adminAddr)
+		res, err = http.Post(url, "application/json", strings.NewReader(`{"rateLimit":10}`))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusAccepted, res.StatusCode)
+		require.Equal(t, &SnapshotRequest{RateLimit: 10}, snapshotter.req)
+
+		res, err = http.Get(url)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		gotBody := SnapshotStatus{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&gotBody))
+		require.Equal(t, SnapshotStatus{Id: "1", Db: "test-db", Coll: "coll1", Status: "COMPLETED", Published: 10}, gotBody)
+	})
 }
 
 func healthcheck(srv *Server) (*http.Response, error) {
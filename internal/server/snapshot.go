@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrNotWatched             = errors.New("collection is not watched")
+	ErrSnapshotRunning        = errors.New("a snapshot of the collection is already running")
+	ErrSnapshotNotFound       = errors.New("no snapshot of the collection was started")
+	ErrInvalidSnapshotRequest = errors.New("invalid snapshot request")
+)
+
+// Snapshotter starts on-demand snapshots of the watched collections, which are published while the collections keep
+// being watched, and reports their progress.
+type Snapshotter interface {
+	// StartSnapshot starts a snapshot of the given collection in the background, and returns its initial status.
+	StartSnapshot(dbName, collName string, req *SnapshotRequest) (*SnapshotStatus, error)
+	// SnapshotStatus returns the status of the last snapshot of the given collection.
+	SnapshotStatus(dbName, collName string) (*SnapshotStatus, error)
+}
+
+// SnapshotRequest represents the body of a request to start a snapshot.
+type SnapshotRequest struct {
+	// Filter represents the query selecting the documents to publish, as extended json. All documents are published if
+	// it is empty.
+	Filter json.RawMessage `json:"filter,omitempty"`
+	// RateLimit represents the maximum number of documents published per second, zero means the default is used.
+	RateLimit int `json:"rateLimit,omitempty"`
+}
+
+// SnapshotStatus represents the progress of a snapshot.
+type SnapshotStatus struct {
+	Id          string     `json:"id"`
+	Db          string     `json:"db"`
+	Coll        string     `json:"coll"`
+	Status      string     `json:"status"`
+	Published   int64      `json:"published"`
+	StartedAt   time.Time  `json:"startedAt"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+func startSnapshot(snapshotter Snapshotter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &SnapshotRequest{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+				writeJsonError(w, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidSnapshotRequest, err))
+				return
+			}
+		}
+		if req.RateLimit < 0 {
+			writeJsonError(w, http.StatusBadRequest,
+				fmt.Errorf("%w: `rateLimit` cannot be negative", ErrInvalidSnapshotRequest))
+			return
+		}
+		status, err := snapshotter.StartSnapshot(r.PathValue("db"), r.PathValue("coll"), req)
+		if err != nil {
+			writeJsonError(w, snapshotErrorCode(err), err)
+			return
+		}
+		writeJson(w, http.StatusAccepted, status)
+	}
+}
+
+func snapshotStatus(snapshotter Snapshotter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := snapshotter.SnapshotStatus(r.PathValue("db"), r.PathValue("coll"))
+		if err != nil {
+			writeJsonError(w, snapshotErrorCode(err), err)
+			return
+		}
+		writeJson(w, http.StatusOK, status)
+	}
+}
+
+func snapshotErrorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNotWatched), errors.Is(err, ErrSnapshotNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSnapshotRunning):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidSnapshotRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_startSnapshot(t *testing.T) {
+	tests := []struct {
+		name        string
+		snapshotter *testSnapshotter
+		body        string
+		wantCode    int
+		wantReq     *SnapshotRequest
+	}{
+		{
+			name:        "should start a snapshot of all documents, if no body was given",
+			snapshotter: &testSnapshotter{},
+			wantCode:    http.StatusAccepted,
+			wantReq:     &SnapshotRequest{},
+		},
+		{
+			name:        "should start a snapshot with the given filter and rate limit",
+			snapshotter: &testSnapshotter{},
+			body:        `{"filter":{"tenantId":"acme"},"rateLimit":100}`,
+			wantCode:    http.StatusAccepted,
+			wantReq:     &SnapshotRequest{Filter: json.RawMessage(`{"tenantId":"acme"}`), RateLimit: 100},
+		},
+		{
+			name:        "should return bad request, if the body is not valid json",
+			snapshotter: &testSnapshotter{},
+			body:        `{"filter":`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "should return bad request, if the rate limit is negative",
+			snapshotter: &testSnapshotter{},
+			body:        `{"rateLimit":-1}`,
+			wantCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "should return not found, if the collection is not watched",
+			snapshotter: &testSnapshotter{err: ErrNotWatched},
+			wantCode:    http.StatusNotFound,
+		},
+		{
+			name:        "should return conflict, if a snapshot is already running",
+			snapshotter: &testSnapshotter{err: ErrSnapshotRunning},
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:        "should return internal server error, if the snapshot could not be started",
+			snapshotter: &testSnapshotter{err: errors.New("unexpected error")},
+			wantCode:    http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/collections/test-db/coll1/snapshot", strings.NewReader(tt.body))
+			req.SetPathValue("db", "test-db")
+			req.SetPathValue("coll", "coll1")
+
+			startSnapshot(tt.snapshotter)(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			if tt.wantCode != http.StatusAccepted {
+				gotBody := errorResponse{}
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
+				require.Equal(t, tt.wantCode, gotBody.Error.Code)
+				return
+			}
+			gotBody := SnapshotStatus{}
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&gotBody))
+			require.Equal(t, "test-db", gotBody.Db)
+			require.Equal(t, "coll1", gotBody.Coll)
+			require.Equal(t, tt.wantReq, tt.snapshotter.req)
+		})
+	}
+}
+
+func Test_snapshotStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		snapshotter *testSnapshotter
+		wantCode    int
+	}{
+		{
+			name:        "should write the status of the last snapshot",
+			snapshotter: &testSnapshotter{},
+			wantCode:    http.StatusOK,
+		},
+		{
+			name:        "should return not found, if no snapshot was started",
+			snapshotter: &testSnapshotter{err: ErrSnapshotNotFound},
+			wantCode:    http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/collections/test-db/coll1/snapshot", nil)
+			req.SetPathValue("db", "test-db")
+			req.SetPathValue("coll", "coll1")
+
+			snapshotStatus(tt.snapshotter)(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
+
+type testSnapshotter struct {
+	err error
+	req *SnapshotRequest
+}
+
+func (s *testSnapshotter) StartSnapshot(dbName, collName string, req *SnapshotRequest) (*SnapshotStatus, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.req = req
+	return &SnapshotStatus{Id: "1", Db: dbName, Coll: collName, Status: "RUNNING"}, nil
+}
+
+func (s *testSnapshotter) SnapshotStatus(dbName, collName string) (*SnapshotStatus, error) {
+	if s.err != nil {
+		return nil, fmt.Errorf("%w: %v.%v", s.err, dbName, collName)
+	}
+	return &SnapshotStatus{Id: "1", Db: dbName, Coll: collName, Status: "COMPLETED", Published: 10}, nil
+}
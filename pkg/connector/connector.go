@@ -1,15 +1,26 @@
 package connector
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/damianiandrea/mongodb-nats-connector/internal/mongo"
@@ -24,15 +35,202 @@ const (
 	defaultTokensDbName                 = "resume-tokens"
 	defaultTokensCollCapped             = false
 	defaultTokensCollSizeInBytes        = 0
+	defaultTokensStore                  = MongoTokensStore
+	defaultClusterTokensCollName        = "cluster"
+	defaultClusterStreamName            = "CLUSTER"
+	defaultDeadLetterDbName             = "dead-letters"
+	defaultDeadLetterRetryBackoff       = 1 * time.Second
+	defaultDeadLetterMaxRetryBackoff    = 1 * time.Minute
+	defaultBackoffInitial               = 1 * time.Second
+	defaultBackoffMax                   = 1 * time.Minute
+	defaultBackoffMultiplier            = 2
+	defaultBackoffJitter                = 0.2
+	defaultFailurePolicy                = RestartFailurePolicy
+	defaultSnapshot                     = NeverSnapshot
+	defaultSnapshotRateLimit            = 1000
+	defaultOnInvalidate                 = StopOnInvalidate
+	defaultOnHistoryLost                = FailOnHistoryLost
+	defaultFullDocument                 = UpdateLookupFullDocument
+	defaultFullDocumentBeforeChange     = WhenAvailableFullDocumentBeforeChange
+	defaultDiscoveryInterval            = 30 * time.Second
+	defaultPublishMaxPending            = 256
 )
 
 var (
-	ErrDbNameMissing          = errors.New("invalid option: `dbName` is missing")
-	ErrCollNameMissing        = errors.New("invalid option: `collName` is missing")
-	ErrInvalidCollSizeInBytes = errors.New("invalid option: `collSizeInBytes` must be greater than 0")
-	ErrInvalidDbAndCollNames  = errors.New("invalid option: `dbName` and `tokensDbName` cannot be the same if `collName` and `tokensCollName` are the same")
+	ErrDbNameMissing                   = errors.New("invalid option: `dbName` is missing")
+	ErrCollNameMissing                 = errors.New("invalid option: `collName` is missing")
+	ErrInvalidCollSizeInBytes          = errors.New("invalid option: `collSizeInBytes` must be greater than 0")
+	ErrInvalidDbAndCollNames           = errors.New("invalid option: `dbName` and `tokensDbName` cannot be the same if `collName` and `tokensCollName` are the same")
+	ErrInvalidTokensStore              = errors.New("invalid option: `tokensStore` must be one of 'mongo', 'nats' or 'stream'")
+	ErrInvalidStreamTokensStore        = errors.New("invalid option: `tokensStore` 'stream' requires a stream with 'limits' retention, and without 'maxAge', 'maxBytes' or 'maxMsgs'")
+	ErrInvalidStreamConfig             = errors.New("invalid option: `stream` contains an invalid value")
+	ErrInvalidSubjectTemplate          = errors.New("invalid option: `subjectTemplate` must be a valid template starting with the stream name")
+	ErrInvalidHeaders                  = errors.New("invalid option: `headers` names cannot be empty or contain colons and whitespaces")
+	ErrInvalidPipeline                 = errors.New("invalid option: `pipeline` must be a json array of change stream aggregation stages")
+	ErrInvalidDeadLetter               = errors.New("invalid option: `deadLetter` contains an invalid value")
+	ErrInvalidPublishConfig            = errors.New("invalid option: `publish` mode must be one of 'sync' or 'async', and maxPending cannot be negative")
+	ErrInvalidCheckpoint               = errors.New("invalid option: `checkpoint` every and interval cannot be negative")
+	ErrInvalidChangeStreamConfig       = errors.New("invalid option: `changeStream` contains an invalid value")
+	ErrInvalidBackoff                  = errors.New("invalid option: `backoff` contains an invalid value")
+	ErrInvalidFailurePolicy            = errors.New("invalid option: `failurePolicy` must be one of 'restart', 'stop' or 'exit'")
+	ErrInvalidSnapshot                 = errors.New("invalid option: `snapshot` must be one of 'initial', 'always' or 'never', and can only be set for collections")
+	ErrInvalidOperationTypes           = errors.New("invalid option: `operationTypes` contains an unknown operation type")
+	ErrInvalidOnInvalidate             = errors.New("invalid option: `onInvalidate` must be one of 'stop', 'restart' or 'follow-rename', and 'follow-rename' can only be set for collections whose tokens are not stored in the stream")
+	ErrInvalidOnHistoryLost            = errors.New("invalid option: `onHistoryLost` must be one of 'fail', 'restart-from-now' or 'resnapshot', and 'resnapshot' can only be set for collections")
+	ErrInvalidFullDocument             = errors.New("invalid option: `fullDocument` must be one of 'default', 'updateLookup', 'whenAvailable' or 'required'")
+	ErrInvalidFullDocumentBeforeChange = errors.New("invalid option: `fullDocumentBeforeChange` must be one of 'off', 'whenAvailable' or 'required'")
+	ErrUnsupportedMongoVersion         = errors.New("the mongodb server version does not support the given options")
+	ErrUnsupportedMongoTopology        = errors.New("the mongodb topology does not support the given options")
+	ErrInvalidStartAt                  = errors.New("invalid option: `startAt` must be 'latest', 'earliest-available-oplog', an RFC 3339 timestamp or a resume token, and cannot be set along with a snapshot")
+	ErrInvalidResetTokens              = errors.New("invalid option: `resetTokens` must only contain watched namespaces")
+	ErrInvalidCollectionPattern        = errors.New("invalid option: `collectionPatterns` contains an invalid value")
+	ErrInvalidMongoAuth                = errors.New("invalid option: `mongo.auth` contains an invalid value")
+	ErrInvalidMongoTLS                 = errors.New("invalid option: `mongo.tls` contains an invalid value")
+	ErrInvalidMongoReadPreference      = errors.New("invalid option: `mongo.readPreference` must be one of 'primary', 'primaryPreferred', 'secondary', 'secondaryPreferred' or 'nearest'")
+	ErrInvalidNatsAuth                 = errors.New("invalid option: `nats.auth` must set only one of 'credsFile', 'nkeySeedFile', 'token' or 'user'")
+	ErrInvalidNatsConnection           = errors.New("invalid option: `nats` connection settings cannot be negative, except for 'maxReconnects' and 'reconnectBufSize'")
+	ErrInvalidNatsTLS                  = errors.New("invalid option: `nats.tls` must set both 'certFile' and 'keyFile', or neither")
 )
 
+const (
+	// MongoTokensStore stores resume tokens in a MongoDB collection.
+	MongoTokensStore = "mongo"
+	// NatsTokensStore stores resume tokens in a NATS JetStream key-value bucket.
+	NatsTokensStore = "nats"
+	// StreamTokensStore stores resume tokens in a header of each published message, and reads them back from the last
+	// message of the stream.
+	StreamTokensStore = "stream"
+)
+
+const (
+	// RestartFailurePolicy restarts a failed watcher with a backoff, while the other watchers keep running.
+	RestartFailurePolicy = "restart"
+	// StopFailurePolicy stops a failed watcher, while the other watchers keep running.
+	StopFailurePolicy = "stop"
+	// ExitFailurePolicy stops the Connector when a watcher fails.
+	ExitFailurePolicy = "exit"
+)
+
+const (
+	// NeverSnapshot never publishes the existing documents of a watched collection.
+	NeverSnapshot = mongo.SnapshotNever
+	// InitialSnapshot publishes the existing documents of a watched collection if no resume token is stored yet.
+	InitialSnapshot = mongo.SnapshotInitial
+	// AlwaysSnapshot publishes the existing documents of a watched collection every time it starts being watched.
+	AlwaysSnapshot = mongo.SnapshotAlways
+)
+
+const (
+	// StopOnInvalidate stops watching a collection once its change stream is invalidated, e.g. after a drop or rename.
+	StopOnInvalidate = mongo.InvalidateStop
+	// RestartOnInvalidate keeps watching a collection after its change stream is invalidated, so that a recreated
+	// collection is watched again.
+	RestartOnInvalidate = mongo.InvalidateRestart
+	// FollowRenameOnInvalidate watches the new name of a renamed collection, and behaves like RestartOnInvalidate after
+	// other invalidations.
+	FollowRenameOnInvalidate = mongo.InvalidateFollowRename
+)
+
+const (
+	// FailOnHistoryLost fails the watcher of a collection whose change stream cannot be resumed because its resume
+	// token is no longer in the oplog, so that it is handled according to the failure policy.
+	FailOnHistoryLost = mongo.HistoryLostFail
+	// RestartFromNowOnHistoryLost watches a collection from now once its history is lost, skipping the lost changes.
+	RestartFromNowOnHistoryLost = mongo.HistoryLostRestartFromNow
+	// ResnapshotOnHistoryLost publishes the existing documents of a collection again once its history is lost, and
+	// then watches it from the time the snapshot was taken.
+	ResnapshotOnHistoryLost = mongo.HistoryLostResnapshot
+)
+
+const (
+	// DefaultFullDocument omits the full document of the update events of a watched collection.
+	DefaultFullDocument = mongo.FullDocumentDefault
+	// UpdateLookupFullDocument looks up the current version of the document for the update events of a watched
+	// collection.
+	UpdateLookupFullDocument = mongo.FullDocumentUpdateLookup
+	// WhenAvailableFullDocument includes the post-image of the document in the change events of a watched collection,
+	// if available. Requires MongoDB 6.0.
+	WhenAvailableFullDocument = mongo.FullDocumentWhenAvailable
+	// RequiredFullDocument includes the post-image of the document in the change events of a watched collection, and
+	// fails if it is not available. Requires MongoDB 6.0.
+	RequiredFullDocument = mongo.FullDocumentRequired
+)
+
+const (
+	// OffFullDocumentBeforeChange omits the pre-image of the document from the change events of a watched collection.
+	OffFullDocumentBeforeChange = mongo.FullDocumentOff
+	// WhenAvailableFullDocumentBeforeChange includes the pre-image of the document in the change events of a watched
+	// collection, if available.
+	WhenAvailableFullDocumentBeforeChange = mongo.FullDocumentWhenAvailable
+	// RequiredFullDocumentBeforeChange includes the pre-image of the document in the change events of a watched
+	// collection, and fails if it is not available. Requires MongoDB 6.0.
+	RequiredFullDocumentBeforeChange = mongo.FullDocumentRequired
+)
+
+const (
+	// minFullDocumentMajorVersion represents the MongoDB major version required by the whenAvailable and required full
+	// documents.
+	minFullDocumentMajorVersion = 6
+	// minExpandedEventsMajorVersion represents the MongoDB major version required by showExpandedEvents.
+	minExpandedEventsMajorVersion = 6
+)
+
+const (
+	// LatestStartAt starts watching a collection at the current operation time, if no resume token is stored.
+	LatestStartAt = mongo.StartAtLatest
+	// EarliestStartAt starts watching a collection at the earliest operation still available in the oplog, if no
+	// resume token is stored.
+	EarliestStartAt = mongo.StartAtEarliest
+)
+
+const (
+	// SyncPublishMode publishes each change event and stores its resume token before handling the next one.
+	SyncPublishMode = "sync"
+	// AsyncPublishMode publishes change events without waiting for their acknowledgement, and stores the resume token
+	// of the highest contiguous acknowledged one.
+	AsyncPublishMode = "async"
+)
+
+const (
+	// NatsDeadLetterStore publishes dead letters to a NATS stream.
+	NatsDeadLetterStore = "nats"
+	// MongoDeadLetterStore stores dead letters in a MongoDB collection.
+	MongoDeadLetterStore = "mongo"
+)
+
+// changeStreamStages contains the aggregation stages that MongoDB allows in a change stream pipeline.
+var changeStreamStages = map[string]struct{}{
+	"$addFields":   {},
+	"$match":       {},
+	"$project":     {},
+	"$replaceRoot": {},
+	"$replaceWith": {},
+	"$redact":      {},
+	"$set":         {},
+	"$unset":       {},
+}
+
+// operationTypes contains the operation types of the change events that can be published, and whether MongoDB only
+// reports them with showExpandedEvents.
+var operationTypes = map[string]bool{
+	"insert":                   false,
+	"update":                   false,
+	"replace":                  false,
+	"delete":                   false,
+	"drop":                     false,
+	"rename":                   false,
+	"dropDatabase":             false,
+	"invalidate":               false,
+	"create":                   true,
+	"createIndexes":            true,
+	"dropIndexes":              true,
+	"modify":                   true,
+	"shardCollection":          true,
+	"refineCollectionShardKey": true,
+	"reshardCollection":        true,
+	"migrateChunkToNewShard":   true,
+}
+
 // The Connector type represents a connector between MongoDB and NATS.
 type Connector struct {
 
@@ -44,6 +242,15 @@ type Connector struct {
 
 	// server represents the HTTP server used by the Connector.
 	server *server.Server
+
+	// supervisor runs the watchers of the Connector, isolating their failures.
+	supervisor *supervisor
+
+	// snapshotter runs the on-demand snapshots of the watched collections.
+	snapshotter *snapshotter
+
+	// discoverer watches the collections matching the configured collection patterns.
+	discoverer *discoverer
 }
 
 // New creates a new Connector.
@@ -58,20 +265,36 @@ func New(opts ...Option) (*Connector, error) {
 			return nil, err
 		}
 	}
+	if err := c.options.applyResetTokens(); err != nil {
+		return nil, err
+	}
 
 	loggerOpts := &slog.HandlerOptions{Level: c.options.logLevel}
 	c.logger = slog.New(slog.NewJSONHandler(os.Stdout, loggerOpts))
 
 	registerer := prometheus.DefaultRegisterer()
 
+	var onCollectionDiscovery func(pattern, event string, discovered int)
 	if c.options.mongoClient == nil {
 		connectorRegisterer := prometheus.NewConnectorRegisterer(registerer)
+		onCollectionDiscovery = connectorRegisterer.ObserveCollectionDiscovery
 		mongoRegisterer := prometheus.NewMongoRegisterer(registerer)
 		mongoClient, err := mongo.NewDefaultClient(
 			mongo.WithMongoUri(c.options.mongoUri),
+			mongo.WithAuth(c.options.mongoAuth),
+			mongo.WithTLS(c.options.mongoTLS),
+			mongo.WithReadPreference(c.options.mongoReadPreference),
 			mongo.WithLogger(c.logger),
 			mongo.WithEventListeners(
 				mongo.OnChangeEventProcessingEvent(connectorRegisterer.ObserveChangeEventProcessing),
+				mongo.OnChangeEventRetryEvent(connectorRegisterer.IncChangeEventRetries),
+				mongo.OnChangeEventDeadLetterEvent(connectorRegisterer.IncChangeEventDeadLetters),
+				mongo.OnChangeStreamBackoffEvent(connectorRegisterer.SetChangeStreamBackoff),
+				mongo.OnChangeStreamInvalidateEvent(connectorRegisterer.IncChangeStreamInvalidations),
+				mongo.OnChangeStreamHistoryLostEvent(connectorRegisterer.IncChangeStreamHistoryLost),
+				mongo.OnChangeStreamClusterTimeEvent(connectorRegisterer.SetChangeStreamClusterTime),
+				mongo.OnChunkMigrationEvent(connectorRegisterer.IncChunkMigrations),
+				mongo.OnShardChangeEventEvent(connectorRegisterer.IncShardChangeEvents),
 				mongo.OnCmdStartedEvent(mongoRegisterer.IncMongoCmdStarted),
 				mongo.OnCmdSucceededEvent(mongoRegisterer.ObserveMongoCmdSucceeded),
 				mongo.OnCmdFailedEvent(mongoRegisterer.ObserveMongoCmdFailed),
@@ -87,6 +310,9 @@ func New(opts ...Option) (*Connector, error) {
 		natsRegisterer := prometheus.NewNatsRegisterer(registerer)
 		natsClient, err := nats.NewDefaultClient(
 			nats.WithNatsUrl(c.options.natsUrl),
+			nats.WithAuth(c.options.natsAuth),
+			nats.WithTLS(c.options.natsTLS),
+			nats.WithConnection(c.options.natsConnection),
 			nats.WithLogger(c.logger),
 			nats.WithEventListeners(
 				nats.OnMsgPublishedEvent(natsRegisterer.ObserveNatsMsgPublished),
@@ -101,12 +327,18 @@ func New(opts ...Option) (*Connector, error) {
 
 	c.options.ctx, c.options.stop = signal.NotifyContext(c.options.ctx, syscall.SIGINT, syscall.SIGTERM)
 
+	c.supervisor = newSupervisor(c.logger, c.options.backoff.policy())
+	c.snapshotter = newSnapshotter(c.logger, c.options.mongoClient, defaultSnapshotRateLimit)
+	c.discoverer = newDiscoverer(c.logger, c.options.mongoClient, c.options.collections, onCollectionDiscovery)
+
 	c.server = server.New(
 		server.WithAddr(c.options.serverAddr),
+		server.WithAdminAddr(c.options.adminAddr),
 		server.WithContext(c.options.ctx),
-		server.WithNamedMonitors(c.options.mongoClient, c.options.natsClient),
+		server.WithNamedMonitors(c.options.mongoClient, c.options.natsClient, c.supervisor),
 		server.WithLogger(c.logger),
 		server.WithMetricsHandler(prometheus.HTTPHandler()),
+		server.WithSnapshotter(c.snapshotter),
 	)
 
 	return c, nil
@@ -115,11 +347,20 @@ func New(opts ...Option) (*Connector, error) {
 // Run runs the Connector.
 // It performs the following operations:
 //
-//	For each configured collection to be watched:
-//		- It creates the given collection on MongoDB, if it does not already exist
-//		- It creates the resume tokens collection for the given collection on MongoDB, if it does not already exist
-//		- It creates the given stream on NATS, if it does not already exist
-//		- Spins up a goroutine to watch the given collection
+//	It checks that the MongoDB topology and version support the given options.
+//	For each configured collection, database or cluster to be watched:
+//		- It creates the given collection on MongoDB, if it does not already exist and a collection is being watched
+//		- It creates the resume tokens collection for the given collection on MongoDB, or the resume tokens
+//		  key-value bucket on NATS, if it does not already exist and resume tokens are not stored in the stream
+//		- It creates the given stream on NATS, if it does not already exist, or updates its configuration
+//		- It creates the dead letter stream on NATS, or the dead letter collection on MongoDB, if dead letters are
+//		  enabled and it does not already exist
+//		- Spins up a goroutine to watch the given collection, which is restarted or stopped on failure depending on
+//		  its failure policy, without affecting the other collections
+//		- Makes the given collection available for on-demand snapshots through the admin HTTP server, if enabled
+//	For each configured collection pattern, it runs a goroutine that periodically lists the collections of the given
+//	database, and performs the operations above for the new collections matching the pattern, or stops watching the
+//	dropped ones.
 //	It runs an HTTP server in its own goroutine.
 //	It runs another goroutine that will perform graceful shutdown once the Connector's context is cancelled.
 func (c *Connector) Run() error {
@@ -127,62 +368,288 @@ func (c *Connector) Run() error {
 
 	group, groupCtx := errgroup.WithContext(c.options.ctx)
 
+	if err := c.checkMongoTopology(groupCtx); err != nil {
+		return err
+	}
+	if err := c.checkMongoVersion(groupCtx); err != nil {
+		return err
+	}
+
 	for _, coll := range c.options.collections {
+		if err := c.watch(groupCtx, group, coll); err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range c.options.collectionPatterns {
+		group.Go(func() error {
+			c.discoverer.discover(groupCtx, pattern, func(ctx context.Context, coll *collection) error {
+				return c.watch(ctx, group, coll)
+			})
+			return nil
+		})
+	}
+
+	group.Go(func() error {
+		return c.server.Run()
+	})
+
+	group.Go(func() error {
+		<-groupCtx.Done()
+		return c.server.Close()
+	})
+
+	return group.Wait()
+}
+
+// watch creates the collections and streams needed by the given collection, and starts watching it in a goroutine of
+// the given group until the given context is done.
+func (c *Connector) watch(ctx context.Context, group *errgroup.Group, coll *collection) error {
+	if coll.collName != "" && !coll.discovered {
+		// discovered collections already exist, and must not be recreated if they have just been dropped
 		createWatchedCollOpts := &mongo.CreateCollectionOptions{
 			DbName:                       coll.dbName,
 			CollName:                     coll.collName,
 			ChangeStreamPreAndPostImages: coll.changeStreamPreAndPostImages,
 		}
-		if err := c.options.mongoClient.CreateCollection(groupCtx, createWatchedCollOpts); err != nil {
+		if err := c.options.mongoClient.CreateCollection(ctx, createWatchedCollOpts); err != nil {
 			return err
 		}
+	}
 
+	var tokenStore mongo.TokenStore
+	switch coll.tokensStore {
+	case NatsTokensStore:
+		createTokenStoreOpts := &nats.CreateTokenStoreOptions{
+			BucketName: coll.tokensDbName,
+			Key:        coll.tokensCollName,
+		}
+		natsTokenStore, err := c.options.natsClient.CreateTokenStore(ctx, createTokenStoreOpts)
+		if err != nil {
+			return err
+		}
+		tokenStore = natsTokenStore
+	case StreamTokensStore:
+		createStreamTokenStoreOpts := &nats.CreateStreamTokenStoreOptions{
+			StreamName: coll.streamName,
+			Subject:    coll.streamSubjects()[0],
+		}
+		streamTokenStore, err := c.options.natsClient.CreateStreamTokenStore(ctx, createStreamTokenStoreOpts)
+		if err != nil {
+			return err
+		}
+		tokenStore = streamTokenStore
+	default:
 		createResumeTokensCollOpts := &mongo.CreateCollectionOptions{
 			DbName:      coll.tokensDbName,
 			CollName:    coll.tokensCollName,
 			Capped:      coll.tokensCollCapped,
 			SizeInBytes: coll.tokensCollSizeInBytes,
 		}
-		if err := c.options.mongoClient.CreateCollection(groupCtx, createResumeTokensCollOpts); err != nil {
+		if err := c.options.mongoClient.CreateCollection(ctx, createResumeTokensCollOpts); err != nil {
 			return err
 		}
+	}
+
+	addStreamOpts := &nats.AddStreamOptions{
+		StreamName:      coll.streamName,
+		Subjects:        coll.streamSubjects(),
+		Storage:         coll.streamConfig.Storage,
+		Retention:       coll.streamConfig.Retention,
+		Replicas:        coll.streamConfig.Replicas,
+		MaxAge:          coll.streamConfig.MaxAge,
+		MaxBytes:        coll.streamConfig.MaxBytes,
+		MaxMsgs:         coll.streamConfig.MaxMsgs,
+		Discard:         coll.streamConfig.Discard,
+		DuplicateWindow: coll.streamConfig.DuplicateWindow,
+	}
+	if err := c.options.natsClient.AddStream(ctx, addStreamOpts); err != nil {
+		return err
+	}
 
-		addStreamOpts := &nats.AddStreamOptions{StreamName: coll.streamName}
-		if err := c.options.natsClient.AddStream(groupCtx, addStreamOpts); err != nil {
+	var deadLetterHandler mongo.DeadLetterHandler
+	if coll.deadLetter != nil {
+		if err := c.createDeadLetterStore(ctx, coll); err != nil {
 			return err
 		}
+		deadLetterHandler = c.deadLetterHandler(coll)
+	}
 
-		group.Go(func() error {
-			watchCollOpts := &mongo.WatchCollectionOptions{
-				WatchedDbName:          coll.dbName,
-				WatchedCollName:        coll.collName,
-				ResumeTokensDbName:     coll.tokensDbName,
-				ResumeTokensCollName:   coll.tokensCollName,
-				ResumeTokensCollCapped: coll.tokensCollCapped,
-				StreamName:             coll.streamName,
-				ChangeEventHandler: func(ctx context.Context, subj, msgId string, data []byte) error {
-					publishOpts := &nats.PublishOptions{
-						Subj:  subj,
-						MsgId: msgId,
-						Data:  data,
-					}
-					return c.options.natsClient.Publish(ctx, publishOpts)
-				},
-			}
-			return c.options.mongoClient.WatchCollection(groupCtx, watchCollOpts) // blocking call
-		})
+	watchCollOpts := &mongo.WatchCollectionOptions{
+		WatchedDbName:            coll.dbName,
+		WatchedCollName:          coll.collName,
+		ResumeTokensDbName:       coll.tokensDbName,
+		ResumeTokensCollName:     coll.tokensCollName,
+		ResumeTokensCollCapped:   coll.tokensCollCapped,
+		TokenStore:               tokenStore,
+		CheckpointEvery:          coll.checkpoint.Every,
+		CheckpointInterval:       coll.checkpoint.Interval,
+		StreamName:               coll.streamName,
+		Pipeline:                 coll.watchPipeline(),
+		SubjectTemplate:          coll.subjectTemplate,
+		OperationTypes:           coll.operationTypes,
+		ShowExpandedEvents:       coll.showExpandedEvents(),
+		FullDocument:             coll.fullDocument,
+		FullDocumentBeforeChange: coll.fullDocumentBeforeChange,
+		BatchSize:                coll.changeStreamConfig.BatchSize,
+		MaxAwaitTime:             coll.changeStreamConfig.MaxAwaitTime,
+		Collation:                coll.collation,
+		Comment:                  coll.changeStreamConfig.Comment,
+		OnInvalidate:             coll.onInvalidate,
+		OnHistoryLost:            coll.onHistoryLost,
+		ChangeEventHandler: func(ctx context.Context, event *mongo.ChangeEvent) error {
+			return c.options.natsClient.Publish(ctx, coll.publishOptions(event))
+		},
+		DeadLetterHandler: deadLetterHandler,
+		MaxPending:        coll.publishConfig.MaxPending,
+		Backoff:           c.options.backoff.policy(),
+		Snapshot:          coll.snapshot,
+		StartAt:           coll.startAt,
+	}
+	if coll.publishConfig.Mode == AsyncPublishMode {
+		watchCollOpts.AsyncChangeEventHandler = func(ctx context.Context, event *mongo.ChangeEvent) (<-chan error, error) {
+			return c.options.natsClient.PublishAsync(ctx, coll.publishOptions(event))
+		}
 	}
+	if coll.deadLetter != nil {
+		watchCollOpts.MaxRetries = coll.deadLetter.MaxRetries
+		watchCollOpts.RetryBackoff = coll.deadLetter.RetryBackoff
+		watchCollOpts.MaxRetryBackoff = coll.deadLetter.MaxRetryBackoff
+	}
+	c.snapshotter.register(ctx, coll, watchCollOpts)
 
+	resetTokens := coll.resetTokens
 	group.Go(func() error {
-		return c.server.Run()
+		err := c.supervisor.supervise(ctx, coll.namespace(), coll.failurePolicy, func(ctx context.Context) error {
+			// the stored resume tokens are only reset the first time, a restarted watcher resumes after the
+			// tokens stored since then
+			opts := *watchCollOpts
+			opts.ResetTokens, resetTokens = resetTokens, false
+			return c.options.mongoClient.WatchCollection(ctx, &opts)
+		})
+		if coll.discovered {
+			// the collection was dropped, its change stream was invalidated, or the Connector is stopping
+			c.supervisor.remove(coll.namespace())
+			c.snapshotter.unregister(coll)
+			c.discoverer.ended(coll)
+		}
+		return err
 	})
+	return nil
+}
 
-	group.Go(func() error {
-		<-groupCtx.Done()
-		return c.server.Close()
-	})
+// checkMongoTopology checks that the MongoDB deployment supports change streams, and the start positions of the
+// watched collections: the oplog cannot be read through mongos.
+func (c *Connector) checkMongoTopology(ctx context.Context) error {
+	topology, err := c.options.mongoClient.Topology(ctx)
+	if err != nil {
+		return err
+	}
+	c.logger.Info("detected mongodb topology", "topology", topology.Kind, "setName", topology.SetName,
+		"shards", topology.Shards)
+	switch topology.Kind {
+	case mongo.TopologyStandalone:
+		return fmt.Errorf("%w: change streams require a replica set or a sharded cluster", ErrUnsupportedMongoTopology)
+	case mongo.TopologySharded:
+		for _, coll := range c.allCollections() {
+			if coll.startAt.Earliest() {
+				return fmt.Errorf("%w: startAt of %v cannot be %v on a sharded cluster", ErrUnsupportedMongoTopology,
+					coll.namespace(), EarliestStartAt)
+			}
+		}
+	}
+	return nil
+}
 
-	return group.Wait()
+// allCollections returns the configured collections, databases and clusters, along with a sample collection for each
+// collection pattern.
+func (c *Connector) allCollections() []*collection {
+	collections := slices.Clone(c.options.collections)
+	for _, pattern := range c.options.collectionPatterns {
+		collections = append(collections, pattern.sample)
+	}
+	return collections
+}
+
+// checkMongoVersion checks that the MongoDB server supports the full document settings and the expanded events of the
+// watched collections.
+func (c *Connector) checkMongoVersion(ctx context.Context) error {
+	collections := c.allCollections()
+	if !slices.ContainsFunc(collections, func(coll *collection) bool { return coll.minMongoMajorVersion() > 0 }) {
+		return nil
+	}
+	version, err := c.options.mongoClient.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+	majorVersion := mongoMajorVersion(version)
+	for _, coll := range collections {
+		if minMajorVersion := coll.minMongoMajorVersion(); majorVersion < minMajorVersion {
+			return fmt.Errorf("%w: options of %v require MongoDB %v.0, got %v", ErrUnsupportedMongoVersion,
+				coll.namespace(), minMajorVersion, version)
+		}
+	}
+	return nil
+}
+
+// mongoMajorVersion returns the major version of the given MongoDB server version, or 0 if it cannot be parsed.
+func mongoMajorVersion(version string) int {
+	major, _, _ := strings.Cut(version, ".")
+	majorVersion, _ := strconv.Atoi(major)
+	return majorVersion
+}
+
+// createDeadLetterStore creates the NATS stream or the MongoDB collection where the dead letters of the given
+// collection are stored.
+func (c *Connector) createDeadLetterStore(ctx context.Context, coll *collection) error {
+	if coll.deadLetter.Store == MongoDeadLetterStore {
+		createDeadLetterCollOpts := &mongo.CreateCollectionOptions{
+			DbName:   coll.deadLetter.DbName,
+			CollName: coll.deadLetter.CollName,
+		}
+		return c.options.mongoClient.CreateCollection(ctx, createDeadLetterCollOpts)
+	}
+	addDeadLetterStreamOpts := &nats.AddStreamOptions{
+		StreamName: coll.deadLetter.StreamName,
+		Subjects:   []string{coll.deadLetter.StreamName + ".>"},
+	}
+	return c.options.natsClient.AddStream(ctx, addDeadLetterStreamOpts)
+}
+
+// deadLetterHandler returns the handler storing the change events of the given collection that could not be
+// published, along with the error that prevented their publication.
+func (c *Connector) deadLetterHandler(coll *collection) mongo.DeadLetterHandler {
+	attempts := coll.deadLetter.MaxRetries + 1
+	return func(ctx context.Context, event *mongo.ChangeEvent, cause error) error {
+		if coll.deadLetter.Store == MongoDeadLetterStore {
+			insertDeadLetterOpts := &mongo.InsertDeadLetterOptions{
+				DbName:   coll.deadLetter.DbName,
+				CollName: coll.deadLetter.CollName,
+				Event:    event,
+				Err:      cause,
+				Attempts: attempts,
+			}
+			return c.options.mongoClient.InsertDeadLetter(ctx, insertDeadLetterOpts)
+		}
+
+		headers := coll.headers(event)
+		headers[nats.DeadLetterSubjectHdr] = event.Subj
+		headers[nats.DeadLetterErrorHdr] = strings.Join(strings.Fields(cause.Error()), " ")
+		headers[nats.DeadLetterAttemptsHdr] = strconv.Itoa(attempts)
+		publishOpts := &nats.PublishOptions{
+			Subj:    coll.deadLetter.StreamName + strings.TrimPrefix(event.Subj, coll.streamName),
+			MsgId:   event.Id,
+			Data:    event.Data,
+			Headers: headers,
+		}
+		err := c.options.natsClient.Publish(ctx, publishOpts)
+		if errors.Is(err, nats.ErrMaxPayload) {
+			// the change event itself is too large, publish its metadata only
+			publishOpts.Data = nil
+			err = c.options.natsClient.Publish(ctx, publishOpts)
+		}
+		return err
+	}
 }
 
 func (c *Connector) cleanup() {
@@ -207,12 +674,30 @@ type Options struct {
 	// mongoUri represents the Connector's MongoDB URI.
 	mongoUri string
 
+	// mongoAuth represents the credentials used by the Connector to authenticate to MongoDB.
+	mongoAuth *mongo.AuthOptions
+
+	// mongoTLS represents the TLS configuration of the Connector's connection to MongoDB.
+	mongoTLS *mongo.TLSOptions
+
+	// mongoReadPreference represents the members of the replica set the Connector reads change streams from.
+	mongoReadPreference *mongo.ReadPreferenceOptions
+
 	// mongoClient represents the MongoDB client used by the Connector to connect to MongoDB.
 	mongoClient mongo.Client
 
 	// natsUrl represents the Connector's NATS URL.
 	natsUrl string
 
+	// natsAuth represents the credentials used by the Connector to authenticate to NATS.
+	natsAuth *nats.AuthOptions
+
+	// natsTLS represents the TLS configuration of the Connector's connection to NATS.
+	natsTLS *nats.TLSOptions
+
+	// natsConnection represents the tuning of the Connector's connection to NATS.
+	natsConnection *nats.ConnectionOptions
+
 	// natsClient represents the NATS client used by the Connector to connect to NATS.
 	natsClient nats.Client
 
@@ -223,8 +708,22 @@ type Options struct {
 	// serverAddr represents the Connector's HTTP server address.
 	serverAddr string
 
-	// collections represents a slice containing the collections to be watched, with their own configuration.
+	// adminAddr represents the Connector's admin HTTP server address, serving the on-demand snapshots if set.
+	adminAddr string
+
+	// collections represents a slice containing the collections, databases and clusters to be watched, with their
+	// own configuration.
 	collections []*collection
+
+	// backoff represents the policy used to wait before reopening a change stream after a failure.
+	backoff BackoffConfig
+
+	// resetTokens represents the namespaces whose stored resume tokens are ignored once the Connector starts.
+	resetTokens []string
+
+	// collectionPatterns represents the patterns of the collections to be discovered and watched while the Connector
+	// runs.
+	collectionPatterns []*collectionPattern
 }
 
 func getDefaultOptions() Options {
@@ -232,6 +731,12 @@ func getDefaultOptions() Options {
 		logLevel:    defaultLogLevel,
 		ctx:         context.Background(),
 		collections: make([]*collection, 0),
+		backoff: BackoffConfig{
+			Initial:    defaultBackoffInitial,
+			Max:        defaultBackoffMax,
+			Multiplier: defaultBackoffMultiplier,
+			Jitter:     defaultBackoffJitter,
+		},
 	}
 }
 
@@ -265,6 +770,125 @@ func WithMongoUri(mongoUri string) Option {
 	}
 }
 
+// mongoAuthMechanisms contains the supported MongoDB authentication mechanisms.
+var mongoAuthMechanisms = []string{"SCRAM-SHA-1", "SCRAM-SHA-256", "MONGODB-X509", "MONGODB-AWS", "MONGODB-OIDC", "GSSAPI",
+	"PLAIN"}
+
+// MongoAuthConfig represents the credentials used to authenticate to MongoDB, which replace the ones in the URI.
+type MongoAuthConfig struct {
+
+	// Mechanism can be set to "SCRAM-SHA-1", "SCRAM-SHA-256", "MONGODB-X509", "MONGODB-AWS", "MONGODB-OIDC", "GSSAPI"
+	// or "PLAIN". Defaults to the mechanism negotiated with the server.
+	Mechanism string
+
+	// MechanismProperties represents the properties of the mechanism, such as ENVIRONMENT and TOKEN_RESOURCE for
+	// MONGODB-OIDC, or AWS_SESSION_TOKEN for MONGODB-AWS.
+	MechanismProperties map[string]string
+
+	// Source represents the database the user is defined in. Defaults to the database of the URI, or "admin".
+	Source string
+
+	// Username represents the name of the user, authenticated with Password, or with the content of PasswordFile.
+	Username     string
+	Password     string
+	PasswordFile string
+}
+
+// WithMongoAuth sets the credentials used by the Connector to authenticate to MongoDB.
+func WithMongoAuth(auth MongoAuthConfig) Option {
+	return func(o *Options) error {
+		if auth.Mechanism != "" && !slices.Contains(mongoAuthMechanisms, strings.ToUpper(auth.Mechanism)) {
+			return fmt.Errorf("%w: unknown mechanism %v", ErrInvalidMongoAuth, auth.Mechanism)
+		}
+		if auth.Password != "" && auth.PasswordFile != "" {
+			return fmt.Errorf("%w: password and passwordFile cannot be both set", ErrInvalidMongoAuth)
+		}
+		if auth.Username == "" && (auth.Password != "" || auth.PasswordFile != "") {
+			return fmt.Errorf("%w: password requires username", ErrInvalidMongoAuth)
+		}
+		o.mongoAuth = &mongo.AuthOptions{
+			Mechanism:           strings.ToUpper(auth.Mechanism),
+			MechanismProperties: auth.MechanismProperties,
+			Source:              auth.Source,
+			Username:            auth.Username,
+			Password:            auth.Password,
+			PasswordFile:        auth.PasswordFile,
+		}
+		return nil
+	}
+}
+
+// MongoTLSConfig represents the TLS configuration of the connection to MongoDB, which replaces the one in the URI.
+type MongoTLSConfig struct {
+
+	// CAFile represents the path of the CA certificates used to verify the server, instead of the system ones.
+	CAFile string
+
+	// CertKeyFile represents the path of the file containing both the client certificate and its private key, used
+	// with mutual TLS and X.509 authentication.
+	CertKeyFile string
+
+	// CertKeyPassword represents the password of the private key, CertKeyPasswordFile the path of the file
+	// containing it.
+	CertKeyPassword     string
+	CertKeyPasswordFile string
+}
+
+// WithMongoTLS enables TLS on the Connector's connection to MongoDB.
+func WithMongoTLS(tls MongoTLSConfig) Option {
+	return func(o *Options) error {
+		if tls.CertKeyPassword != "" && tls.CertKeyPasswordFile != "" {
+			return fmt.Errorf("%w: certKeyPassword and certKeyPasswordFile cannot be both set", ErrInvalidMongoTLS)
+		}
+		if tls.CertKeyFile == "" && (tls.CertKeyPassword != "" || tls.CertKeyPasswordFile != "") {
+			return fmt.Errorf("%w: certKeyPassword requires certKeyFile", ErrInvalidMongoTLS)
+		}
+		o.mongoTLS = &mongo.TLSOptions{
+			CAFile:              tls.CAFile,
+			CertKeyFile:         tls.CertKeyFile,
+			CertKeyPassword:     tls.CertKeyPassword,
+			CertKeyPasswordFile: tls.CertKeyPasswordFile,
+		}
+		return nil
+	}
+}
+
+// MongoReadPreferenceConfig represents the members of the replica set the change streams are read from, which
+// replaces the read preference in the URI. Snapshots and resume tokens are always read from the primary.
+type MongoReadPreferenceConfig struct {
+
+	// Mode can be set to "primary", "primaryPreferred", "secondary", "secondaryPreferred" or "nearest".
+	Mode string
+
+	// MaxStaleness represents how far a secondary can lag behind the primary to be read from, at least 90s.
+	// Cannot be set with the "primary" mode.
+	MaxStaleness time.Duration
+}
+
+// WithMongoReadPreference sets the read preference of the Connector's change streams. Snapshots and resume tokens
+// are always read from the primary.
+func WithMongoReadPreference(readPreference MongoReadPreferenceConfig) Option {
+	return func(o *Options) error {
+		if readPreference.Mode == "" && readPreference.MaxStaleness == 0 {
+			return nil
+		}
+		if _, err := readpref.ModeFromString(readPreference.Mode); err != nil {
+			return ErrInvalidMongoReadPreference
+		}
+		if readPreference.MaxStaleness < 0 || (readPreference.MaxStaleness > 0 && readPreference.MaxStaleness < 90*time.Second) {
+			return fmt.Errorf("%w: maxStaleness must be at least 90s", ErrInvalidMongoReadPreference)
+		}
+		if readPreference.MaxStaleness > 0 && strings.EqualFold(readPreference.Mode, "primary") {
+			return fmt.Errorf("%w: maxStaleness cannot be set with the primary mode", ErrInvalidMongoReadPreference)
+		}
+		o.mongoReadPreference = &mongo.ReadPreferenceOptions{
+			Mode:         readPreference.Mode,
+			MaxStaleness: readPreference.MaxStaleness,
+		}
+		return nil
+	}
+}
+
 // withMongoClient sets the Connector's MongoDB client implementation.
 // Used for testing.
 func withMongoClient(mongoClient mongo.Client) Option {
@@ -286,6 +910,149 @@ func WithNatsUrl(natsUrl string) Option {
 	}
 }
 
+// NatsConnectionConfig represents the tuning of the connection to NATS. Zero values fall back to the NATS client
+// defaults. Once the reconnect attempts are exhausted the connection is closed, and the Connector re-creates it every
+// ReconnectWait until it succeeds.
+type NatsConnectionConfig struct {
+
+	// Name represents the connection name reported by the NATS server, e.g. in its monitoring endpoints.
+	Name string
+
+	// Urls represents additional seed URLs, tried along with the NATS URL.
+	Urls []string
+
+	// MaxReconnects represents the number of reconnect attempts after the connection is lost, negative for infinite
+	// attempts. Defaults to 60.
+	MaxReconnects int
+
+	// ReconnectWait represents the time to wait between reconnect attempts to the same server. Defaults to 2s.
+	ReconnectWait time.Duration
+
+	// ReconnectJitter represents the maximum random time added to ReconnectWait. Defaults to 100ms.
+	ReconnectJitter time.Duration
+
+	// PingInterval represents the interval between the pings sent to the server. Defaults to 2m.
+	PingInterval time.Duration
+
+	// MaxPingsOut represents the number of pings without a reply after which the connection is considered lost.
+	// Defaults to 2.
+	MaxPingsOut int
+
+	// ReconnectBufSize represents the size in bytes of the buffer holding the messages published while reconnecting,
+	// negative to disable it. Defaults to 8MB.
+	ReconnectBufSize int
+}
+
+// WithNatsConnection sets the tuning of the Connector's connection to NATS.
+func WithNatsConnection(connection NatsConnectionConfig) Option {
+	return func(o *Options) error {
+		if connection.ReconnectWait < 0 || connection.ReconnectJitter < 0 || connection.PingInterval < 0 ||
+			connection.MaxPingsOut < 0 {
+			return ErrInvalidNatsConnection
+		}
+		o.natsConnection = &nats.ConnectionOptions{
+			Name:             connection.Name,
+			Servers:          slices.DeleteFunc(slices.Clone(connection.Urls), func(url string) bool { return url == "" }),
+			MaxReconnects:    connection.MaxReconnects,
+			ReconnectWait:    connection.ReconnectWait,
+			ReconnectJitter:  connection.ReconnectJitter,
+			PingInterval:     connection.PingInterval,
+			MaxPingsOut:      connection.MaxPingsOut,
+			ReconnectBufSize: connection.ReconnectBufSize,
+		}
+		return nil
+	}
+}
+
+// NatsAuthConfig represents the credentials used to authenticate to NATS. Only one authentication method can be set.
+// Secrets can be read from files, so that they are not part of the configuration.
+type NatsAuthConfig struct {
+
+	// CredsFile represents the path of the file containing the user JWT and NKey seed, used with decentralised
+	// authentication.
+	CredsFile string
+
+	// NkeySeedFile represents the path of the file containing the NKey seed of the user.
+	NkeySeedFile string
+
+	// Token represents the token of the user, TokenFile the path of the file containing it.
+	Token     string
+	TokenFile string
+
+	// User represents the name of the user, authenticated with Password, or with the content of PasswordFile.
+	User         string
+	Password     string
+	PasswordFile string
+}
+
+// WithNatsAuth sets the credentials used by the Connector to authenticate to NATS.
+func WithNatsAuth(auth NatsAuthConfig) Option {
+	return func(o *Options) error {
+		methods := 0
+		for _, set := range []bool{auth.CredsFile != "", auth.NkeySeedFile != "", auth.Token != "" || auth.TokenFile != "",
+			auth.User != ""} {
+			if set {
+				methods++
+			}
+		}
+		if methods > 1 {
+			return ErrInvalidNatsAuth
+		}
+		if auth.Token != "" && auth.TokenFile != "" {
+			return fmt.Errorf("%w: token and tokenFile cannot be both set", ErrInvalidNatsAuth)
+		}
+		if auth.Password != "" && auth.PasswordFile != "" {
+			return fmt.Errorf("%w: password and passwordFile cannot be both set", ErrInvalidNatsAuth)
+		}
+		if auth.User == "" && (auth.Password != "" || auth.PasswordFile != "") {
+			return fmt.Errorf("%w: password requires user", ErrInvalidNatsAuth)
+		}
+		if methods == 0 {
+			return nil
+		}
+		o.natsAuth = &nats.AuthOptions{
+			CredsFile:    auth.CredsFile,
+			NkeySeedFile: auth.NkeySeedFile,
+			Token:        auth.Token,
+			TokenFile:    auth.TokenFile,
+			User:         auth.User,
+			Password:     auth.Password,
+			PasswordFile: auth.PasswordFile,
+		}
+		return nil
+	}
+}
+
+// NatsTLSConfig represents the TLS configuration of the connection to NATS.
+type NatsTLSConfig struct {
+
+	// CAFile represents the path of the CA certificates used to verify the server, instead of the system ones.
+	CAFile string
+
+	// CertFile and KeyFile represent the paths of the client certificate and key, used with mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// ServerName represents the host name expected in the server certificate. Defaults to the host of the NATS URL.
+	ServerName string
+}
+
+// WithNatsTLS enables TLS on the Connector's connection to NATS.
+func WithNatsTLS(tls NatsTLSConfig) Option {
+	return func(o *Options) error {
+		if (tls.CertFile == "") != (tls.KeyFile == "") {
+			return ErrInvalidNatsTLS
+		}
+		o.natsTLS = &nats.TLSOptions{
+			CAFile:     tls.CAFile,
+			CertFile:   tls.CertFile,
+			KeyFile:    tls.KeyFile,
+			ServerName: tls.ServerName,
+		}
+		return nil
+	}
+}
+
 // withNatsClient sets the Connector's NATS client implementation.
 // Used for testing.
 func withNatsClient(natsClient nats.Client) Option {
@@ -317,51 +1084,483 @@ func WithServerAddr(serverAddr string) Option {
 	}
 }
 
-// WithCollection configures a collection to be watched by the Connector, with the given options.
-func WithCollection(dbName, collName string, opts ...CollectionOption) Option {
+// WithAdminAddr sets the Connector's admin HTTP server address, where on-demand snapshots can be started. The admin
+// server does not authenticate requests, so it is only run if its address is set, and it should not be exposed.
+func WithAdminAddr(adminAddr string) Option {
 	return func(o *Options) error {
-		if dbName == "" {
-			return ErrDbNameMissing
+		if adminAddr != "" {
+			o.adminAddr = adminAddr
+		}
+		return nil
+	}
+}
+
+// BackoffConfig represents the policy used to wait before reopening a change stream after a failure, such as the
+// failure to open it, to publish a change event or to store its resume token. The backoff grows after each
+// consecutive failure of the same change stream, and it is reset once a change event is processed.
+// The same policy is used to restart a failed watcher.
+type BackoffConfig struct {
+
+	// Initial represents the time to wait after the first failure. Defaults to 1s.
+	Initial time.Duration
+
+	// Max represents the maximum time to wait. Defaults to 1m.
+	Max time.Duration
+
+	// Multiplier represents the factor the backoff is multiplied by after each consecutive failure. Defaults to 2.
+	Multiplier float64
+
+	// Jitter represents the fraction of the backoff that is randomized, between 0 and 1. Defaults to 0.2.
+	Jitter float64
+
+	// MaxAttempts represents the number of consecutive failures after which the connector gives up watching the
+	// change stream, zero means it never gives up.
+	MaxAttempts int
+}
+
+func (b BackoffConfig) policy() mongo.Backoff {
+	return mongo.Backoff{
+		Initial:     b.Initial,
+		Max:         b.Max,
+		Multiplier:  b.Multiplier,
+		Jitter:      b.Jitter,
+		MaxAttempts: b.MaxAttempts,
+	}
+}
+
+// WithBackoff sets the policy used to wait before reopening a change stream after a failure.
+// Zero values fall back to the defaults.
+func WithBackoff(backoff BackoffConfig) Option {
+	return func(o *Options) error {
+		if backoff.Initial < 0 || backoff.Max < 0 || backoff.MaxAttempts < 0 {
+			return fmt.Errorf("%w: durations and attempts cannot be negative", ErrInvalidBackoff)
 		}
-		if collName == "" {
-			return ErrCollNameMissing
+		if backoff.Multiplier != 0 && backoff.Multiplier < 1 {
+			return fmt.Errorf("%w: multiplier cannot be lower than 1", ErrInvalidBackoff)
 		}
-		coll := &collection{
-			dbName:                       dbName,
-			collName:                     collName,
-			changeStreamPreAndPostImages: defaultChangeStreamPreAndPostImages,
-			tokensDbName:                 defaultTokensDbName,
-			tokensCollName:               collName,
-			tokensCollCapped:             defaultTokensCollCapped,
-			tokensCollSizeInBytes:        defaultTokensCollSizeInBytes,
-			streamName:                   strings.ToUpper(collName),
+		if backoff.Jitter < 0 || backoff.Jitter > 1 {
+			return fmt.Errorf("%w: jitter must be between 0 and 1", ErrInvalidBackoff)
 		}
-		for _, opt := range opts {
-			if err := opt(coll); err != nil {
-				return err
-			}
+		if backoff.Initial != 0 {
+			o.backoff.Initial = backoff.Initial
 		}
-		if strings.EqualFold(coll.dbName, coll.tokensDbName) &&
-			strings.EqualFold(coll.collName, coll.tokensCollName) {
-			return ErrInvalidDbAndCollNames
+		if backoff.Max != 0 {
+			o.backoff.Max = backoff.Max
+		}
+		if backoff.Multiplier != 0 {
+			o.backoff.Multiplier = backoff.Multiplier
+		}
+		if backoff.Jitter != 0 {
+			o.backoff.Jitter = backoff.Jitter
+		}
+		o.backoff.MaxAttempts = backoff.MaxAttempts
+		if o.backoff.Max < o.backoff.Initial {
+			return fmt.Errorf("%w: max cannot be lower than initial", ErrInvalidBackoff)
 		}
-		o.collections = append(o.collections, coll)
 		return nil
 	}
 }
 
-type collection struct {
-	dbName                       string
-	collName                     string
-	changeStreamPreAndPostImages bool
+// WithResetTokens makes the Connector ignore the stored resume tokens of the given namespaces once it starts, so that
+// they are watched from their start position again, to replay or skip a window of changes. Namespaces are given as
+// 'db.coll' for collections, 'db' for databases and '*' for the cluster. The tokens stored afterwards are used as
+// usual, so the option should not be kept across restarts.
+func WithResetTokens(namespaces ...string) Option {
+	return func(o *Options) error {
+		o.resetTokens = append(o.resetTokens, namespaces...)
+		return nil
+	}
+}
+
+// applyResetTokens marks the collections whose stored resume tokens are reset, once all the options have been applied.
+func (o *Options) applyResetTokens() error {
+	for _, namespace := range o.resetTokens {
+		i := slices.IndexFunc(o.collections, func(coll *collection) bool {
+			return coll.namespace() == namespace
+		})
+		if i < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidResetTokens, namespace)
+		}
+		o.collections[i].resetTokens = true
+	}
+	return nil
+}
+
+// WithCollection configures a collection to be watched by the Connector, with the given options.
+func WithCollection(dbName, collName string, opts ...CollectionOption) Option {
+	return func(o *Options) error {
+		coll, err := newCollection(dbName, collName, opts...)
+		if err != nil {
+			return err
+		}
+		o.collections = append(o.collections, coll)
+		return nil
+	}
+}
+
+// newCollection creates a collection to be watched, with the given options.
+func newCollection(dbName, collName string, opts ...CollectionOption) (*collection, error) {
+	if dbName == "" {
+		return nil, ErrDbNameMissing
+	}
+	if collName == "" {
+		return nil, ErrCollNameMissing
+	}
+	coll := &collection{
+		dbName:                       dbName,
+		collName:                     collName,
+		changeStreamPreAndPostImages: defaultChangeStreamPreAndPostImages,
+		tokensStore:                  defaultTokensStore,
+		tokensDbName:                 defaultTokensDbName,
+		tokensCollName:               collName,
+		tokensCollCapped:             defaultTokensCollCapped,
+		tokensCollSizeInBytes:        defaultTokensCollSizeInBytes,
+		streamName:                   strings.ToUpper(collName),
+		failurePolicy:                defaultFailurePolicy,
+		snapshot:                     defaultSnapshot,
+		onInvalidate:                 defaultOnInvalidate,
+		onHistoryLost:                defaultOnHistoryLost,
+		fullDocument:                 defaultFullDocument,
+		fullDocumentBeforeChange:     defaultFullDocumentBeforeChange,
+	}
+	for _, opt := range opts {
+		if err := opt(coll); err != nil {
+			return nil, err
+		}
+	}
+	if coll.tokensStore == MongoTokensStore &&
+		strings.EqualFold(coll.dbName, coll.tokensDbName) &&
+		strings.EqualFold(coll.collName, coll.tokensCollName) {
+		return nil, ErrInvalidDbAndCollNames
+	}
+	if err := coll.validate(); err != nil {
+		return nil, err
+	}
+	return coll, nil
+}
+
+// CollectionPatternConfig represents the collections of a database to be discovered and watched while the Connector
+// runs, whose names match a pattern.
+type CollectionPatternConfig struct {
+
+	// DbName represents the database whose collections are discovered.
+	DbName string
+
+	// CollNamePattern represents the pattern of the collection names, a glob such as 'customer_*', or a regular
+	// expression enclosed in slashes such as '/^customer_[0-9]+$/'.
+	CollNamePattern string
+
+	// StreamNameTemplate represents the text/template of the stream name of each discovered collection, for example
+	// CUSTOMER_{{upper .CollName}}. Defaults to the upper-cased collection name.
+	StreamNameTemplate string
+
+	// TokensCollNameTemplate represents the text/template of the name of the collection or key storing the resume
+	// tokens of each discovered collection, for example {{.CollName}}-tokens. Defaults to the collection name.
+	TokensCollNameTemplate string
+
+	// DiscoveryInterval represents how often the collections of the database are listed. Defaults to 30s.
+	DiscoveryInterval time.Duration
+}
+
+// WithCollectionPattern configures the collections of a database whose names match the given pattern to be watched by
+// the Connector, with the given options. The collections are listed periodically: the new ones matching the pattern
+// start being watched, and the dropped ones stop being watched. Collections that are already watched, or that store
+// the resume tokens or dead letters of a watched collection, are skipped.
+// The templates of the pattern can reference {{.DbName}} and {{.CollName}}, along with the upper and lower functions.
+func WithCollectionPattern(pattern CollectionPatternConfig, opts ...CollectionOption) Option {
+	return func(o *Options) error {
+		if pattern.DbName == "" {
+			return ErrDbNameMissing
+		}
+		p, err := newCollectionPattern(pattern, opts...)
+		if err != nil {
+			return err
+		}
+		o.collectionPatterns = append(o.collectionPatterns, p)
+		return nil
+	}
+}
+
+// WithDatabase configures a whole database to be watched by the Connector, with the given options.
+// Change events are published on subjects that include the name of the collection they belong to, for example
+// 'STREAM.coll1.insert'.
+func WithDatabase(dbName string, opts ...CollectionOption) Option {
+	return func(o *Options) error {
+		if dbName == "" {
+			return ErrDbNameMissing
+		}
+		coll := &collection{
+			dbName:                   dbName,
+			tokensStore:              defaultTokensStore,
+			tokensDbName:             defaultTokensDbName,
+			tokensCollName:           dbName,
+			tokensCollCapped:         defaultTokensCollCapped,
+			tokensCollSizeInBytes:    defaultTokensCollSizeInBytes,
+			streamName:               strings.ToUpper(dbName),
+			failurePolicy:            defaultFailurePolicy,
+			snapshot:                 defaultSnapshot,
+			onInvalidate:             defaultOnInvalidate,
+			onHistoryLost:            defaultOnHistoryLost,
+			fullDocument:             defaultFullDocument,
+			fullDocumentBeforeChange: defaultFullDocumentBeforeChange,
+		}
+		for _, opt := range opts {
+			if err := opt(coll); err != nil {
+				return err
+			}
+		}
+		if err := coll.validate(); err != nil {
+			return err
+		}
+		o.collections = append(o.collections, coll)
+		return nil
+	}
+}
+
+// WithCluster configures the whole MongoDB deployment to be watched by the Connector, with the given options.
+// Change events are published on subjects that include the name of the database and of the collection they belong to,
+// for example 'STREAM.db1.coll1.insert'.
+func WithCluster(opts ...CollectionOption) Option {
+	return func(o *Options) error {
+		coll := &collection{
+			tokensStore:              defaultTokensStore,
+			tokensDbName:             defaultTokensDbName,
+			tokensCollName:           defaultClusterTokensCollName,
+			tokensCollCapped:         defaultTokensCollCapped,
+			tokensCollSizeInBytes:    defaultTokensCollSizeInBytes,
+			streamName:               defaultClusterStreamName,
+			failurePolicy:            defaultFailurePolicy,
+			snapshot:                 defaultSnapshot,
+			onInvalidate:             defaultOnInvalidate,
+			onHistoryLost:            defaultOnHistoryLost,
+			fullDocument:             defaultFullDocument,
+			fullDocumentBeforeChange: defaultFullDocumentBeforeChange,
+		}
+		for _, opt := range opts {
+			if err := opt(coll); err != nil {
+				return err
+			}
+		}
+		if err := coll.validate(); err != nil {
+			return err
+		}
+		o.collections = append(o.collections, coll)
+		return nil
+	}
+}
+
+// collection represents a watched collection, or a watched database if collName is empty, or the whole watched
+// cluster if dbName is empty as well.
+type collection struct {
+	dbName                       string
+	collName                     string
+	changeStreamPreAndPostImages bool
+	tokensStore                  string
 	tokensDbName                 string
 	tokensCollName               string
 	tokensCollCapped             bool
 	tokensCollSizeInBytes        int64
 	streamName                   string
+	streamConfig                 StreamConfig
+	changeStreamConfig           ChangeStreamConfig
+	publishConfig                PublishConfig
+	checkpoint                   CheckpointConfig
+	collation                    *options.Collation
+	pipeline                     []bson.D
+	subjectTemplate              *mongo.SubjectTemplate
+	staticHeaders                map[string]string
+	operationTypes               []string
+	deadLetter                   *DeadLetterConfig
+	failurePolicy                string
+	snapshot                     string
+	onInvalidate                 string
+	onHistoryLost                string
+	fullDocument                 string
+	fullDocumentBeforeChange     string
+	startAt                      *mongo.StartAt
+	resetTokens                  bool
+	discovered                   bool
+}
+
+// validate checks the options that depend on each other, once they have all been applied.
+func (c *collection) validate() error {
+	if c.subjectTemplate != nil {
+		// change events would not be captured by the stream, unless their subjects start with the stream name
+		subj, err := c.subjectTemplate.Execute(c.streamName, nil)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubjectTemplate, err)
+		}
+		if !strings.HasPrefix(subj, c.streamName+".") {
+			return fmt.Errorf("%w: got %v", ErrInvalidSubjectTemplate, subj)
+		}
+	}
+	if c.tokensStore == StreamTokensStore {
+		// the last message of the stream must never be removed, or the connector would silently resume from now
+		config := c.streamConfig
+		if (config.Retention != "" && config.Retention != "limits") || config.MaxAge > 0 || config.MaxBytes > 0 ||
+			config.MaxMsgs > 0 {
+			return ErrInvalidStreamTokensStore
+		}
+	}
+	if c.snapshot != NeverSnapshot && c.collName == "" {
+		// databases and clusters have no single collection to scan
+		return ErrInvalidSnapshot
+	}
+	if c.onHistoryLost == ResnapshotOnHistoryLost && c.collName == "" {
+		// databases and clusters have no single collection to scan
+		return ErrInvalidOnHistoryLost
+	}
+	if c.startAt != nil && c.snapshot != NeverSnapshot {
+		// the change stream starts where the snapshot was taken
+		return ErrInvalidStartAt
+	}
+	if c.onInvalidate == FollowRenameOnInvalidate && c.collName == "" {
+		// renaming a collection does not invalidate the change stream of its database or cluster
+		return ErrInvalidOnInvalidate
+	}
+	if c.onInvalidate == FollowRenameOnInvalidate && c.tokensStore == StreamTokensStore {
+		// the stream cannot store the followed rename, which would be lost once restarted
+		return ErrInvalidOnInvalidate
+	}
+	if c.deadLetter != nil {
+		// the defaults depend on the collection, so they are set on its own copy of the config
+		dl := *c.deadLetter
+		if dl.StreamName == "" {
+			dl.StreamName = c.streamName + "_DLQ"
+		}
+		if dl.CollName == "" {
+			dl.CollName = c.tokensCollName
+		}
+		c.deadLetter = &dl
+		if dl.Store == NatsDeadLetterStore && dl.StreamName == c.streamName {
+			return fmt.Errorf("%w: dead letter stream cannot be the watched collection's stream", ErrInvalidDeadLetter)
+		}
+		if dl.Store == MongoDeadLetterStore && dl.DbName == c.dbName && dl.CollName == c.collName {
+			return fmt.Errorf("%w: dead letter collection cannot be the watched collection", ErrInvalidDeadLetter)
+		}
+	}
+	return nil
+}
+
+// namespace returns the watched namespace: 'db.coll' for a collection, 'db' for a database and '*' for the cluster.
+func (c *collection) namespace() string {
+	switch {
+	case c.dbName == "":
+		return "*"
+	case c.collName == "":
+		return c.dbName
+	default:
+		return c.dbName + "." + c.collName
+	}
+}
+
+// storeNamespaces returns the namespaces of the MongoDB collections storing the resume tokens and the dead letters of
+// the watched collection, if any.
+func (c *collection) storeNamespaces() []string {
+	var namespaces []string
+	if c.tokensStore == MongoTokensStore {
+		namespaces = append(namespaces, c.tokensDbName+"."+c.tokensCollName)
+	}
+	if c.deadLetter != nil && c.deadLetter.Store == MongoDeadLetterStore {
+		namespaces = append(namespaces, c.deadLetter.DbName+"."+c.deadLetter.CollName)
+	}
+	return namespaces
+}
+
+// watchPipeline returns the pipeline of the change stream, excluding the dead letter collection if it may be part of
+// the watched namespace.
+func (c *collection) watchPipeline() []bson.D {
+	if c.collName != "" || c.deadLetter == nil || c.deadLetter.Store != MongoDeadLetterStore {
+		return c.pipeline
+	}
+	excludeDeadLetters := bson.D{{Key: "$match", Value: bson.D{{Key: "$nor", Value: bson.A{bson.D{
+		{Key: "ns.db", Value: c.deadLetter.DbName},
+		{Key: "ns.coll", Value: c.deadLetter.CollName},
+	}}}}}}
+	return append([]bson.D{excludeDeadLetters}, c.pipeline...)
+}
+
+// minMongoMajorVersion returns the MongoDB major version required by the full document settings and the expanded
+// events, or 0 if any version is supported. The whenAvailable pre-image is accepted by older versions, which never have
+// one available.
+func (c *collection) minMongoMajorVersion() int {
+	var minMajorVersion int
+	if c.fullDocument == WhenAvailableFullDocument || c.fullDocument == RequiredFullDocument ||
+		c.fullDocumentBeforeChange == RequiredFullDocumentBeforeChange {
+		minMajorVersion = minFullDocumentMajorVersion
+	}
+	if c.showExpandedEvents() {
+		minMajorVersion = max(minMajorVersion, minExpandedEventsMajorVersion)
+	}
+	return minMajorVersion
 }
 
-// CollectionOption is used to configure a MongoDB collection to be watched.
+// showExpandedEvents returns whether showExpandedEvents is enabled, or any of the operation types to publish is only
+// reported by MongoDB with showExpandedEvents.
+func (c *collection) showExpandedEvents() bool {
+	return c.changeStreamConfig.ShowExpandedEvents || slices.ContainsFunc(c.operationTypes, func(operationType string) bool {
+		return operationTypes[operationType]
+	})
+}
+
+// publishOptions returns the message published for the given change event, which carries its resume token if the
+// tokens are read from the stream.
+func (c *collection) publishOptions(event *mongo.ChangeEvent) *nats.PublishOptions {
+	publishOpts := &nats.PublishOptions{
+		Subj:    event.Subj,
+		MsgId:   event.Id,
+		Data:    event.Data,
+		Headers: c.headers(event),
+	}
+	if c.tokensStore == StreamTokensStore {
+		publishOpts.ResumeToken = event.Id
+	}
+	return publishOpts
+}
+
+// headers returns the headers of the message published for the given change event: the static headers, followed by
+// the change event metadata.
+func (c *collection) headers(event *mongo.ChangeEvent) map[string]string {
+	headers := maps.Clone(c.staticHeaders)
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+	metadata := map[string]string{
+		nats.OperationTypeHdr: event.OperationType,
+		nats.DbHdr:            event.DbName,
+		nats.CollHdr:          event.CollName,
+		nats.DocumentKeyHdr:   event.DocumentKey,
+		nats.ClusterTimeHdr:   event.ClusterTime,
+	}
+	if !event.WallTime.IsZero() {
+		metadata[nats.WallTimeHdr] = event.WallTime.Format(time.RFC3339Nano)
+	}
+	for key, value := range metadata {
+		if value != "" {
+			headers[key] = value
+		}
+	}
+	return headers
+}
+
+// streamSubjects returns the subjects of the stream, with one wildcard for the operation type plus one for each
+// namespace component that is not fixed by the watched collection, or a full wildcard if the subject is templated.
+func (c *collection) streamSubjects() []string {
+	if c.subjectTemplate != nil {
+		// the number of tokens of a templated subject is not known in advance
+		return []string{c.streamName + ".>"}
+	}
+	subj := c.streamName
+	if c.dbName == "" {
+		subj += ".*"
+	}
+	if c.collName == "" {
+		subj += ".*"
+	}
+	return []string{subj + ".*"}
+}
+
+// CollectionOption is used to configure a MongoDB collection, database or cluster to be watched.
 type CollectionOption func(*collection) error
 
 // WithChangeStreamPreAndPostImages enables MongoDB's changeStreamPreAndPostImages configuration.
@@ -374,6 +1573,164 @@ func WithChangeStreamPreAndPostImages() CollectionOption {
 	}
 }
 
+// WithTokensStore sets where the resume tokens for the collection to be watched will be stored, one of
+// MongoTokensStore (the default), NatsTokensStore or StreamTokensStore.
+// With NatsTokensStore the resume tokens are stored in the NATS key-value bucket named after the tokens database
+// name, under the key named after the tokens collection name, and no MongoDB collection is created for them.
+// With StreamTokensStore the resume tokens are stored in the Mongo-Resume-Token header of the published messages, and
+// the connector resumes after the last message of the stream, so no separate write is needed. The stream must then
+// keep its messages: its retention must be "limits", without any max age, bytes or messages. The change events that
+// are not published on the stream, such as the dead-lettered ones, do not advance the position, and they are handled
+// again after a restart until a following change event is published.
+func WithTokensStore(tokensStore string) CollectionOption {
+	return func(c *collection) error {
+		switch tokensStore {
+		case "":
+		case MongoTokensStore, NatsTokensStore, StreamTokensStore:
+			c.tokensStore = tokensStore
+		default:
+			return ErrInvalidTokensStore
+		}
+		return nil
+	}
+}
+
+// WithFailurePolicy sets what happens when the watcher of the collection to be watched fails, one of
+// RestartFailurePolicy (the default), StopFailurePolicy or ExitFailurePolicy.
+func WithFailurePolicy(failurePolicy string) CollectionOption {
+	return func(c *collection) error {
+		switch failurePolicy {
+		case "":
+		case RestartFailurePolicy, StopFailurePolicy, ExitFailurePolicy:
+			c.failurePolicy = failurePolicy
+		default:
+			return ErrInvalidFailurePolicy
+		}
+		return nil
+	}
+}
+
+// WithSnapshot sets when the existing documents of the collection to be watched are published, one of NeverSnapshot
+// (the default), InitialSnapshot or AlwaysSnapshot. The documents are published as change events with the 'snapshot'
+// operation type, before the change stream starts at the operation time captured before scanning them.
+func WithSnapshot(snapshot string) CollectionOption {
+	return func(c *collection) error {
+		switch snapshot {
+		case "":
+		case NeverSnapshot, InitialSnapshot, AlwaysSnapshot:
+			c.snapshot = snapshot
+		default:
+			return ErrInvalidSnapshot
+		}
+		return nil
+	}
+}
+
+// WithOperationTypes sets the operation types of the change events to be published for the collection to be watched.
+// By default only insert, update, replace and delete events are published. DDL events such as drop, rename,
+// dropDatabase and invalidate can be published as well, so that consumers know when the collection disappears, and
+// create, createIndexes, dropIndexes, modify, shardCollection, refineCollectionShardKey and reshardCollection require
+// MongoDB 6.0, as they are only reported with showExpandedEvents.
+// Once an invalidate event is received the collection is no longer watched, unless WithOnInvalidate says otherwise.
+func WithOperationTypes(types ...string) CollectionOption {
+	return func(c *collection) error {
+		for _, operationType := range types {
+			if _, ok := operationTypes[operationType]; !ok {
+				return fmt.Errorf("%w: %v", ErrInvalidOperationTypes, operationType)
+			}
+		}
+		if len(types) > 0 {
+			c.operationTypes = slices.Compact(slices.Sorted(slices.Values(types)))
+		}
+		return nil
+	}
+}
+
+// WithOnInvalidate sets what happens once the change stream of the collection to be watched is invalidated, e.g.
+// after the collection is dropped or renamed, one of StopOnInvalidate (the default), RestartOnInvalidate or
+// FollowRenameOnInvalidate. The change stream is reopened after the invalidate event, so that a recreated collection
+// keeps being watched, or at the time of the rename on the new name of the collection, which is stored along with the
+// resume tokens and keeps being watched after a restart.
+func WithOnInvalidate(onInvalidate string) CollectionOption {
+	return func(c *collection) error {
+		switch onInvalidate {
+		case "":
+		case StopOnInvalidate, RestartOnInvalidate, FollowRenameOnInvalidate:
+			c.onInvalidate = onInvalidate
+		default:
+			return ErrInvalidOnInvalidate
+		}
+		return nil
+	}
+}
+
+// WithOnHistoryLost sets what happens when the change stream of the collection to be watched cannot be resumed, because
+// its resume token is no longer in the oplog, e.g. after a long outage, one of FailOnHistoryLost (the default),
+// RestartFromNowOnHistoryLost or ResnapshotOnHistoryLost.
+func WithOnHistoryLost(onHistoryLost string) CollectionOption {
+	return func(c *collection) error {
+		switch onHistoryLost {
+		case "":
+		case FailOnHistoryLost, RestartFromNowOnHistoryLost, ResnapshotOnHistoryLost:
+			c.onHistoryLost = onHistoryLost
+		default:
+			return ErrInvalidOnHistoryLost
+		}
+		return nil
+	}
+}
+
+// WithFullDocument sets whether the change events of the collection to be watched carry the full document, one of
+// DefaultFullDocument, UpdateLookupFullDocument (the default), WhenAvailableFullDocument or RequiredFullDocument.
+// DefaultFullDocument avoids looking up the document for each update, whose change events then only carry the
+// updated fields.
+func WithFullDocument(fullDocument string) CollectionOption {
+	return func(c *collection) error {
+		switch fullDocument {
+		case "":
+		case DefaultFullDocument, UpdateLookupFullDocument, WhenAvailableFullDocument, RequiredFullDocument:
+			c.fullDocument = fullDocument
+		default:
+			return ErrInvalidFullDocument
+		}
+		return nil
+	}
+}
+
+// WithFullDocumentBeforeChange sets whether the change events of the collection to be watched carry the document
+// before the change, one of OffFullDocumentBeforeChange, WhenAvailableFullDocumentBeforeChange (the default) or
+// RequiredFullDocumentBeforeChange. Pre-images require changeStreamPreAndPostImages to be enabled on the collection.
+func WithFullDocumentBeforeChange(fullDocumentBeforeChange string) CollectionOption {
+	return func(c *collection) error {
+		switch fullDocumentBeforeChange {
+		case "":
+		case OffFullDocumentBeforeChange, WhenAvailableFullDocumentBeforeChange, RequiredFullDocumentBeforeChange:
+			c.fullDocumentBeforeChange = fullDocumentBeforeChange
+		default:
+			return ErrInvalidFullDocumentBeforeChange
+		}
+		return nil
+	}
+}
+
+// WithStartAt sets where the change stream of the collection to be watched starts if no resume token is stored, or
+// if the stored ones are reset: LatestStartAt (the default), EarliestStartAt, an RFC 3339 timestamp, which is mapped to
+// the operation time of that second, or a resume token, which the change stream starts after. It cannot be set along
+// with a snapshot.
+func WithStartAt(startAt string) CollectionOption {
+	return func(c *collection) error {
+		if startAt == "" {
+			return nil
+		}
+		parsed, err := mongo.ParseStartAt(startAt)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidStartAt, err)
+		}
+		c.startAt = parsed
+		return nil
+	}
+}
+
 // WithTokensDbName sets the name of the MongoDB database that will store the resume tokens collection for the
 // collection to be watched.
 func WithTokensDbName(tokensDbName string) CollectionOption {
@@ -419,3 +1776,281 @@ func WithStreamName(streamName string) CollectionOption {
 		return nil
 	}
 }
+
+// WithSubjectTemplate sets the text/template used to build the subject of each change event of the collection to be
+// watched, for example: {{.Stream}}.{{.Db}}.{{.Coll}}.{{.OperationType}}.{{.FullDocument.tenantId}}.
+// The template must start with the stream name, and the characters not allowed in subjects are replaced with
+// underscores. The stream will capture all the subjects under the stream name.
+func WithSubjectTemplate(subjectTemplate string) CollectionOption {
+	return func(c *collection) error {
+		if subjectTemplate == "" {
+			return nil
+		}
+		tmpl, err := mongo.NewSubjectTemplate(subjectTemplate)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSubjectTemplate, err)
+		}
+		c.subjectTemplate = tmpl
+		return nil
+	}
+}
+
+// WithHeaders sets static headers to be added to every message published for the collection to be watched, in
+// addition to the headers containing the change event metadata, which cannot be overridden.
+func WithHeaders(headers map[string]string) CollectionOption {
+	return func(c *collection) error {
+		for key := range headers {
+			if key == "" || strings.ContainsFunc(key, func(r rune) bool { return r == ':' || unicode.IsSpace(r) }) {
+				return ErrInvalidHeaders
+			}
+		}
+		if len(headers) > 0 {
+			c.staticHeaders = headers
+		}
+		return nil
+	}
+}
+
+// StreamConfig represents the configuration of the NATS stream where the change events of a watched collection are
+// published. Zero values fall back to the NATS server defaults, except for Storage which defaults to "file".
+type StreamConfig struct {
+
+	// Storage can be set to "file" or "memory".
+	Storage string
+
+	// Retention can be set to "limits", "interest" or "workqueue".
+	Retention string
+
+	// Replicas represents the number of replicas of the stream, in clustered mode.
+	Replicas int
+
+	// MaxAge represents the maximum age of the messages in the stream.
+	MaxAge time.Duration
+
+	// MaxBytes represents the maximum size of the stream.
+	MaxBytes int64
+
+	// MaxMsgs represents the maximum number of messages in the stream.
+	MaxMsgs int64
+
+	// Discard can be set to "old" or "new", it tells what to do once the stream limits are reached.
+	Discard string
+
+	// DuplicateWindow represents the window within which messages with the same msg id are discarded.
+	DuplicateWindow time.Duration
+}
+
+// WithStreamConfig sets the configuration of the NATS stream, where the MongoDB change events will be published for
+// the collection to be watched. If the stream already exists its configuration is updated, but its storage and
+// retention cannot be changed.
+func WithStreamConfig(streamConfig StreamConfig) CollectionOption {
+	return func(c *collection) error {
+		if !slices.Contains([]string{"", "file", "memory"}, streamConfig.Storage) {
+			return fmt.Errorf("%w: unknown storage %v", ErrInvalidStreamConfig, streamConfig.Storage)
+		}
+		if !slices.Contains([]string{"", "limits", "interest", "workqueue"}, streamConfig.Retention) {
+			return fmt.Errorf("%w: unknown retention %v", ErrInvalidStreamConfig, streamConfig.Retention)
+		}
+		if !slices.Contains([]string{"", "old", "new"}, streamConfig.Discard) {
+			return fmt.Errorf("%w: unknown discard policy %v", ErrInvalidStreamConfig, streamConfig.Discard)
+		}
+		if streamConfig.Replicas < 0 || streamConfig.MaxAge < 0 || streamConfig.MaxBytes < 0 ||
+			streamConfig.MaxMsgs < 0 || streamConfig.DuplicateWindow < 0 {
+			return fmt.Errorf("%w: limits cannot be negative", ErrInvalidStreamConfig)
+		}
+		c.streamConfig = streamConfig
+		return nil
+	}
+}
+
+// WithPipeline sets the aggregation pipeline used to filter and transform the change events of the collection to be
+// watched, directly on MongoDB. The pipeline must be a json array of aggregation stages, written in MongoDB Extended
+// JSON, for example: [{"$match": {"operationType": "insert"}}].
+func WithPipeline(pipeline string) CollectionOption {
+	return func(c *collection) error {
+		if pipeline == "" {
+			return nil
+		}
+		stages := make([]bson.D, 0)
+		if err := bson.UnmarshalExtJSON([]byte(pipeline), false, &stages); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPipeline, err)
+		}
+		for _, stage := range stages {
+			if len(stage) != 1 {
+				return fmt.Errorf("%w: each stage must contain exactly one field", ErrInvalidPipeline)
+			}
+			if _, ok := changeStreamStages[stage[0].Key]; !ok {
+				return fmt.Errorf("%w: stage %v is not allowed", ErrInvalidPipeline, stage[0].Key)
+			}
+		}
+		c.pipeline = stages
+		return nil
+	}
+}
+
+// DeadLetterConfig represents how the change events of a watched collection that cannot be published are handled.
+// A change event is retried up to MaxRetries times, waiting an exponential backoff between retries, and it is then
+// stored along with the error that prevented its publication, so that the collection can move on.
+type DeadLetterConfig struct {
+
+	// Store can be set to "nats" or "mongo".
+	Store string
+
+	// StreamName represents the name of the NATS stream where dead letters are published, if stored on NATS.
+	// Dead letters keep the subject of the change event, with the stream name replaced. Defaults to the stream name
+	// followed by "_DLQ".
+	StreamName string
+
+	// DbName represents the name of the MongoDB database where dead letters are stored, if stored on MongoDB.
+	// Defaults to "dead-letters".
+	DbName string
+
+	// CollName represents the name of the MongoDB collection where dead letters are stored, if stored on MongoDB.
+	// Defaults to the name of the resume tokens collection.
+	CollName string
+
+	// MaxRetries represents the number of times the publication of a change event is retried, zero means no retries.
+	MaxRetries int
+
+	// RetryBackoff represents the time to wait before the first retry, doubled at each retry. Defaults to 1s.
+	RetryBackoff time.Duration
+
+	// MaxRetryBackoff represents the maximum time to wait between retries. Defaults to 1m.
+	MaxRetryBackoff time.Duration
+}
+
+// WithDeadLetter enables dead letters for the collection to be watched, so that a change event that cannot be
+// published, for example because it exceeds the NATS max payload, does not stall the collection indefinitely.
+func WithDeadLetter(deadLetter DeadLetterConfig) CollectionOption {
+	return func(c *collection) error {
+		if !slices.Contains([]string{NatsDeadLetterStore, MongoDeadLetterStore}, deadLetter.Store) {
+			return fmt.Errorf("%w: unknown store %v", ErrInvalidDeadLetter, deadLetter.Store)
+		}
+		if deadLetter.MaxRetries < 0 || deadLetter.RetryBackoff < 0 || deadLetter.MaxRetryBackoff < 0 {
+			return fmt.Errorf("%w: retries cannot be negative", ErrInvalidDeadLetter)
+		}
+		// the option may be applied to many collections, discovered by a pattern, each with its own config
+		dl := deadLetter
+		if dl.DbName == "" {
+			dl.DbName = defaultDeadLetterDbName
+		}
+		if dl.RetryBackoff == 0 {
+			dl.RetryBackoff = defaultDeadLetterRetryBackoff
+		}
+		if dl.MaxRetryBackoff == 0 {
+			dl.MaxRetryBackoff = max(defaultDeadLetterMaxRetryBackoff, dl.RetryBackoff)
+		}
+		if dl.MaxRetryBackoff < dl.RetryBackoff {
+			return fmt.Errorf("%w: max retry backoff cannot be lower than retry backoff", ErrInvalidDeadLetter)
+		}
+		c.deadLetter = &dl
+		return nil
+	}
+}
+
+// PublishConfig represents how the change events of a watched collection are published.
+type PublishConfig struct {
+
+	// Mode can be set to "sync" or "async". Defaults to "sync".
+	// With "async", change events are published without waiting for their acknowledgement, which is much faster, and
+	// the resume token of the highest contiguous acknowledged change event is stored. Once a change event is not
+	// acknowledged, the change stream is reopened before it: it is published again synchronously, retried and
+	// dead-lettered as configured, and then the following change events are published again, in order. Duplicates are
+	// discarded by NATS within the duplicate window of the stream.
+	Mode string
+
+	// MaxPending represents the maximum number of change events awaiting their acknowledgement, in "async" mode.
+	// Defaults to 256.
+	MaxPending int
+}
+
+// WithPublishConfig sets how the change events of the collection to be watched are published.
+func WithPublishConfig(publishConfig PublishConfig) CollectionOption {
+	return func(c *collection) error {
+		publishConfig.Mode = strings.ToLower(cmp.Or(publishConfig.Mode, SyncPublishMode))
+		if publishConfig.Mode != SyncPublishMode && publishConfig.Mode != AsyncPublishMode {
+			return ErrInvalidPublishConfig
+		}
+		if publishConfig.MaxPending < 0 {
+			return ErrInvalidPublishConfig
+		}
+		if publishConfig.Mode == AsyncPublishMode && publishConfig.MaxPending == 0 {
+			publishConfig.MaxPending = defaultPublishMaxPending
+		}
+		c.publishConfig = publishConfig
+		return nil
+	}
+}
+
+// CheckpointConfig represents how often the resume token of a watched collection is stored. The resume token is
+// stored after every change event unless any of the fields is set, and anyway once the change stream is idle or closed,
+// including on graceful shutdown. If the connector does not shut down gracefully, up to Every change events, or the
+// change events of the last Interval, are published again once it restarts. Duplicates are discarded by NATS within
+// the duplicate window of the stream.
+type CheckpointConfig struct {
+
+	// Every represents the number of change events after which the resume token is stored.
+	Every int
+
+	// Interval represents the time after which the resume token is stored.
+	Interval time.Duration
+}
+
+// WithCheckpoint sets how often the resume token of the collection to be watched is stored, trading the number of
+// writes to the token store for the number of change events published again after a crash.
+func WithCheckpoint(checkpoint CheckpointConfig) CollectionOption {
+	return func(c *collection) error {
+		if checkpoint.Every < 0 || checkpoint.Interval < 0 {
+			return ErrInvalidCheckpoint
+		}
+		c.checkpoint = checkpoint
+		return nil
+	}
+}
+
+// ChangeStreamConfig represents the tuning of the change stream of a watched collection. Zero values fall back to the
+// MongoDB server defaults.
+type ChangeStreamConfig struct {
+
+	// BatchSize represents the maximum number of change events returned by MongoDB in each batch.
+	BatchSize int32
+
+	// MaxAwaitTime represents the maximum time MongoDB waits for new change events before returning an empty batch.
+	MaxAwaitTime time.Duration
+
+	// Collation represents the collation used to compare strings in the pipeline, as a json document, for example:
+	// {"locale": "en", "strength": 2}.
+	Collation string
+
+	// ShowExpandedEvents enables the change events of DDL operations such as create or createIndexes, along with the
+	// chunk migrations of a sharded cluster, requires MongoDB 6.0. It is enabled anyway if any of the operation types to
+	// publish requires it.
+	ShowExpandedEvents bool
+
+	// Comment represents a comment attached to the change stream, shown by the MongoDB profiler and currentOp.
+	Comment string
+}
+
+// WithChangeStreamConfig sets the tuning of the change stream of the collection to be watched, such as the batch size
+// and the maximum time to wait for new change events, which trade throughput for latency.
+func WithChangeStreamConfig(changeStreamConfig ChangeStreamConfig) CollectionOption {
+	return func(c *collection) error {
+		if changeStreamConfig.BatchSize < 0 || changeStreamConfig.MaxAwaitTime < 0 {
+			return fmt.Errorf("%w: batch size and max await time cannot be negative", ErrInvalidChangeStreamConfig)
+		}
+		if changeStreamConfig.Collation != "" {
+			collation := &options.Collation{}
+			decoder := json.NewDecoder(strings.NewReader(changeStreamConfig.Collation))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(collation); err != nil {
+				return fmt.Errorf("%w: collation: %v", ErrInvalidChangeStreamConfig, err)
+			}
+			if collation.Locale == "" {
+				return fmt.Errorf("%w: collation locale is missing", ErrInvalidChangeStreamConfig)
+			}
+			c.collation = collation
+		}
+		c.changeStreamConfig = changeStreamConfig
+		return nil
+	}
+}
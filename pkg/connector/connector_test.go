@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"maps"
+	"reflect"
 	"slices"
 	"strings"
 	"sync"
@@ -12,9 +14,12 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/damianiandrea/mongodb-nats-connector/internal/mongo"
 	"github.com/damianiandrea/mongodb-nats-connector/internal/nats"
+	"github.com/damianiandrea/mongodb-nats-connector/internal/server"
 )
 
 func TestNew(t *testing.T) {
@@ -38,6 +43,7 @@ func TestNew(t *testing.T) {
 		require.NotNil(t, conn.options.ctx)
 		require.NotNil(t, conn.options.stop)
 		require.Empty(t, conn.options.serverAddr)
+		require.Empty(t, conn.options.adminAddr)
 		require.NotNil(t, conn.logger)
 		require.NotNil(t, conn.server)
 		require.Empty(t, conn.options.collections)
@@ -74,6 +80,7 @@ func TestNew(t *testing.T) {
 			natsUrl     = "localhost:4222"
 			natsClient  = &mockNatsClient{}
 			serverAddr  = ":8080"
+			adminAddr   = "127.0.0.1:8081"
 		)
 
 		conn, err := New(
@@ -84,6 +91,7 @@ func TestNew(t *testing.T) {
 			withNatsClient(natsClient),
 			WithContext(context.TODO()),
 			WithServerAddr(serverAddr),
+			WithAdminAddr(adminAddr),
 		)
 
 		require.NoError(t, err)
@@ -95,10 +103,193 @@ func TestNew(t *testing.T) {
 		require.NotNil(t, conn.options.ctx)
 		require.NotNil(t, conn.options.stop)
 		require.Equal(t, serverAddr, conn.options.serverAddr)
+		require.Equal(t, adminAddr, conn.options.adminAddr)
 		require.NotNil(t, conn.logger)
 		require.NotNil(t, conn.server)
 		require.Empty(t, conn.options.collections)
 	})
+	t.Run("should create connector with default backoff", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, BackoffConfig{
+			Initial:    1 * time.Second,
+			Max:        1 * time.Minute,
+			Multiplier: 2,
+			Jitter:     0.2,
+		}, conn.options.backoff)
+	})
+	t.Run("should create connector with given backoff", func(t *testing.T) {
+		backoff := BackoffConfig{
+			Initial:     500 * time.Millisecond,
+			Max:         30 * time.Second,
+			Multiplier:  1.5,
+			Jitter:      0.1,
+			MaxAttempts: 10,
+		}
+
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithBackoff(backoff),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, backoff, conn.options.backoff)
+	})
+	t.Run("should return error cause backoff contains an invalid value", func(t *testing.T) {
+		for _, backoff := range []BackoffConfig{
+			{Initial: -time.Second},
+			{MaxAttempts: -1},
+			{Multiplier: 0.5},
+			{Jitter: 1.5},
+			{Initial: time.Minute, Max: time.Second},
+		} {
+			conn, err := New(WithBackoff(backoff))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidBackoff)
+		}
+	})
+	t.Run("should create connector with given mongo auth, tls and read preference", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithMongoAuth(MongoAuthConfig{Mechanism: "mongodb-x509"}),
+			WithMongoTLS(MongoTLSConfig{
+				CAFile:              "/etc/mongo/ca.pem",
+				CertKeyFile:         "/etc/mongo/client.pem",
+				CertKeyPasswordFile: "/run/secrets/mongo-key-password",
+			}),
+			WithMongoReadPreference(MongoReadPreferenceConfig{Mode: "secondaryPreferred", MaxStaleness: 2 * time.Minute}),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, &mongo.AuthOptions{Mechanism: "MONGODB-X509"}, conn.options.mongoAuth)
+		require.Equal(t, &mongo.TLSOptions{
+			CAFile:              "/etc/mongo/ca.pem",
+			CertKeyFile:         "/etc/mongo/client.pem",
+			CertKeyPasswordFile: "/run/secrets/mongo-key-password",
+		}, conn.options.mongoTLS)
+		require.Equal(t, &mongo.ReadPreferenceOptions{Mode: "secondaryPreferred", MaxStaleness: 2 * time.Minute},
+			conn.options.mongoReadPreference)
+	})
+	t.Run("should return error cause mongo auth contains an invalid value", func(t *testing.T) {
+		for _, auth := range []MongoAuthConfig{
+			{Mechanism: "MONGODB-CR"},
+			{Username: "connector", Password: "s3cr3t", PasswordFile: "password"},
+			{PasswordFile: "password"},
+		} {
+			conn, err := New(WithMongoAuth(auth))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidMongoAuth)
+		}
+	})
+	t.Run("should return error cause mongo tls sets a key password without a cert key file", func(t *testing.T) {
+		conn, err := New(WithMongoTLS(MongoTLSConfig{CertKeyPassword: "s3cr3t"}))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidMongoTLS)
+	})
+	t.Run("should return error cause mongo read preference contains an invalid value", func(t *testing.T) {
+		for _, readPreference := range []MongoReadPreferenceConfig{
+			{Mode: "fastest"},
+			{Mode: "secondary", MaxStaleness: time.Second},
+			{Mode: "primary", MaxStaleness: 2 * time.Minute},
+		} {
+			conn, err := New(WithMongoReadPreference(readPreference))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidMongoReadPreference)
+		}
+	})
+	t.Run("should create connector with given nats connection", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithNatsConnection(NatsConnectionConfig{
+				Name:             "mongodb-nats-connector",
+				Urls:             []string{"nats://nats2:4222", "", "nats://nats3:4222"},
+				MaxReconnects:    -1,
+				ReconnectWait:    5 * time.Second,
+				ReconnectJitter:  time.Second,
+				PingInterval:     30 * time.Second,
+				MaxPingsOut:      3,
+				ReconnectBufSize: 16 * 1024 * 1024,
+			}),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, &nats.ConnectionOptions{
+			Name:             "mongodb-nats-connector",
+			Servers:          []string{"nats://nats2:4222", "nats://nats3:4222"},
+			MaxReconnects:    -1,
+			ReconnectWait:    5 * time.Second,
+			ReconnectJitter:  time.Second,
+			PingInterval:     30 * time.Second,
+			MaxPingsOut:      3,
+			ReconnectBufSize: 16 * 1024 * 1024,
+		}, conn.options.natsConnection)
+	})
+	t.Run("should return error cause nats connection contains an invalid value", func(t *testing.T) {
+		for _, connection := range []NatsConnectionConfig{
+			{ReconnectWait: -time.Second},
+			{ReconnectJitter: -time.Second},
+			{PingInterval: -time.Second},
+			{MaxPingsOut: -1},
+		} {
+			conn, err := New(WithNatsConnection(connection))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidNatsConnection)
+		}
+	})
+	t.Run("should create connector with given nats auth and tls", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithNatsAuth(NatsAuthConfig{CredsFile: "/etc/nats/connector.creds"}),
+			WithNatsTLS(NatsTLSConfig{
+				CAFile:     "/etc/nats/ca.pem",
+				CertFile:   "/etc/nats/cert.pem",
+				KeyFile:    "/etc/nats/key.pem",
+				ServerName: "nats.internal",
+			}),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, &nats.AuthOptions{CredsFile: "/etc/nats/connector.creds"}, conn.options.natsAuth)
+		require.Equal(t, &nats.TLSOptions{
+			CAFile:     "/etc/nats/ca.pem",
+			CertFile:   "/etc/nats/cert.pem",
+			KeyFile:    "/etc/nats/key.pem",
+			ServerName: "nats.internal",
+		}, conn.options.natsTLS)
+	})
+	t.Run("should return error cause nats auth contains an invalid value", func(t *testing.T) {
+		for _, auth := range []NatsAuthConfig{
+			{CredsFile: "connector.creds", Token: "s3cr3t"},
+			{NkeySeedFile: "connector.nk", User: "connector"},
+			{Token: "s3cr3t", TokenFile: "token"},
+			{User: "connector", Password: "s3cr3t", PasswordFile: "password"},
+			{Password: "s3cr3t"},
+		} {
+			conn, err := New(WithNatsAuth(auth))
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidNatsAuth)
+		}
+	})
+	t.Run("should return error cause nats tls sets a cert without a key", func(t *testing.T) {
+		conn, err := New(WithNatsTLS(NatsTLSConfig{CertFile: "cert.pem"}))
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidNatsTLS)
+	})
 	t.Run("should create connector with collection defaults", func(t *testing.T) {
 		var (
 			mongoClient = &mockMongoClient{}
@@ -118,11 +309,18 @@ func TestNew(t *testing.T) {
 			dbName:                       dbName,
 			collName:                     collName,
 			changeStreamPreAndPostImages: false,
+			tokensStore:                  "mongo",
 			tokensDbName:                 "resume-tokens",
 			tokensCollName:               collName,
 			tokensCollCapped:             false,
 			tokensCollSizeInBytes:        0,
 			streamName:                   strings.ToUpper(collName),
+			failurePolicy:                "restart",
+			snapshot:                     "never",
+			onInvalidate:                 "stop",
+			onHistoryLost:                "fail",
+			fullDocument:                 "updateLookup",
+			fullDocumentBeforeChange:     "whenAvailable",
 		})
 	})
 	t.Run("should create connector with given collection options", func(t *testing.T) {
@@ -135,6 +333,8 @@ func TestNew(t *testing.T) {
 			tokensCollName  = "coll1-tokens"
 			collSizeInBytes = int64(2048)
 			streamName      = "coll1-stream"
+			streamConfig    = StreamConfig{Storage: "memory", Replicas: 3, MaxAge: time.Hour}
+			pipeline        = `[{"$match": {"operationType": "insert"}}]`
 		)
 
 		conn, err := New(
@@ -146,6 +346,8 @@ func TestNew(t *testing.T) {
 				WithTokensCollName(tokensCollName),
 				WithTokensCollCapped(collSizeInBytes),
 				WithStreamName(streamName),
+				WithStreamConfig(streamConfig),
+				WithPipeline(pipeline),
 			),
 		)
 
@@ -154,13 +356,88 @@ func TestNew(t *testing.T) {
 			dbName:                       dbName,
 			collName:                     collName,
 			changeStreamPreAndPostImages: true,
+			tokensStore:                  "mongo",
 			tokensDbName:                 tokensDbName,
 			tokensCollName:               tokensCollName,
 			tokensCollCapped:             true,
 			tokensCollSizeInBytes:        collSizeInBytes,
 			streamName:                   streamName,
+			streamConfig:                 streamConfig,
+			pipeline:                     []bson.D{{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}},
+			failurePolicy:                "restart",
+			snapshot:                     "never",
+			onInvalidate:                 "stop",
+			onHistoryLost:                "fail",
+			fullDocument:                 "updateLookup",
+			fullDocumentBeforeChange:     "whenAvailable",
+		})
+	})
+	t.Run("should create connector with database defaults", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			dbName      = "connector-db"
+		)
+
+		conn, err := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithDatabase(dbName),
+		)
+
+		require.NoError(t, err)
+		require.Contains(t, conn.options.collections, &collection{
+			dbName:                   dbName,
+			tokensStore:              "mongo",
+			tokensDbName:             "resume-tokens",
+			tokensCollName:           dbName,
+			tokensCollCapped:         false,
+			tokensCollSizeInBytes:    0,
+			streamName:               strings.ToUpper(dbName),
+			failurePolicy:            "restart",
+			snapshot:                 "never",
+			onInvalidate:             "stop",
+			onHistoryLost:            "fail",
+			fullDocument:             "updateLookup",
+			fullDocumentBeforeChange: "whenAvailable",
+		})
+	})
+	t.Run("should create connector with cluster defaults", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+		)
+
+		conn, err := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithCluster(),
+		)
+
+		require.NoError(t, err)
+		require.Contains(t, conn.options.collections, &collection{
+			tokensStore:              "mongo",
+			tokensDbName:             "resume-tokens",
+			tokensCollName:           "cluster",
+			tokensCollCapped:         false,
+			tokensCollSizeInBytes:    0,
+			streamName:               "CLUSTER",
+			failurePolicy:            "restart",
+			snapshot:                 "never",
+			onInvalidate:             "stop",
+			onHistoryLost:            "fail",
+			fullDocument:             "updateLookup",
+			fullDocumentBeforeChange: "whenAvailable",
 		})
 	})
+	t.Run("should return error cause database dbName is missing", func(t *testing.T) {
+		conn, err := New(
+			WithDatabase(""),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrDbNameMissing.Error())
+	})
 	t.Run("should return error cause dbName is missing", func(t *testing.T) {
 		conn, err := New(
 			WithCollection("", "test-coll"),
@@ -182,62 +459,1466 @@ func TestNew(t *testing.T) {
 			WithCollection("test-db", "test-coll", WithTokensCollCapped(-1)),
 		)
 
-		require.Nil(t, conn)
-		require.EqualError(t, err, ErrInvalidCollSizeInBytes.Error())
-	})
-	t.Run("should return error cause collSizeInBytes is 0", func(t *testing.T) {
-		conn, err := New(
-			WithCollection("test-db", "test-coll", WithTokensCollCapped(0)),
-		)
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidCollSizeInBytes.Error())
+	})
+	t.Run("should return error cause collSizeInBytes is 0", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithTokensCollCapped(0)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidCollSizeInBytes.Error())
+	})
+	t.Run("should create connector with nats tokens store", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			dbName      = "connector-db"
+			collName    = "coll1"
+		)
+
+		conn, err := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithCollection(dbName, collName,
+				WithTokensStore(NatsTokensStore),
+				WithTokensDbName(dbName), // allowed, tokens are not stored on mongo
+				WithTokensCollName(collName),
+			),
+		)
+
+		require.NoError(t, err)
+		require.Contains(t, conn.options.collections, &collection{
+			dbName:                   dbName,
+			collName:                 collName,
+			tokensStore:              "nats",
+			tokensDbName:             dbName,
+			tokensCollName:           collName,
+			streamName:               strings.ToUpper(collName),
+			failurePolicy:            "restart",
+			snapshot:                 "never",
+			onInvalidate:             "stop",
+			onHistoryLost:            "fail",
+			fullDocument:             "updateLookup",
+			fullDocumentBeforeChange: "whenAvailable",
+		})
+	})
+	t.Run("should return error cause tokens store is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithTokensStore("redis")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidTokensStore.Error())
+	})
+	t.Run("should return error cause stream tokens store requires a stream keeping its messages", func(t *testing.T) {
+		for _, streamConfig := range []StreamConfig{
+			{Retention: "interest"},
+			{Retention: "workqueue"},
+			{MaxAge: 24 * time.Hour},
+			{MaxBytes: 1024},
+			{MaxMsgs: 1000},
+		} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithTokensStore(StreamTokensStore),
+					WithStreamConfig(streamConfig)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidStreamTokensStore)
+		}
+	})
+	t.Run("should return error cause pipeline is not a valid json array", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithPipeline(`{"$match": {}}`)),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidPipeline)
+	})
+	t.Run("should return error cause pipeline contains a stage not allowed in change streams", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithPipeline(`[{"$group": {"_id": "$operationType"}}]`)),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidPipeline)
+	})
+	t.Run("should create connector with subject template", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+		)
+
+		conn, err := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithCollection("test-db", "test-coll",
+				WithSubjectTemplate("{{.Stream}}.{{.OperationType}}.{{.FullDocument.tenantId}}")),
+		)
+
+		require.NoError(t, err)
+		require.Len(t, conn.options.collections, 1)
+		require.NotNil(t, conn.options.collections[0].subjectTemplate)
+		require.Equal(t, []string{"TEST-COLL.>"}, conn.options.collections[0].streamSubjects())
+	})
+	t.Run("should return error cause subject template cannot be parsed", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithSubjectTemplate("{{.Stream")),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidSubjectTemplate)
+	})
+	t.Run("should return error cause subject template does not start with the stream name", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("test-db", "test-coll", WithSubjectTemplate("{{.Db}}.{{.Coll}}.{{.OperationType}}")),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidSubjectTemplate)
+	})
+	t.Run("should return error cause header names are invalid", func(t *testing.T) {
+		for _, name := range []string{"", "Invalid:Name", "Invalid Name"} {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithHeaders(map[string]string{name: "value"})),
+			)
+
+			require.Nil(t, conn)
+			require.EqualError(t, err, ErrInvalidHeaders.Error())
+		}
+	})
+	t.Run("should return error cause stream config contains an invalid value", func(t *testing.T) {
+		invalidConfigs := []StreamConfig{
+			{Storage: "disk"},
+			{Retention: "forever"},
+			{Discard: "all"},
+			{Replicas: -1},
+			{MaxAge: -time.Second},
+		}
+
+		for _, streamConfig := range invalidConfigs {
+			conn, err := New(
+				WithCollection("test-db", "test-coll", WithStreamConfig(streamConfig)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidStreamConfig)
+		}
+	})
+	t.Run("should create connector with dead letter defaults", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithDeadLetter(DeadLetterConfig{Store: "nats", MaxRetries: 3})),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, &DeadLetterConfig{
+			Store:           "nats",
+			StreamName:      "COLL1_DLQ",
+			DbName:          "dead-letters",
+			CollName:        "coll1",
+			MaxRetries:      3,
+			RetryBackoff:    1 * time.Second,
+			MaxRetryBackoff: 1 * time.Minute,
+		}, conn.options.collections[0].deadLetter)
+	})
+	t.Run("should return error cause dead letter config contains an invalid value", func(t *testing.T) {
+		for _, deadLetter := range []DeadLetterConfig{
+			{Store: "kafka"},
+			{Store: "nats", MaxRetries: -1},
+			{Store: "nats", RetryBackoff: time.Minute, MaxRetryBackoff: time.Second},
+			{Store: "nats", StreamName: "COLL1"},
+			{Store: "mongo", DbName: "connector-db", CollName: "coll1"},
+		} {
+			conn, err := New(
+				WithCollection("connector-db", "coll1", WithDeadLetter(deadLetter)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidDeadLetter)
+		}
+	})
+	t.Run("should create connector with given failure policy", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithFailurePolicy(StopFailurePolicy)),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, "stop", conn.options.collections[0].failurePolicy)
+	})
+	t.Run("should return error cause failure policy is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithFailurePolicy("ignore")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidFailurePolicy.Error())
+	})
+	t.Run("should create connector with given snapshot mode", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithSnapshot(InitialSnapshot)),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, "initial", conn.options.collections[0].snapshot)
+	})
+	t.Run("should return error cause snapshot mode is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithSnapshot("sometimes")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidSnapshot.Error())
+	})
+	t.Run("should return error cause snapshot is set for a database", func(t *testing.T) {
+		conn, err := New(
+			WithDatabase("connector-db", WithSnapshot(AlwaysSnapshot)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidSnapshot.Error())
+	})
+	t.Run("should create connector with given invalidate policy", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithOnInvalidate(FollowRenameOnInvalidate)),
+			WithDatabase("connector-db", WithOnInvalidate(RestartOnInvalidate)),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, "follow-rename", conn.options.collections[0].onInvalidate)
+		require.Equal(t, "restart", conn.options.collections[1].onInvalidate)
+	})
+	t.Run("should return error cause invalidate policy is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithOnInvalidate("ignore")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidOnInvalidate.Error())
+	})
+	t.Run("should return error cause renames are followed for a database", func(t *testing.T) {
+		conn, err := New(
+			WithDatabase("connector-db", WithOnInvalidate(FollowRenameOnInvalidate)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidOnInvalidate.Error())
+	})
+	t.Run("should return error cause renames cannot be stored in the stream", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithOnInvalidate(FollowRenameOnInvalidate),
+				WithTokensStore(StreamTokensStore)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidOnInvalidate.Error())
+	})
+	t.Run("should create connector with given history lost policy", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithOnHistoryLost(ResnapshotOnHistoryLost)),
+			WithDatabase("connector-db", WithOnHistoryLost(RestartFromNowOnHistoryLost)),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, "resnapshot", conn.options.collections[0].onHistoryLost)
+		require.Equal(t, "restart-from-now", conn.options.collections[1].onHistoryLost)
+	})
+	t.Run("should return error cause history lost policy is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithOnHistoryLost("ignore")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidOnHistoryLost.Error())
+	})
+	t.Run("should return error cause a database is resnapshotted", func(t *testing.T) {
+		conn, err := New(
+			WithDatabase("connector-db", WithOnHistoryLost(ResnapshotOnHistoryLost)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidOnHistoryLost.Error())
+	})
+	t.Run("should create connector with given full document settings", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithFullDocument(DefaultFullDocument),
+				WithFullDocumentBeforeChange(OffFullDocumentBeforeChange)),
+			WithDatabase("connector-db", WithFullDocument(RequiredFullDocument)),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, "default", conn.options.collections[0].fullDocument)
+		require.Equal(t, "off", conn.options.collections[0].fullDocumentBeforeChange)
+		require.Zero(t, conn.options.collections[0].minMongoMajorVersion())
+		require.Equal(t, "required", conn.options.collections[1].fullDocument)
+		require.Equal(t, 6, conn.options.collections[1].minMongoMajorVersion())
+	})
+	t.Run("should return error cause full document mode is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithFullDocument("always")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidFullDocument.Error())
+	})
+	t.Run("should return error cause full document before change mode is not supported", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithFullDocumentBeforeChange("updateLookup")),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidFullDocumentBeforeChange.Error())
+	})
+	t.Run("should return error cause change stream config contains an invalid value", func(t *testing.T) {
+		tests := []ChangeStreamConfig{
+			{BatchSize: -1},
+			{MaxAwaitTime: -time.Second},
+			{Collation: `{"locale": "en", "strength": "2"}`},
+			{Collation: `{"local": "en"}`},
+			{Collation: `{"strength": 2}`},
+		}
+		for _, changeStreamConfig := range tests {
+			conn, err := New(
+				WithCollection("connector-db", "coll1", WithChangeStreamConfig(changeStreamConfig)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidChangeStreamConfig)
+		}
+	})
+	t.Run("should return error cause publish config contains an invalid value", func(t *testing.T) {
+		for _, publishConfig := range []PublishConfig{
+			{Mode: "batch"},
+			{Mode: "async", MaxPending: -1},
+		} {
+			conn, err := New(
+				WithCollection("connector-db", "coll1", WithPublishConfig(publishConfig)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidPublishConfig)
+		}
+	})
+	t.Run("should return error cause checkpoint config contains a negative value", func(t *testing.T) {
+		for _, checkpoint := range []CheckpointConfig{
+			{Every: -1},
+			{Interval: -time.Second},
+		} {
+			conn, err := New(
+				WithCollection("connector-db", "coll1", WithCheckpoint(checkpoint)),
+			)
+
+			require.Nil(t, conn)
+			require.ErrorIs(t, err, ErrInvalidCheckpoint)
+		}
+	})
+	t.Run("should create connector with given start position", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithStartAt("2024-06-06T12:00:00Z")),
+			WithDatabase("connector-db", WithStartAt(EarliestStartAt)),
+			WithResetTokens("connector-db.coll1"),
+		)
+
+		require.NoError(t, err)
+		require.NotNil(t, conn.options.collections[0].startAt)
+		require.True(t, conn.options.collections[0].resetTokens)
+		require.NotNil(t, conn.options.collections[1].startAt)
+		require.False(t, conn.options.collections[1].resetTokens)
+	})
+	t.Run("should return error cause start position is not valid", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithStartAt("yesterday")),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidStartAt)
+	})
+	t.Run("should return error cause start position is set along with a snapshot", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithStartAt(LatestStartAt), WithSnapshot(InitialSnapshot)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidStartAt.Error())
+	})
+	t.Run("should return error cause tokens are reset for a namespace that is not watched", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1"),
+			WithResetTokens("connector-db.coll2"),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidResetTokens)
+	})
+	t.Run("should create connector with collection pattern", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollectionPattern(CollectionPatternConfig{
+				DbName:                 "connector-db",
+				CollNamePattern:        "/^customer_[0-9]+$/",
+				StreamNameTemplate:     "{{upper .CollName}}",
+				TokensCollNameTemplate: "{{.CollName}}-tokens",
+			}),
+		)
+
+		require.NoError(t, err)
+		require.Len(t, conn.options.collectionPatterns, 1)
+		pattern := conn.options.collectionPatterns[0]
+		require.Equal(t, 30*time.Second, pattern.discoveryInterval)
+		require.True(t, pattern.matches("customer_42"))
+		require.False(t, pattern.matches("customer_archive"))
+		coll, err := pattern.newCollection("customer_42")
+		require.NoError(t, err)
+		require.Equal(t, "CUSTOMER_42", coll.streamName)
+		require.Equal(t, "customer_42-tokens", coll.tokensCollName)
+		require.True(t, coll.discovered)
+	})
+	t.Run("should return error cause collection pattern is invalid", func(t *testing.T) {
+		for _, pattern := range []CollectionPatternConfig{
+			{DbName: "connector-db"},
+			{DbName: "connector-db", CollNamePattern: "customer_["},
+			{DbName: "connector-db", CollNamePattern: "/customer_(/"},
+			{DbName: "connector-db", CollNamePattern: "customer_*", StreamNameTemplate: "{{.Stream}}"},
+			{DbName: "connector-db", CollNamePattern: "customer_*", DiscoveryInterval: -time.Second},
+		} {
+			_, err := New(
+				withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+				withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+				WithCollectionPattern(pattern),
+			)
+
+			require.ErrorIs(t, err, ErrInvalidCollectionPattern)
+		}
+	})
+	t.Run("should create connector with given operation types", func(t *testing.T) {
+		conn, err := New(
+			withMongoClient(&mockMongoClient{}), // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}),   // avoid connecting to a real nats instance
+			WithCollection("connector-db", "coll1", WithOperationTypes("insert", "drop", "invalidate", "drop")),
+			WithDatabase("connector-db", WithOperationTypes("insert", "createIndexes")),
+		)
+
+		require.NoError(t, err)
+		require.Equal(t, []string{"drop", "insert", "invalidate"}, conn.options.collections[0].operationTypes)
+		require.False(t, conn.options.collections[0].showExpandedEvents())
+		require.True(t, conn.options.collections[1].showExpandedEvents())
+	})
+	t.Run("should return error cause operation type is unknown", func(t *testing.T) {
+		conn, err := New(
+			WithCollection("connector-db", "coll1", WithOperationTypes("insert", "truncate")),
+		)
+
+		require.Nil(t, conn)
+		require.ErrorIs(t, err, ErrInvalidOperationTypes)
+	})
+	t.Run("should return error cause tokens cannot be stored in the collection to be watched", func(t *testing.T) {
+		var (
+			dbName   = "test-db"
+			collName = "test-coll"
+		)
+
+		conn, err := New(
+			WithCollection(dbName, collName, WithTokensDbName(dbName), WithTokensCollName(collName)),
+		)
+
+		require.Nil(t, conn)
+		require.EqualError(t, err, ErrInvalidDbAndCollNames.Error())
+	})
+}
+
+func TestConnector_Run(t *testing.T) {
+	t.Run("should run connector and ", func(t *testing.T) {
+		var (
+			mongoClient     = &mockMongoClient{}
+			natsClient      = &mockNatsClient{}
+			ctx, cancel     = context.WithCancel(context.Background())
+			dbName          = "connector-db"
+			collName        = "coll1"
+			tokensDbName    = "tokens-db"
+			tokensCollName  = "coll1-tokens"
+			collSizeInBytes = int64(2048)
+			streamName      = "coll1-stream"
+			streamConfig    = StreamConfig{Retention: "interest", MaxBytes: 1024}
+			pipeline        = `[{"$match": {"operationType": "insert"}}]`
+			subj            = "subj"
+			msgId           = "msgId"
+			data            = []byte("event")
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, collName,
+				WithChangeStreamPreAndPostImages(),
+				WithTokensDbName(tokensDbName),
+				WithTokensCollName(tokensCollName),
+				WithTokensCollCapped(collSizeInBytes),
+				WithStreamName(streamName),
+				WithStreamConfig(streamConfig),
+				WithHeaders(map[string]string{"Source": "connector"}),
+				WithPipeline(pipeline),
+				WithOperationTypes("insert", "update", "replace", "delete", "drop", "invalidate"),
+			),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		t.Run("create watchable collections", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+					DbName:                       dbName,
+					CollName:                     collName,
+					Capped:                       false,
+					SizeInBytes:                  0,
+					ChangeStreamPreAndPostImages: true,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("create resume tokens collections", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+					DbName:                       tokensDbName,
+					CollName:                     tokensCollName,
+					Capped:                       true,
+					SizeInBytes:                  collSizeInBytes,
+					ChangeStreamPreAndPostImages: false,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("add nats streams", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return natsClient.StreamWasAdded(nats.AddStreamOptions{
+					StreamName: streamName,
+					Subjects:   []string{streamName + ".*"},
+					Retention:  "interest",
+					MaxBytes:   1024,
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("watch collections", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+					WatchedDbName:            dbName,
+					WatchedCollName:          collName,
+					ResumeTokensDbName:       tokensDbName,
+					ResumeTokensCollName:     tokensCollName,
+					ResumeTokensCollCapped:   true,
+					StreamName:               streamName,
+					Pipeline:                 []bson.D{{{Key: "$match", Value: bson.D{{Key: "operationType", Value: "insert"}}}}},
+					OperationTypes:           []string{"delete", "drop", "insert", "invalidate", "replace", "update"},
+					FullDocument:             "updateLookup",
+					FullDocumentBeforeChange: "whenAvailable",
+				})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("publish change event messages", func(t *testing.T) {
+			mongoClient.SimulateChangeEvents(&mongo.ChangeEvent{
+				Subj:          subj,
+				Id:            msgId,
+				OperationType: "insert",
+				DbName:        dbName,
+				CollName:      collName,
+				DocumentKey:   `{"_id":1}`,
+				ClusterTime:   "1683637178.1",
+				WallTime:      time.Date(2023, 5, 9, 12, 59, 38, 17000000, time.UTC),
+				Data:          data,
+			})
+
+			require.Eventually(t, func() bool {
+				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: msgId, Data: data,
+					Headers: map[string]string{
+						"Source":               "connector",
+						"Mongo-Operation-Type": "insert",
+						"Mongo-Db":             dbName,
+						"Mongo-Coll":           collName,
+						"Mongo-Document-Key":   `{"_id":1}`,
+						"Mongo-Cluster-Time":   "1683637178.1",
+						"Mongo-Wall-Time":      "2023-05-09T12:59:38.017Z",
+					}})
+			}, 1*time.Second, 100*time.Millisecond)
+		})
+
+		t.Run("shut down and close clients when context is cancelled", func(t *testing.T) {
+			cancel() // stop the connector by canceling context
+			err := <-errCh
+			require.NotNil(t, err)
+			require.True(t, mongoClient.closed)
+			require.True(t, natsClient.closed)
+		})
+	})
+	t.Run("should run connector storing resume tokens on nats", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+			collName    = "coll1"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, collName, WithTokensStore(NatsTokensStore)),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.TokenStoreWasCreated(nats.CreateTokenStoreOptions{
+				BucketName: "resume-tokens",
+				Key:        collName,
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithTokenStore(dbName, collName)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.False(t, mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+			DbName:   "resume-tokens",
+			CollName: collName,
+		}))
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector storing resume tokens in the stream", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+			collName    = "coll1"
+			subj        = "COLL1.insert"
+			msgId       = "token"
+			data        = []byte("event")
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, collName, WithTokensStore(StreamTokensStore)),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.StreamTokenStoreWasCreated(nats.CreateStreamTokenStoreOptions{
+				StreamName: "COLL1",
+				Subject:    "COLL1.*",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithTokenStore(dbName, collName)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		mongoClient.SimulateChangeEvents(&mongo.ChangeEvent{Subj: subj, Id: msgId, OperationType: "insert", Data: data})
+
+		require.Eventually(t, func() bool {
+			return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: msgId, Data: data,
+				ResumeToken: msgId, Headers: map[string]string{"Mongo-Operation-Type": "insert"}})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector publishing change events asynchronously", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+			subj        = "COLL1.insert"
+			msgId       = "82645A43BA000000012B022C0100296E5A1004"
+			data        = []byte(`{"message":"hello"}`)
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, "coll1", WithPublishConfig(PublishConfig{Mode: "async"})),
+			WithCollection(dbName, "coll2", WithPublishConfig(PublishConfig{Mode: "ASYNC", MaxPending: 1000})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedAsync(dbName, "coll1", 256) &&
+				mongoClient.CollectionWasWatchedAsync(dbName, "coll2", 1000)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		mongoClient.SimulateAsyncChangeEvents(&mongo.ChangeEvent{Subj: subj, Id: msgId, OperationType: "insert",
+			Data: data})
+
+		require.Eventually(t, func() bool {
+			return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: msgId, Data: data,
+				Headers: map[string]string{"Mongo-Operation-Type": "insert"}})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector storing resume tokens according to the checkpoint config", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, "coll1"),
+			WithCollection(dbName, "coll2", WithCheckpoint(CheckpointConfig{Every: 100, Interval: 5 * time.Second})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithCheckpoint(dbName, "coll1", 0, 0) &&
+				mongoClient.CollectionWasWatchedWithCheckpoint(dbName, "coll2", 100, 5*time.Second)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector watching databases and clusters", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithDatabase(dbName),
+			WithCluster(),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "CONNECTOR-DB",
+				Subjects:   []string{"CONNECTOR-DB.*.*"},
+			}) && natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "CLUSTER",
+				Subjects:   []string{"CLUSTER.*.*.*"},
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:            dbName,
+				ResumeTokensDbName:       "resume-tokens",
+				ResumeTokensCollName:     dbName,
+				StreamName:               "CONNECTOR-DB",
+				FullDocument:             "updateLookup",
+				FullDocumentBeforeChange: "whenAvailable",
+			}) && mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				ResumeTokensDbName:       "resume-tokens",
+				ResumeTokensCollName:     "cluster",
+				StreamName:               "CLUSTER",
+				FullDocument:             "updateLookup",
+				FullDocumentBeforeChange: "whenAvailable",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.False(t, mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{DbName: dbName}))
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector watching the collections matching a pattern", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+		)
+		defer cancel()
+		mongoClient.SetCollectionNames(dbName, "customer_1-tokens", "customer_1", "customer_2", "orders")
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, "customer_2"),
+			WithCollectionPattern(CollectionPatternConfig{
+				DbName:                 dbName,
+				CollNamePattern:        "customer_*",
+				StreamNameTemplate:     "CUSTOMERS_{{upper .CollName}}",
+				TokensCollNameTemplate: "{{.CollName}}-tokens",
+				DiscoveryInterval:      10 * time.Millisecond,
+			}, WithTokensDbName(dbName)),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "CUSTOMERS_CUSTOMER_1",
+				Subjects:   []string{"CUSTOMERS_CUSTOMER_1.*"},
+			}) && mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:            dbName,
+				WatchedCollName:          "customer_1",
+				ResumeTokensDbName:       dbName,
+				ResumeTokensCollName:     "customer_1-tokens",
+				StreamName:               "CUSTOMERS_CUSTOMER_1",
+				FullDocument:             "updateLookup",
+				FullDocumentBeforeChange: "whenAvailable",
+			})
+		}, 1*time.Second, 10*time.Millisecond)
+
+		// already watched, or storing the resume tokens of a discovered collection
+		require.False(t, natsClient.StreamWasAdded(nats.AddStreamOptions{
+			StreamName: "CUSTOMERS_CUSTOMER_2",
+			Subjects:   []string{"CUSTOMERS_CUSTOMER_2.*"},
+		}))
+		require.False(t, natsClient.StreamWasAdded(nats.AddStreamOptions{
+			StreamName: "CUSTOMERS_CUSTOMER_1-TOKENS",
+			Subjects:   []string{"CUSTOMERS_CUSTOMER_1-TOKENS.*"},
+		}))
+		require.False(t, mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+			DbName:   dbName,
+			CollName: "customer_1",
+		}))
+
+		mongoClient.SetCollectionNames(dbName, "customer_2", "customer_3", "orders")
+
+		require.Eventually(t, func() bool {
+			details, _ := conn.supervisor.Details().(map[string]watcherState)
+			_, dropped := details["connector-db.customer_1"]
+			return !dropped && details["connector-db.customer_3"] == watcherState{Status: "UP"}
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Nil(t, conn.snapshotter.watcher(dbName, "customer_1"))
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector publishing the dead letters of each discovered collection", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+		)
+		defer cancel()
+		mongoClient.SetCollectionNames(dbName, "customer_1", "customer_2")
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollectionPattern(CollectionPatternConfig{
+				DbName:             dbName,
+				CollNamePattern:    "customer_*",
+				StreamNameTemplate: "{{upper .CollName}}",
+				DiscoveryInterval:  10 * time.Millisecond,
+			}, WithDeadLetter(DeadLetterConfig{Store: "nats"})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "CUSTOMER_1_DLQ",
+				Subjects:   []string{"CUSTOMER_1_DLQ.>"},
+			}) && natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "CUSTOMER_2_DLQ",
+				Subjects:   []string{"CUSTOMER_2_DLQ.>"},
+			})
+		}, 1*time.Second, 10*time.Millisecond)
+
+		// the defaults of the pattern itself are not shared with the collections it discovers
+		coll, err := conn.options.collectionPatterns[0].newCollection("customer_3")
+		require.NoError(t, err)
+		require.Equal(t, "CUSTOMER_3_DLQ", coll.deadLetter.StreamName)
+		require.Equal(t, "customer_3", coll.deadLetter.CollName)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector watching again a discovered collection whose watcher ended", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+		)
+		defer cancel()
+		mongoClient.SetCollectionNames(dbName, "customer_1")
+		// the collection is dropped and recreated between two discoveries, invalidating its change stream
+		mongoClient.SetInvalidations("customer_1", 1)
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollectionPattern(CollectionPatternConfig{
+				DbName:            dbName,
+				CollNamePattern:   "customer_*",
+				DiscoveryInterval: 500 * time.Millisecond,
+			}),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		// the ended watcher is removed until the next discovery
+		require.Eventually(t, func() bool {
+			details, _ := conn.supervisor.Details().(map[string]watcherState)
+			_, ok := details["connector-db.customer_1"]
+			return mongoClient.CollectionWatches(dbName, "customer_1") == 1 && !ok &&
+				conn.snapshotter.watcher(dbName, "customer_1") == nil
+		}, 400*time.Millisecond, 10*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			details, _ := conn.supervisor.Details().(map[string]watcherState)
+			return mongoClient.CollectionWatches(dbName, "customer_1") == 2 &&
+				details["connector-db.customer_1"] == watcherState{Status: "UP"}
+		}, 1*time.Second, 10*time.Millisecond)
+		require.NoError(t, conn.supervisor.Monitor(ctx))
+		require.NotNil(t, conn.snapshotter.watcher(dbName, "customer_1"))
+		require.Equal(t, 1, conn.discoverer.watched(conn.options.collectionPatterns[0]))
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector with given change stream tuning", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithDatabase(dbName, WithChangeStreamConfig(ChangeStreamConfig{
+				BatchSize:          500,
+				MaxAwaitTime:       200 * time.Millisecond,
+				Collation:          `{"locale": "en", "strength": 2}`,
+				ShowExpandedEvents: true,
+				Comment:            "mongodb-nats-connector",
+			})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:            dbName,
+				ResumeTokensDbName:       "resume-tokens",
+				ResumeTokensCollName:     dbName,
+				StreamName:               "CONNECTOR-DB",
+				ShowExpandedEvents:       true,
+				FullDocument:             "updateLookup",
+				FullDocumentBeforeChange: "whenAvailable",
+				BatchSize:                500,
+				MaxAwaitTime:             200 * time.Millisecond,
+				Collation:                &options.Collation{Locale: "en", Strength: 2},
+				Comment:                  "mongodb-nats-connector",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector publishing dead letters on nats", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+			collName    = "coll1"
+			data        = []byte("event")
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection(dbName, collName, WithDeadLetter(DeadLetterConfig{Store: "nats", MaxRetries: 2})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return natsClient.StreamWasAdded(nats.AddStreamOptions{
+				StreamName: "COLL1_DLQ",
+				Subjects:   []string{"COLL1_DLQ.>"},
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithDeadLetters(dbName, collName, 2)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		mongoClient.SimulateDeadLetters(&mongo.ChangeEvent{Subj: "COLL1.insert", Id: "token", OperationType: "insert",
+			Data: data}, errors.New("publish\nerror"))
+
+		require.Eventually(t, func() bool {
+			return natsClient.MessageWasPublished(nats.PublishOptions{Subj: "COLL1_DLQ.insert", MsgId: "token",
+				Data: data, Headers: map[string]string{
+					"Mongo-Operation-Type":       "insert",
+					"Mongo-Dead-Letter-Subject":  "COLL1.insert",
+					"Mongo-Dead-Letter-Error":    "publish error",
+					"Mongo-Dead-Letter-Attempts": "3",
+				}})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector storing dead letters on mongo", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
+			cause       = errors.New("publish error")
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithDatabase(dbName, WithDeadLetter(DeadLetterConfig{Store: "mongo"})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+				DbName:   "dead-letters",
+				CollName: dbName,
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:        dbName,
+				ResumeTokensDbName:   "resume-tokens",
+				ResumeTokensCollName: dbName,
+				StreamName:           "CONNECTOR-DB",
+				Pipeline: []bson.D{{{Key: "$match", Value: bson.D{{Key: "$nor", Value: bson.A{bson.D{
+					{Key: "ns.db", Value: "dead-letters"},
+					{Key: "ns.coll", Value: dbName},
+				}}}}}}},
+				FullDocument:             "updateLookup",
+				FullDocumentBeforeChange: "whenAvailable",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		event := &mongo.ChangeEvent{Subj: "CONNECTOR-DB.coll1.insert", Id: "token", OperationType: "insert"}
+		mongoClient.SimulateDeadLetters(event, cause)
+
+		require.Eventually(t, func() bool {
+			return mongoClient.DeadLetterWasInserted(mongo.InsertDeadLetterOptions{
+				DbName:   "dead-letters",
+				CollName: dbName,
+				Event:    event,
+				Err:      cause,
+				Attempts: 1,
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector with an initial snapshot", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithSnapshot(InitialSnapshot)),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithSnapshot("connector-db", "coll1", "initial")
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should not run connector cause mongodb does not support the full document settings", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{serverVersion: "5.0.26"}
+			natsClient  = &mockNatsClient{}
+		)
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithCollection("connector-db", "coll1"),
+			WithCollection("connector-db", "coll2", WithFullDocumentBeforeChange(RequiredFullDocumentBeforeChange)),
+		)
+
+		err := conn.Run()
+
+		require.ErrorIs(t, err, ErrUnsupportedMongoVersion)
+		require.ErrorContains(t, err, "connector-db.coll2")
+	})
+	t.Run("should not run connector cause mongodb is a standalone server", func(t *testing.T) {
+		mongoClient := &mockMongoClient{topology: &mongo.Topology{Kind: mongo.TopologyStandalone}}
+
+		conn, _ := New(
+			withMongoClient(mongoClient),      // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}), // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		require.ErrorIs(t, conn.Run(), ErrUnsupportedMongoTopology)
+		require.False(t, mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
+			DbName:   "connector-db",
+			CollName: "coll1",
+		}))
+	})
+	t.Run("should not run connector cause the oplog cannot be read through mongos", func(t *testing.T) {
+		mongoClient := &mockMongoClient{topology: &mongo.Topology{Kind: mongo.TopologySharded, Shards: []string{"shard1"}}}
+
+		conn, _ := New(
+			withMongoClient(mongoClient),      // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}), // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithCollection("connector-db", "coll1", WithStartAt(EarliestStartAt)),
+		)
+
+		require.ErrorIs(t, conn.Run(), ErrUnsupportedMongoTopology)
+	})
+	t.Run("should run connector showing the expanded events on a sharded cluster only if enabled", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{
+				topology: &mongo.Topology{Kind: mongo.TopologySharded, Shards: []string{"shard1", "shard2"}},
+			}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient),      // avoid connecting to a real mongo instance
+			withNatsClient(&mockNatsClient{}), // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+			WithCollection("connector-db", "coll2", WithChangeStreamConfig(ChangeStreamConfig{ShowExpandedEvents: true})),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithExpandedEvents("connector-db", "coll1", false) &&
+				mongoClient.CollectionWasWatchedWithExpandedEvents("connector-db", "coll2", true)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run connector resetting the stored tokens only once", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("watch error")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithBackoff(BackoffConfig{Initial: time.Millisecond, Max: time.Millisecond}),
+			WithCollection("connector-db", "coll1", WithStartAt(EarliestStartAt)),
+			WithResetTokens("connector-db.coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithStartAt("connector-db", "coll1", true) &&
+				mongoClient.CollectionWasWatchedWithStartAt("connector-db", "coll1", false)
+		}, 1*time.Second, 100*time.Millisecond)
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should run an on-demand snapshot of a watched collection", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
+				WatchedDbName:            "connector-db",
+				WatchedCollName:          "coll1",
+				ResumeTokensDbName:       "resume-tokens",
+				ResumeTokensCollName:     "coll1",
+				StreamName:               "COLL1",
+				FullDocument:             "updateLookup",
+				FullDocumentBeforeChange: "whenAvailable",
+			})
+		}, 1*time.Second, 100*time.Millisecond)
+
+		req := &server.SnapshotRequest{Filter: []byte(`{"tenantId":"acme"}`)}
+		started, err := conn.snapshotter.StartSnapshot("connector-db", "coll1", req)
+		require.NoError(t, err)
+		require.Equal(t, "RUNNING", started.Status)
+
+		require.Eventually(t, func() bool {
+			status, err := conn.snapshotter.SnapshotStatus("connector-db", "coll1")
+			return err == nil && status.Status == "COMPLETED" && status.Published == 1
+		}, 1*time.Second, 100*time.Millisecond)
+		require.True(t, mongoClient.CollectionWasSnapshotted(mongo.SnapshotCollectionOptions{
+			Id:        started.Id,
+			DbName:    "connector-db",
+			CollName:  "coll1",
+			Filter:    bson.D{{Key: "tenantId", Value: "acme"}},
+			RateLimit: 1000,
+		}))
+		require.True(t, natsClient.MessageWasPublished(nats.PublishOptions{
+			Subj:  "COLL1.snapshot",
+			MsgId: started.Id + ":1",
+			Data:  []byte("{}"),
+			Headers: map[string]string{
+				nats.OperationTypeHdr: "snapshot",
+				nats.DbHdr:            "connector-db",
+				nats.CollHdr:          "coll1",
+			},
+		}))
+
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should reject on-demand snapshots that cannot be run", func(t *testing.T) {
+		var (
+			mongoClient = &mockMongoClient{snapshotRelease: make(chan struct{})}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
+
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1"),
+			WithCollection("connector-db", "coll2", WithTokensStore(StreamTokensStore)),
+			WithDatabase("tenants-db"),
+		)
+
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
+
+		require.Eventually(t, func() bool {
+			return mongoClient.CollectionWasWatchedWithSnapshot("tenants-db", "", "never")
+		}, 1*time.Second, 100*time.Millisecond)
+
+		_, err := conn.snapshotter.StartSnapshot("connector-db", "coll3", &server.SnapshotRequest{})
+		require.ErrorIs(t, err, server.ErrNotWatched)
+		_, err = conn.snapshotter.StartSnapshot("connector-db", "coll2", &server.SnapshotRequest{})
+		require.ErrorIs(t, err, server.ErrInvalidSnapshotRequest)
+		_, err = conn.snapshotter.StartSnapshot("connector-db", "coll1", &server.SnapshotRequest{Filter: []byte(`[]`)})
+		require.ErrorIs(t, err, server.ErrInvalidSnapshotRequest)
+		_, err = conn.snapshotter.SnapshotStatus("connector-db", "coll1")
+		require.ErrorIs(t, err, server.ErrSnapshotNotFound)
+
+		_, err = conn.snapshotter.StartSnapshot("tenants-db", "acme", &server.SnapshotRequest{})
+		require.NoError(t, err)
+		_, err = conn.snapshotter.StartSnapshot("tenants-db", "acme", &server.SnapshotRequest{})
+		require.ErrorIs(t, err, server.ErrSnapshotRunning)
+		close(mongoClient.snapshotRelease)
+		require.Eventually(t, func() bool {
+			status, err := conn.snapshotter.SnapshotStatus("tenants-db", "acme")
+			return err == nil && status.Status == "COMPLETED"
+		}, 1*time.Second, 100*time.Millisecond)
+		_, err = conn.snapshotter.StartSnapshot("tenants-db", "acme", &server.SnapshotRequest{})
+		require.NoError(t, err)
 
-		require.Nil(t, conn)
-		require.EqualError(t, err, ErrInvalidCollSizeInBytes.Error())
+		cancel()
+		require.NotNil(t, <-errCh)
 	})
-	t.Run("should return error cause tokens cannot be stored in the collection to be watched", func(t *testing.T) {
+	t.Run("should keep running other collections if a watcher fails", func(t *testing.T) {
 		var (
-			dbName   = "test-db"
-			collName = "test-coll"
+			watchErr    = errors.New("watch error")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
 		)
+		defer cancel()
 
-		conn, err := New(
-			WithCollection(dbName, collName, WithTokensDbName(dbName), WithTokensCollName(collName)),
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithBackoff(BackoffConfig{Initial: time.Millisecond, Max: time.Millisecond}),
+			WithCollection(dbName, "coll1", WithFailurePolicy(StopFailurePolicy)),
+			WithCollection(dbName, "coll2"),
 		)
 
-		require.Nil(t, conn)
-		require.EqualError(t, err, ErrInvalidDbAndCollNames.Error())
-	})
-}
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
 
-func TestConnector_Run(t *testing.T) {
-	t.Run("should run connector and ", func(t *testing.T) {
+		require.Eventually(t, func() bool {
+			details, _ := conn.supervisor.Details().(map[string]watcherState)
+			return details["connector-db.coll1"] == watcherState{Status: "STOPPED", LastError: "watch error"} &&
+				details["connector-db.coll2"] == watcherState{Status: "UP"}
+		}, 1*time.Second, 100*time.Millisecond)
+		require.Error(t, conn.supervisor.Monitor(ctx))
+
+		cancel()
+		require.NotErrorIs(t, <-errCh, watchErr)
+	})
+	t.Run("should keep the watchers up if a watcher ends as its change stream was invalidated", func(t *testing.T) {
 		var (
-			mongoClient     = &mockMongoClient{}
-			natsClient      = &mockNatsClient{}
-			ctx, cancel     = context.WithCancel(context.Background())
-			dbName          = "connector-db"
-			collName        = "coll1"
-			tokensDbName    = "tokens-db"
-			tokensCollName  = "coll1-tokens"
-			collSizeInBytes = int64(2048)
-			streamName      = "coll1-stream"
-			subj            = "subj"
-			msgId           = "msgId"
-			data            = []byte("event")
+			mongoClient = &mockMongoClient{}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+			dbName      = "connector-db"
 		)
 		defer cancel()
+		mongoClient.SetInvalidations("coll1", 1)
 
 		conn, _ := New(
 			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
 			withNatsClient(natsClient),   // avoid connecting to a real nats instance
 			WithServerAddr(":0"),
 			WithContext(ctx),
-			WithCollection(dbName, collName,
-				WithChangeStreamPreAndPostImages(),
-				WithTokensDbName(tokensDbName),
-				WithTokensCollName(tokensCollName),
-				WithTokensCollCapped(collSizeInBytes),
-				WithStreamName(streamName),
-			),
+			WithCollection(dbName, "coll1"),
+			WithCollection(dbName, "coll2"),
 		)
 
 		errCh := make(chan error)
@@ -245,66 +1926,66 @@ func TestConnector_Run(t *testing.T) {
 			errCh <- conn.Run()
 		}()
 
-		t.Run("create watchable collections", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
-					DbName:                       dbName,
-					CollName:                     collName,
-					Capped:                       false,
-					SizeInBytes:                  0,
-					ChangeStreamPreAndPostImages: true,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		require.Eventually(t, func() bool {
+			details, _ := conn.supervisor.Details().(map[string]watcherState)
+			return details["connector-db.coll1"] == watcherState{Status: "INVALIDATED"} &&
+				details["connector-db.coll2"] == watcherState{Status: "UP"}
+		}, 1*time.Second, 100*time.Millisecond)
+		require.NoError(t, conn.supervisor.Monitor(ctx))
 
-		t.Run("create resume tokens collections", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return mongoClient.CollectionWasCreated(mongo.CreateCollectionOptions{
-					DbName:                       tokensDbName,
-					CollName:                     tokensCollName,
-					Capped:                       true,
-					SizeInBytes:                  collSizeInBytes,
-					ChangeStreamPreAndPostImages: false,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		cancel()
+		require.NotNil(t, <-errCh)
+	})
+	t.Run("should restart a failed watcher", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("watch error")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
 
-		t.Run("add nats streams", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return natsClient.StreamWasAdded(nats.AddStreamOptions{
-					StreamName: streamName,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithBackoff(BackoffConfig{Initial: time.Millisecond, Max: time.Millisecond}),
+			WithCollection("connector-db", "coll1"),
+		)
 
-		t.Run("watch collections", func(t *testing.T) {
-			require.Eventually(t, func() bool {
-				return mongoClient.CollectionWasWatched(mongo.WatchCollectionOptions{
-					WatchedDbName:          dbName,
-					WatchedCollName:        collName,
-					ResumeTokensDbName:     tokensDbName,
-					ResumeTokensCollName:   tokensCollName,
-					ResumeTokensCollCapped: true,
-					StreamName:             streamName,
-				})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		errCh := make(chan error)
+		go func() {
+			errCh <- conn.Run()
+		}()
 
-		t.Run("publish change event messages", func(t *testing.T) {
-			mongoClient.SimulateChangeEvents(subj, msgId, data)
+		require.Eventually(t, func() bool {
+			details, _ := conn.supervisor.Details().(map[string]watcherState)
+			return details["connector-db.coll1"].Restarts >= 3
+		}, 1*time.Second, 100*time.Millisecond)
 
-			require.Eventually(t, func() bool {
-				return natsClient.MessageWasPublished(nats.PublishOptions{Subj: subj, MsgId: msgId, Data: data})
-			}, 1*time.Second, 100*time.Millisecond)
-		})
+		cancel()
+		require.NotErrorIs(t, <-errCh, watchErr)
+	})
+	t.Run("should stop connector and return error if a watcher fails with the exit policy", func(t *testing.T) {
+		var (
+			watchErr    = errors.New("watch error")
+			mongoClient = &mockMongoClient{watchCollectionErrs: map[string]error{"coll1": watchErr}}
+			natsClient  = &mockNatsClient{}
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer cancel()
 
-		t.Run("shut down and close clients when context is cancelled", func(t *testing.T) {
-			cancel() // stop the connector by canceling context
-			err := <-errCh
-			require.NotNil(t, err)
-			require.True(t, mongoClient.closed)
-			require.True(t, natsClient.closed)
-		})
+		conn, _ := New(
+			withMongoClient(mongoClient), // avoid connecting to a real mongo instance
+			withNatsClient(natsClient),   // avoid connecting to a real nats instance
+			WithServerAddr(":0"),
+			WithContext(ctx),
+			WithCollection("connector-db", "coll1", WithFailurePolicy(ExitFailurePolicy)),
+		)
+
+		err := conn.Run()
+		require.ErrorIs(t, err, watchErr)
 	})
 	t.Run("should stop connector and return error if collection creation fails", func(t *testing.T) {
 		var (
@@ -375,9 +2056,11 @@ func TestConnector_Run(t *testing.T) {
 }
 
 type mockMongoClient struct {
-	closed     bool
-	name       string
-	monitorErr error
+	closed        bool
+	name          string
+	monitorErr    error
+	serverVersion string          // defaults to 7.0.0
+	topology      *mongo.Topology // defaults to a replica set
 
 	muc                  sync.Mutex
 	createCollectionOpts []mongo.CreateCollectionOptions
@@ -385,7 +2068,18 @@ type mockMongoClient struct {
 
 	muw                 sync.Mutex
 	watchCollectionOpts []mongo.WatchCollectionOptions
-	watchCollectionErr  error
+	watchCollectionErrs map[string]error // by watched collection name
+	invalidations       map[string]int   // the number of watches ending as if invalidated, by watched collection name
+
+	mud                  sync.Mutex
+	insertDeadLetterOpts []mongo.InsertDeadLetterOptions
+
+	mus                    sync.Mutex
+	snapshotCollectionOpts []mongo.SnapshotCollectionOptions
+	snapshotRelease        chan struct{} // blocks snapshots until closed, if set
+
+	mul       sync.Mutex
+	collNames map[string][]string // by database name
 }
 
 func (m *mockMongoClient) Close() error {
@@ -401,6 +2095,35 @@ func (m *mockMongoClient) Monitor(_ context.Context) error {
 	return m.monitorErr
 }
 
+func (m *mockMongoClient) ServerVersion(_ context.Context) (string, error) {
+	if m.serverVersion == "" {
+		return "7.0.0", nil
+	}
+	return m.serverVersion, nil
+}
+
+func (m *mockMongoClient) Topology(_ context.Context) (*mongo.Topology, error) {
+	if m.topology == nil {
+		return &mongo.Topology{Kind: mongo.TopologyReplicaSet, SetName: "mongodb-nats-connector"}, nil
+	}
+	return m.topology, nil
+}
+
+func (m *mockMongoClient) ListCollectionNames(_ context.Context, dbName string) ([]string, error) {
+	m.mul.Lock()
+	defer m.mul.Unlock()
+	return slices.Clone(m.collNames[dbName]), nil
+}
+
+func (m *mockMongoClient) SetCollectionNames(dbName string, collNames ...string) {
+	m.mul.Lock()
+	defer m.mul.Unlock()
+	if m.collNames == nil {
+		m.collNames = make(map[string][]string)
+	}
+	m.collNames[dbName] = collNames
+}
+
 func (m *mockMongoClient) CreateCollection(_ context.Context, opts *mongo.CreateCollectionOptions) error {
 	if m.createCollectionErr != nil {
 		return m.createCollectionErr
@@ -417,14 +2140,43 @@ func (m *mockMongoClient) CollectionWasCreated(opts mongo.CreateCollectionOption
 	return slices.Contains(m.createCollectionOpts, opts)
 }
 
-func (m *mockMongoClient) WatchCollection(_ context.Context, opts *mongo.WatchCollectionOptions) error {
-	if m.watchCollectionErr != nil {
-		return m.watchCollectionErr
+func (m *mockMongoClient) WatchCollection(ctx context.Context, opts *mongo.WatchCollectionOptions) error {
+	m.muw.Lock()
+	m.watchCollectionOpts = append(m.watchCollectionOpts, *opts)
+	invalidated := m.invalidations[opts.WatchedCollName] > 0
+	if invalidated {
+		m.invalidations[opts.WatchedCollName]--
+	}
+	m.muw.Unlock()
+	if err := m.watchCollectionErrs[opts.WatchedCollName]; err != nil {
+		return err
 	}
+	if invalidated {
+		return nil
+	}
+	<-ctx.Done() // blocks like a real change stream
+	return ctx.Err()
+}
+
+func (m *mockMongoClient) SetInvalidations(collName string, invalidations int) {
 	m.muw.Lock()
 	defer m.muw.Unlock()
-	m.watchCollectionOpts = append(m.watchCollectionOpts, *opts)
-	return nil
+	if m.invalidations == nil {
+		m.invalidations = make(map[string]int)
+	}
+	m.invalidations[collName] = invalidations
+}
+
+func (m *mockMongoClient) CollectionWatches(dbName, collName string) int {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	var watches int
+	for _, o := range m.watchCollectionOpts {
+		if o.WatchedDbName == dbName && o.WatchedCollName == collName {
+			watches++
+		}
+	}
+	return watches
 }
 
 func (m *mockMongoClient) CollectionWasWatched(opts mongo.WatchCollectionOptions) bool {
@@ -437,16 +2189,158 @@ func (m *mockMongoClient) CollectionWasWatched(opts mongo.WatchCollectionOptions
 			o.ResumeTokensCollName == opts.ResumeTokensCollName &&
 			o.ResumeTokensCollCapped == opts.ResumeTokensCollCapped &&
 			o.StreamName == opts.StreamName &&
+			reflect.DeepEqual(o.Pipeline, opts.Pipeline) &&
+			slices.Equal(o.OperationTypes, opts.OperationTypes) &&
+			o.ShowExpandedEvents == opts.ShowExpandedEvents &&
+			o.FullDocument == opts.FullDocument &&
+			o.FullDocumentBeforeChange == opts.FullDocumentBeforeChange &&
+			o.BatchSize == opts.BatchSize &&
+			o.MaxAwaitTime == opts.MaxAwaitTime &&
+			reflect.DeepEqual(o.Collation, opts.Collation) &&
+			o.Comment == opts.Comment &&
 			o.ChangeEventHandler != nil
 	})
 }
 
-func (m *mockMongoClient) SimulateChangeEvents(subj, msgId string, data []byte) {
+func (m *mockMongoClient) CollectionWasWatchedWithSnapshot(dbName, collName, snapshot string) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.Snapshot == snapshot
+	})
+}
+
+func (m *mockMongoClient) CollectionWasWatchedWithStartAt(dbName, collName string, resetTokens bool) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.StartAt != nil &&
+			o.ResetTokens == resetTokens
+	})
+}
+
+func (m *mockMongoClient) CollectionWasWatchedWithExpandedEvents(dbName, collName string, showExpandedEvents bool) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.ShowExpandedEvents == showExpandedEvents
+	})
+}
+
+func (m *mockMongoClient) CollectionWasWatchedWithTokenStore(dbName, collName string) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.TokenStore != nil
+	})
+}
+
+func (m *mockMongoClient) SimulateChangeEvents(event *mongo.ChangeEvent) {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	for _, opt := range m.watchCollectionOpts {
+		_ = opt.ChangeEventHandler(context.Background(), event)
+	}
+}
+
+func (m *mockMongoClient) SimulateAsyncChangeEvents(event *mongo.ChangeEvent) {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	for _, opt := range m.watchCollectionOpts {
+		if opt.AsyncChangeEventHandler != nil {
+			_, _ = opt.AsyncChangeEventHandler(context.Background(), event)
+		}
+	}
+}
+
+func (m *mockMongoClient) CollectionWasWatchedAsync(dbName, collName string, maxPending int) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.MaxPending == maxPending &&
+			o.AsyncChangeEventHandler != nil
+	})
+}
+
+func (m *mockMongoClient) CollectionWasWatchedWithCheckpoint(dbName, collName string, every int,
+	interval time.Duration) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.CheckpointEvery == every &&
+			o.CheckpointInterval == interval
+	})
+}
+
+func (m *mockMongoClient) CollectionWasWatchedWithDeadLetters(dbName, collName string, maxRetries int) bool {
+	m.muw.Lock()
+	defer m.muw.Unlock()
+	return slices.ContainsFunc(m.watchCollectionOpts, func(o mongo.WatchCollectionOptions) bool {
+		return o.WatchedDbName == dbName && o.WatchedCollName == collName && o.MaxRetries == maxRetries &&
+			o.DeadLetterHandler != nil
+	})
+}
+
+func (m *mockMongoClient) SimulateDeadLetters(event *mongo.ChangeEvent, cause error) {
 	m.muw.Lock()
 	defer m.muw.Unlock()
 	for _, opt := range m.watchCollectionOpts {
-		_ = opt.ChangeEventHandler(context.Background(), subj, msgId, data)
+		if opt.DeadLetterHandler != nil {
+			_ = opt.DeadLetterHandler(context.Background(), event, cause)
+		}
+	}
+}
+
+func (m *mockMongoClient) InsertDeadLetter(_ context.Context, opts *mongo.InsertDeadLetterOptions) error {
+	m.mud.Lock()
+	defer m.mud.Unlock()
+	m.insertDeadLetterOpts = append(m.insertDeadLetterOpts, *opts)
+	return nil
+}
+
+func (m *mockMongoClient) DeadLetterWasInserted(opts mongo.InsertDeadLetterOptions) bool {
+	m.mud.Lock()
+	defer m.mud.Unlock()
+	return slices.Contains(m.insertDeadLetterOpts, opts)
+}
+
+func (m *mockMongoClient) SnapshotCollection(ctx context.Context, opts *mongo.SnapshotCollectionOptions) error {
+	m.mus.Lock()
+	m.snapshotCollectionOpts = append(m.snapshotCollectionOpts, *opts)
+	m.mus.Unlock()
+	if m.snapshotRelease != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.snapshotRelease:
+		}
+	}
+	event := &mongo.ChangeEvent{
+		Subj:          opts.Watch.StreamName + ".snapshot",
+		Id:            opts.Id + ":1",
+		OperationType: "snapshot",
+		DbName:        opts.DbName,
+		CollName:      opts.CollName,
+		Data:          []byte("{}"),
 	}
+	if err := opts.Watch.ChangeEventHandler(ctx, event); err != nil {
+		return err
+	}
+	opts.OnProgress(1)
+	return nil
+}
+
+func (m *mockMongoClient) CollectionWasSnapshotted(opts mongo.SnapshotCollectionOptions) bool {
+	m.mus.Lock()
+	defer m.mus.Unlock()
+	return slices.ContainsFunc(m.snapshotCollectionOpts, func(o mongo.SnapshotCollectionOptions) bool {
+		return o.Id == opts.Id &&
+			o.DbName == opts.DbName &&
+			o.CollName == opts.CollName &&
+			reflect.DeepEqual(o.Filter, opts.Filter) &&
+			o.RateLimit == opts.RateLimit &&
+			o.Watch != nil
+	})
 }
 
 type mockNatsClient struct {
@@ -461,6 +2355,11 @@ type mockNatsClient struct {
 	mup         sync.Mutex
 	publishOpts []nats.PublishOptions
 	publishErr  error
+
+	mut                        sync.Mutex
+	createTokenStoreOpts       []nats.CreateTokenStoreOptions
+	createStreamTokenStoreOpts []nats.CreateStreamTokenStoreOptions
+	createTokenStoreErr        error
 }
 
 func (m *mockNatsClient) Close() error {
@@ -489,7 +2388,9 @@ func (m *mockNatsClient) AddStream(_ context.Context, opts *nats.AddStreamOption
 func (m *mockNatsClient) StreamWasAdded(opt nats.AddStreamOptions) bool {
 	m.mua.Lock()
 	defer m.mua.Unlock()
-	return slices.Contains(m.addStreamOpts, opt)
+	return slices.ContainsFunc(m.addStreamOpts, func(o nats.AddStreamOptions) bool {
+		return reflect.DeepEqual(o, opt)
+	})
 }
 
 func (m *mockNatsClient) Publish(_ context.Context, opts *nats.PublishOptions) error {
@@ -502,10 +2403,67 @@ func (m *mockNatsClient) Publish(_ context.Context, opts *nats.PublishOptions) e
 	return nil
 }
 
+func (m *mockNatsClient) PublishAsync(ctx context.Context, opts *nats.PublishOptions) (<-chan error, error) {
+	ack := make(chan error, 1)
+	ack <- m.Publish(ctx, opts)
+	return ack, nil
+}
+
 func (m *mockNatsClient) MessageWasPublished(opt nats.PublishOptions) bool {
 	m.mup.Lock()
 	defer m.mup.Unlock()
 	return slices.ContainsFunc(m.publishOpts, func(po nats.PublishOptions) bool {
-		return po.Subj == opt.Subj && po.MsgId == opt.MsgId && bytes.Equal(po.Data, opt.Data)
+		return po.Subj == opt.Subj && po.MsgId == opt.MsgId && bytes.Equal(po.Data, opt.Data) &&
+			po.ResumeToken == opt.ResumeToken && maps.Equal(po.Headers, opt.Headers)
 	})
 }
+
+func (m *mockNatsClient) CreateTokenStore(_ context.Context, opts *nats.CreateTokenStoreOptions) (nats.TokenStore, error) {
+	if m.createTokenStoreErr != nil {
+		return nil, m.createTokenStoreErr
+	}
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.createTokenStoreOpts = append(m.createTokenStoreOpts, *opts)
+	return &mockTokenStore{}, nil
+}
+
+func (m *mockNatsClient) TokenStoreWasCreated(opts nats.CreateTokenStoreOptions) bool {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return slices.Contains(m.createTokenStoreOpts, opts)
+}
+
+func (m *mockNatsClient) CreateStreamTokenStore(_ context.Context, opts *nats.CreateStreamTokenStoreOptions) (nats.TokenStore, error) {
+	if m.createTokenStoreErr != nil {
+		return nil, m.createTokenStoreErr
+	}
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.createStreamTokenStoreOpts = append(m.createStreamTokenStoreOpts, *opts)
+	return &mockTokenStore{}, nil
+}
+
+func (m *mockNatsClient) StreamTokenStoreWasCreated(opts nats.CreateStreamTokenStoreOptions) bool {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	return slices.Contains(m.createStreamTokenStoreOpts, opts)
+}
+
+type mockTokenStore struct {
+	mu    sync.Mutex
+	token string
+}
+
+func (m *mockTokenStore) LastToken(_ context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token, nil
+}
+
+func (m *mockTokenStore) StoreToken(_ context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.token = token
+	return nil
+}
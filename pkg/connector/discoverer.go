@@ -0,0 +1,344 @@
+package connector
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/damianiandrea/mongodb-nats-connector/internal/mongo"
+)
+
+const (
+	discoveryAdded   = "added"
+	discoveryRemoved = "removed"
+	discoveryFailed  = "failed"
+)
+
+// nameTemplateFuncs contains the functions available to the stream and tokens collection name templates.
+var nameTemplateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// collectionPattern represents the collections of a database whose names match a pattern, which are discovered and
+// watched while the Connector runs.
+type collectionPattern struct {
+	dbName                 string
+	collNamePattern        string
+	collNameRegex          *regexp.Regexp // nil if the pattern is a glob
+	streamNameTemplate     *template.Template
+	tokensCollNameTemplate *template.Template
+	discoveryInterval      time.Duration
+	opts                   []CollectionOption
+
+	// sample is the collection built for the pattern itself, used to validate the options once.
+	sample *collection
+}
+
+// discoveredNames represents the names of a discovered collection, as referenced by the name templates.
+type discoveredNames struct {
+	DbName   string
+	CollName string
+}
+
+func newCollectionPattern(config CollectionPatternConfig, opts ...CollectionOption) (*collectionPattern, error) {
+	p := &collectionPattern{
+		dbName:            config.DbName,
+		collNamePattern:   config.CollNamePattern,
+		discoveryInterval: cmp.Or(config.DiscoveryInterval, defaultDiscoveryInterval),
+		opts:              opts,
+	}
+	if config.DiscoveryInterval < 0 {
+		return nil, fmt.Errorf("%w: discoveryInterval cannot be negative", ErrInvalidCollectionPattern)
+	}
+	switch {
+	case config.CollNamePattern == "":
+		return nil, fmt.Errorf("%w: collNamePattern is missing", ErrInvalidCollectionPattern)
+	case len(config.CollNamePattern) > 1 && strings.HasPrefix(config.CollNamePattern, "/") &&
+		strings.HasSuffix(config.CollNamePattern, "/"):
+		regex, err := regexp.Compile(strings.Trim(config.CollNamePattern, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCollectionPattern, err)
+		}
+		p.collNameRegex = regex
+	default:
+		if _, err := path.Match(config.CollNamePattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: %v: %v", ErrInvalidCollectionPattern, err, config.CollNamePattern)
+		}
+	}
+
+	var err error
+	if p.streamNameTemplate, err = parseNameTemplate("streamName", config.StreamNameTemplate); err != nil {
+		return nil, err
+	}
+	if p.tokensCollNameTemplate, err = parseNameTemplate("tokensCollName", config.TokensCollNameTemplate); err != nil {
+		return nil, err
+	}
+	if p.sample, err = p.newCollection(config.CollNamePattern); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseNameTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(nameTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCollectionPattern, err)
+	}
+	return tmpl, nil
+}
+
+// String returns the pattern as 'db.pattern', as reported in logs and metrics.
+func (p *collectionPattern) String() string {
+	return p.dbName + "." + p.collNamePattern
+}
+
+// matches returns whether the given collection name matches the pattern.
+func (p *collectionPattern) matches(collName string) bool {
+	if p.collNameRegex != nil {
+		return p.collNameRegex.MatchString(collName)
+	}
+	matched, _ := path.Match(p.collNamePattern, collName)
+	return matched
+}
+
+// newCollection creates the collection to be watched for the given discovered collection name, with the stream and
+// tokens collection names resulting from the templates.
+func (p *collectionPattern) newCollection(collName string) (*collection, error) {
+	opts := slices.Clone(p.opts)
+	names := discoveredNames{DbName: p.dbName, CollName: collName}
+	if p.streamNameTemplate != nil {
+		streamName, err := executeNameTemplate(p.streamNameTemplate, names)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithStreamName(streamName))
+	}
+	if p.tokensCollNameTemplate != nil {
+		tokensCollName, err := executeNameTemplate(p.tokensCollNameTemplate, names)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTokensCollName(tokensCollName))
+	}
+	coll, err := newCollection(p.dbName, collName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	coll.discovered = true
+	return coll, nil
+}
+
+func executeNameTemplate(tmpl *template.Template, names discoveredNames) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, names); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCollectionPattern, err)
+	}
+	if buf.Len() == 0 {
+		return "", fmt.Errorf("%w: %v is empty for %v.%v", ErrInvalidCollectionPattern, tmpl.Name(), names.DbName,
+			names.CollName)
+	}
+	return buf.String(), nil
+}
+
+// discoverer watches the collections matching the collection patterns, starting and stopping their watchers as they
+// are created and dropped.
+type discoverer struct {
+	logger      *slog.Logger
+	mongoClient mongo.Client
+	collections []*collection // the configured collections, databases and clusters
+	onDiscovery func(pattern, event string, discovered int)
+
+	mu         sync.Mutex
+	discovered map[string]*collection                        // by namespace
+	watchers   map[*collectionPattern]map[string]*collection // the watched collections of each pattern, by name
+	cancels    map[*collection]context.CancelFunc            // stops watching a collection once it is dropped
+}
+
+func newDiscoverer(logger *slog.Logger, mongoClient mongo.Client, collections []*collection,
+	onDiscovery func(pattern, event string, discovered int)) *discoverer {
+	return &discoverer{
+		logger:      logger,
+		mongoClient: mongoClient,
+		collections: collections,
+		onDiscovery: onDiscovery,
+		discovered:  make(map[string]*collection),
+		watchers:    make(map[*collectionPattern]map[string]*collection),
+		cancels:     make(map[*collection]context.CancelFunc),
+	}
+}
+
+// discover lists the collections of the database of the given pattern every discovery interval, until the context is
+// done. The given watch function is called for each new collection matching the pattern, and it must keep watching it
+// until the given context is done, which happens once the collection is dropped, and then call ended. A collection
+// whose watcher ended for any other reason, e.g. because it was dropped and recreated between two discoveries, is
+// watched again by the next discovery.
+func (d *discoverer) discover(ctx context.Context, pattern *collectionPattern,
+	watch func(context.Context, *collection) error) {
+	ticker := time.NewTicker(pattern.discoveryInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.discoverCollections(ctx, pattern, watch); err != nil && ctx.Err() == nil {
+			d.logger.Error("could not discover collections", "pattern", pattern.String(), "err", err)
+			d.observe(pattern, discoveryFailed, d.watched(pattern))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *discoverer) discoverCollections(ctx context.Context, pattern *collectionPattern,
+	watch func(context.Context, *collection) error) error {
+	collNames, err := d.mongoClient.ListCollectionNames(ctx, pattern.dbName)
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs       []error
+		candidates []*collection
+	)
+	for _, collName := range collNames {
+		if d.watching(pattern, collName) || !pattern.matches(collName) {
+			continue
+		}
+		coll, err := pattern.newCollection(collName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		candidates = append(candidates, coll)
+	}
+
+	for _, coll := range candidates {
+		if !d.add(coll, candidates) {
+			continue
+		}
+		watchCtx, cancel := context.WithCancel(ctx)
+		// the watcher is tracked before it starts, since it may end right away
+		d.track(pattern, coll, cancel)
+		if err := watch(watchCtx, coll); err != nil {
+			d.ended(coll)
+			errs = append(errs, fmt.Errorf("could not watch %v: %v", coll.namespace(), err))
+			continue
+		}
+		d.logger.Info("discovered collection", "pattern", pattern.String(), "dbName", coll.dbName,
+			"collName", coll.collName, "streamName", coll.streamName)
+		d.observe(pattern, discoveryAdded, d.watched(pattern))
+	}
+
+	for _, coll := range d.dropped(pattern, collNames) {
+		d.logger.Info("discovered collection dropped, stopped watching", "pattern", pattern.String(),
+			"dbName", coll.dbName, "collName", coll.collName)
+		d.observe(pattern, discoveryRemoved, d.watched(pattern))
+	}
+	return errors.Join(errs...)
+}
+
+// track records the watcher of the given discovered collection, along with the function stopping it.
+func (d *discoverer) track(pattern *collectionPattern, coll *collection, cancel context.CancelFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.watchers[pattern] == nil {
+		d.watchers[pattern] = make(map[string]*collection)
+	}
+	d.watchers[pattern][coll.collName] = coll
+	d.cancels[coll] = cancel
+}
+
+// watching returns whether the given collection name of the given pattern is watched.
+func (d *discoverer) watching(pattern *collectionPattern, collName string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.watchers[pattern][collName]
+	return ok
+}
+
+// watched returns the number of watched collections of the given pattern.
+func (d *discoverer) watched(pattern *collectionPattern) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.watchers[pattern])
+}
+
+// dropped stops watching the collections of the given pattern that are not among the given collection names, and
+// returns them. Their namespace stays reserved until their watcher ends.
+func (d *discoverer) dropped(pattern *collectionPattern, collNames []string) []*collection {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var dropped []*collection
+	for collName, coll := range d.watchers[pattern] {
+		if slices.Contains(collNames, collName) {
+			continue
+		}
+		d.cancels[coll]()
+		delete(d.cancels, coll)
+		delete(d.watchers[pattern], collName)
+		dropped = append(dropped, coll)
+	}
+	return dropped
+}
+
+// ended releases the given discovered collection once its watcher ended, for any reason, so that it can be discovered
+// again.
+func (d *discoverer) ended(coll *collection) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cancel, ok := d.cancels[coll]; ok {
+		cancel()
+		delete(d.cancels, coll)
+	}
+	for _, watchers := range d.watchers {
+		if watchers[coll.collName] == coll {
+			delete(watchers, coll.collName)
+		}
+	}
+	if d.discovered[coll.namespace()] == coll {
+		delete(d.discovered, coll.namespace())
+	}
+}
+
+// add reserves the namespace of the given discovered collection, and returns false if it is already watched, or if it
+// stores the resume tokens or the dead letters of a watched collection or of one of the given candidates, which are
+// discovered along with it.
+func (d *discoverer) add(coll *collection, candidates []*collection) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	namespace := coll.namespace()
+	if _, ok := d.discovered[namespace]; ok {
+		return false
+	}
+	for _, watched := range slices.Concat(d.collections, slices.Collect(maps.Values(d.discovered)), candidates) {
+		if watched == coll {
+			continue
+		}
+		if watched.namespace() == namespace || slices.Contains(watched.storeNamespaces(), namespace) {
+			return false
+		}
+	}
+	d.discovered[namespace] = coll
+	return true
+}
+
+func (d *discoverer) observe(pattern *collectionPattern, event string, discovered int) {
+	if d.onDiscovery != nil {
+		d.onDiscovery(pattern.String(), event, discovered)
+	}
+}
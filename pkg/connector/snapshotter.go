@@ -0,0 +1,167 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/damianiandrea/mongodb-nats-connector/internal/mongo"
+	"github.com/damianiandrea/mongodb-nats-connector/internal/server"
+)
+
+const (
+	snapshotRunning   = "RUNNING"
+	snapshotCompleted = "COMPLETED"
+	snapshotFailed    = "FAILED"
+)
+
+var _ server.Snapshotter = &snapshotter{}
+
+// snapshotter runs the on-demand snapshots requested through the HTTP server, at most one at a time per collection,
+// while the collections keep being watched. It keeps the status of the last snapshot of each collection.
+type snapshotter struct {
+	logger      *slog.Logger
+	mongoClient mongo.Client
+	rateLimit   int
+
+	mu       sync.Mutex
+	watchers []*snapshotWatcher
+	statuses map[string]*server.SnapshotStatus // by namespace
+}
+
+// snapshotWatcher represents a watched collection, database or cluster whose collections can be snapshotted.
+type snapshotWatcher struct {
+	ctx  context.Context
+	coll *collection
+	opts *mongo.WatchCollectionOptions
+}
+
+func newSnapshotter(logger *slog.Logger, mongoClient mongo.Client, rateLimit int) *snapshotter {
+	return &snapshotter{
+		logger:      logger,
+		mongoClient: mongoClient,
+		rateLimit:   rateLimit,
+		statuses:    make(map[string]*server.SnapshotStatus),
+	}
+}
+
+// register makes the collections watched with the given options available for snapshots, which run until the given
+// context is done.
+func (s *snapshotter) register(ctx context.Context, coll *collection, opts *mongo.WatchCollectionOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers = append(s.watchers, &snapshotWatcher{ctx: ctx, coll: coll, opts: opts})
+}
+
+// unregister makes the given collection no longer available for snapshots, once it is no longer watched.
+func (s *snapshotter) unregister(coll *collection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers = slices.DeleteFunc(s.watchers, func(w *snapshotWatcher) bool {
+		return w.coll == coll
+	})
+}
+
+func (s *snapshotter) StartSnapshot(dbName, collName string, req *server.SnapshotRequest) (*server.SnapshotStatus, error) {
+	w := s.watcher(dbName, collName)
+	if w == nil {
+		return nil, fmt.Errorf("%w: %v.%v", server.ErrNotWatched, dbName, collName)
+	}
+	if w.coll.tokensStore == StreamTokensStore {
+		// the last message of the stream must carry a resume token, snapshot events do not have one
+		return nil, fmt.Errorf("%w: resume tokens of %v.%v are stored in the stream", server.ErrInvalidSnapshotRequest,
+			dbName, collName)
+	}
+	filter := bson.D{}
+	if len(req.Filter) > 0 {
+		if err := bson.UnmarshalExtJSON(req.Filter, false, &filter); err != nil {
+			return nil, fmt.Errorf("%w: `filter` must be a json document: %v", server.ErrInvalidSnapshotRequest, err)
+		}
+	}
+	rateLimit := s.rateLimit
+	if req.RateLimit > 0 {
+		rateLimit = req.RateLimit
+	}
+
+	ns := dbName + "." + collName
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status, ok := s.statuses[ns]; ok && status.Status == snapshotRunning {
+		return nil, fmt.Errorf("%w: %v", server.ErrSnapshotRunning, status.Id)
+	}
+	status := &server.SnapshotStatus{
+		Id:        primitive.NewObjectID().Hex(),
+		Db:        dbName,
+		Coll:      collName,
+		Status:    snapshotRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	s.statuses[ns] = status
+
+	snapshotCollOpts := &mongo.SnapshotCollectionOptions{
+		Id:        status.Id,
+		DbName:    dbName,
+		CollName:  collName,
+		Filter:    filter,
+		RateLimit: rateLimit,
+		Watch:     w.opts,
+		OnProgress: func(published int64) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			status.Published = published
+		},
+	}
+	go func() {
+		err := s.mongoClient.SnapshotCollection(w.ctx, snapshotCollOpts)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		completedAt := time.Now().UTC()
+		status.CompletedAt = &completedAt
+		status.Status = snapshotCompleted
+		if err != nil {
+			s.logger.Error("on-demand snapshot failed", "id", status.Id, "dbName", dbName, "collName", collName,
+				"err", err)
+			status.Status = snapshotFailed
+			status.Error = err.Error()
+		}
+	}()
+
+	started := *status
+	return &started, nil
+}
+
+func (s *snapshotter) SnapshotStatus(dbName, collName string) (*server.SnapshotStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, ok := s.statuses[dbName+"."+collName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v.%v", server.ErrSnapshotNotFound, dbName, collName)
+	}
+	current := *status
+	return &current, nil
+}
+
+// watcher returns the watcher of the given collection, or of the database or cluster it belongs to, nil if the
+// collection is not watched.
+func (s *snapshotter) watcher(dbName, collName string) *snapshotWatcher {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var match *snapshotWatcher
+	for _, w := range s.watchers {
+		switch {
+		case w.coll.dbName == dbName && w.coll.collName == collName:
+			return w
+		case w.coll.dbName == dbName && w.coll.collName == "":
+			match = w
+		case w.coll.dbName == "" && match == nil:
+			match = w
+		}
+	}
+	return match
+}
@@ -0,0 +1,149 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/damianiandrea/mongodb-nats-connector/internal/mongo"
+	"github.com/damianiandrea/mongodb-nats-connector/internal/server"
+)
+
+const (
+	watcherUp         = "UP"
+	watcherRestarting = "RESTARTING"
+	watcherStopped    = "STOPPED"
+	// watcherInvalidated represents a watcher that ended normally, as its change stream was invalidated, e.g. because
+	// the watched collection was dropped, and it was not to be restarted.
+	watcherInvalidated = "INVALIDATED"
+)
+
+// watcherState represents the state of a supervised watcher, as reported by the health check.
+type watcherState struct {
+	Status    string `json:"status"`
+	Restarts  int    `json:"restarts,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+var _ server.DetailedMonitor = &supervisor{}
+
+// supervisor runs the watchers of the Connector, isolating their failures according to their failure policy, so that
+// a broken collection does not stop the others. It reports the state of each watcher to the health check.
+type supervisor struct {
+	logger  *slog.Logger
+	backoff mongo.Backoff
+
+	mu     sync.Mutex
+	states map[string]watcherState
+}
+
+func newSupervisor(logger *slog.Logger, backoff mongo.Backoff) *supervisor {
+	return &supervisor{
+		logger:  logger,
+		backoff: backoff,
+		states:  make(map[string]watcherState),
+	}
+}
+
+func (s *supervisor) Name() string {
+	return "watchers"
+}
+
+// Monitor returns an error if any watcher is not up, unless it ended as its change stream was invalidated.
+func (s *supervisor) Monitor(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for namespace, state := range s.states {
+		if state.Status != watcherUp && state.Status != watcherInvalidated {
+			return fmt.Errorf("watcher of %v is %v", namespace, state.Status)
+		}
+	}
+	return nil
+}
+
+// Details returns the state of each watcher, by namespace.
+func (s *supervisor) Details() any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.states) == 0 {
+		return nil
+	}
+	return maps.Clone(s.states)
+}
+
+func (s *supervisor) setState(namespace string, state watcherState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[namespace] = state
+}
+
+// remove removes the state of a watcher that is no longer supervised.
+func (s *supervisor) remove(namespace string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, namespace)
+}
+
+// supervise runs the given watch function until the context is cancelled. When it fails, it is restarted with a
+// backoff, stopped, or its error is returned to stop the Connector, depending on the given failure policy.
+func (s *supervisor) supervise(ctx context.Context, namespace, failurePolicy string, watch func(context.Context) error) error {
+	state := watcherState{Status: watcherUp}
+	s.setState(namespace, state)
+
+	attempts := 0
+	for {
+		start := time.Now()
+		err := watch(ctx) // blocking call
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			// the change stream was invalidated, there is nothing left to watch
+			s.logger.Warn("watcher ended, its change stream was invalidated", "namespace", namespace)
+			state.Status = watcherInvalidated
+			s.setState(namespace, state)
+			return nil
+		}
+
+		s.logger.Error("watcher failed", "namespace", namespace, "failurePolicy", failurePolicy, "err", err)
+		state.LastError = err.Error()
+		switch failurePolicy {
+		case ExitFailurePolicy:
+			state.Status = watcherStopped
+			s.setState(namespace, state)
+			return err
+		case StopFailurePolicy:
+			state.Status = watcherStopped
+			s.setState(namespace, state)
+			return nil
+		}
+
+		if time.Since(start) > s.backoff.Max {
+			// the watcher had been running for a while, its failures are not consecutive
+			attempts = 0
+		}
+		attempts++
+		if s.backoff.MaxAttempts > 0 && attempts > s.backoff.MaxAttempts {
+			s.logger.Error("watcher stopped after too many restarts", "namespace", namespace, "attempts", attempts-1)
+			state.Status = watcherStopped
+			s.setState(namespace, state)
+			return nil
+		}
+
+		delay := s.backoff.Delay(attempts)
+		state.Status = watcherRestarting
+		state.Restarts++
+		s.setState(namespace, state)
+		s.logger.Info("restarting watcher", "namespace", namespace, "attempt", attempts, "backoff", delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		state.Status = watcherUp
+		s.setState(namespace, state)
+	}
+}
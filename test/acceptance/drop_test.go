@@ -4,10 +4,13 @@ package acceptance
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/damianiandrea/mongodb-nats-connector/test/harness"
 )
@@ -28,6 +31,7 @@ func TestMongoDropCollection(t *testing.T) {
 	h.MustWaitForConnector(10 * time.Second)
 
 	h.MustMongoDropCollection(ctx, "test-connector", "coll1")
+	h.MustMongoDropCollection(ctx, "test-connector", "coll2")
 
 	t.Run("does not publish drop message", func(t *testing.T) {
 		h.MustNotReceiveNatsMsg("COLL1.drop", 1*time.Second)
@@ -37,6 +41,14 @@ func TestMongoDropCollection(t *testing.T) {
 		h.MustNotReceiveNatsMsg("COLL1.invalidate", 1*time.Second)
 	})
 
+	t.Run("publishes drop message if opted in", func(t *testing.T) {
+		testDdlIsPublishedToNats(t, h, "COLL2.drop", "drop")
+	})
+
+	t.Run("publishes invalidate message if opted in", func(t *testing.T) {
+		testDdlIsPublishedToNats(t, h, "COLL2.invalidate", "invalidate")
+	})
+
 	t.Run("does not crash connector", func(t *testing.T) {
 		h.MustEnsureConnectorIsUpFor(1 * time.Second)
 	})
@@ -67,7 +79,26 @@ func TestMongoDropDatabase(t *testing.T) {
 		h.MustNotReceiveNatsMsg("COLL1.invalidate", 1*time.Second)
 	})
 
+	t.Run("publishes drop message if opted in", func(t *testing.T) {
+		testDdlIsPublishedToNats(t, h, "COLL2.drop", "drop")
+	})
+
+	t.Run("publishes invalidate message if opted in", func(t *testing.T) {
+		testDdlIsPublishedToNats(t, h, "COLL2.invalidate", "invalidate")
+	})
+
 	t.Run("does not crash connector", func(t *testing.T) {
 		h.MustEnsureConnectorIsUpFor(1 * time.Second)
 	})
 }
+
+func testDdlIsPublishedToNats(t *testing.T, h *harness.Harness, subj, operationType string) {
+	msg := h.MustNatsSubscribeNextMsg(subj, 5*time.Second)
+
+	event := &harness.ChangeEvent{}
+	require.NoError(t, json.Unmarshal(msg.Data, event))
+	require.NotEmpty(t, event.Id.Data)
+	require.Equal(t, event.Id.Data, msg.Header.Get(nats.MsgIdHdr))
+	require.Equal(t, operationType, event.OperationType)
+	require.Equal(t, operationType, msg.Header.Get("Mongo-Operation-Type"))
+}
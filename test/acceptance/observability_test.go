@@ -46,6 +46,7 @@ func TestConnectorObservability(t *testing.T) {
 		require.Equal(t, healthRes.Status, "UP")
 		require.Equal(t, healthRes.Components.Mongo.Status, "UP")
 		require.Equal(t, healthRes.Components.Nats.Status, "UP")
+		require.Equal(t, healthRes.Components.Watchers.Status, "UP")
 	})
 
 	t.Run("metrics", func(t *testing.T) {
@@ -69,8 +70,9 @@ type healthResponse struct {
 }
 
 type components struct {
-	Mongo component `json:"mongo"`
-	Nats  component `json:"nats"`
+	Mongo    component `json:"mongo"`
+	Nats     component `json:"nats"`
+	Watchers component `json:"watchers"`
 }
 
 type component struct {
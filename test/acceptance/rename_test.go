@@ -4,10 +4,14 @@ package acceptance
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 
 	"github.com/damianiandrea/mongodb-nats-connector/test/harness"
 )
@@ -28,6 +32,7 @@ func TestMongoRenameCollection(t *testing.T) {
 	h.MustWaitForConnector(10 * time.Second)
 
 	h.MustMongoRenameCollection(ctx, "test-connector", "coll1", "coll3")
+	h.MustMongoRenameCollection(ctx, "test-connector", "coll2", "coll4")
 
 	t.Run("does not publish rename message", func(t *testing.T) {
 		h.MustNotReceiveNatsMsg("COLL1.rename", 1*time.Second)
@@ -37,7 +42,61 @@ func TestMongoRenameCollection(t *testing.T) {
 		h.MustNotReceiveNatsMsg("COLL1.invalidate", 1*time.Second)
 	})
 
+	t.Run("publishes rename message if opted in", func(t *testing.T) {
+		testDdlIsPublishedToNats(t, h, "COLL2.rename", "rename")
+	})
+
+	t.Run("publishes invalidate message if opted in", func(t *testing.T) {
+		testDdlIsPublishedToNats(t, h, "COLL2.invalidate", "invalidate")
+	})
+
 	t.Run("does not crash connector", func(t *testing.T) {
 		h.MustEnsureConnectorIsUpFor(1 * time.Second)
 	})
 }
+
+func TestMongoRenameCollectionFollowed(t *testing.T) {
+	ctx := context.Background()
+	h := harness.New(t, harness.FromEnv())
+
+	h.MustStartContainer(ctx, harness.Connector)
+	t.Cleanup(func() {
+		h.MustStopContainer(ctx, harness.Connector)
+		assert.NoError(t, h.MongoClient.Database("test-connector").Drop(ctx))
+		assert.NoError(t, h.MongoClient.Database("resume-tokens").Drop(ctx))
+		assert.NoError(t, h.NatsJs.PurgeStream("COLL5"))
+	})
+
+	h.MustWaitForConnector(10 * time.Second)
+
+	h.MustMongoRenameCollection(ctx, "test-connector", "coll5", "coll6")
+
+	t.Run("publishes the change events of the renamed collection", func(t *testing.T) {
+		id := h.MustMongoInsertOne(ctx, "test-connector", "coll6", bson.D{{Key: "message", Value: "hi"}})
+		testLastInsertIsPublishedToNats(t, h, "COLL5", "COLL5.insert", id)
+	})
+
+	t.Run("keeps publishing the change events of the renamed collection once restarted", func(t *testing.T) {
+		h.MustStopContainer(ctx, harness.Connector)
+		// inserted while the connector is stopped, it is published once the renamed collection is watched again
+		id := h.MustMongoInsertOne(ctx, "test-connector", "coll6", bson.D{{Key: "message", Value: "hi"}})
+		h.MustStartContainer(ctx, harness.Connector)
+		h.MustWaitForConnector(10 * time.Second)
+
+		testLastInsertIsPublishedToNats(t, h, "COLL5", "COLL5.insert", id)
+
+		id = h.MustMongoInsertOne(ctx, "test-connector", "coll6", bson.D{{Key: "message", Value: "hi"}})
+		testLastInsertIsPublishedToNats(t, h, "COLL5", "COLL5.insert", id)
+	})
+}
+
+func testLastInsertIsPublishedToNats(t *testing.T, h *harness.Harness, streamName, subj string, id primitive.ObjectID) {
+	require.Eventually(t, func() bool {
+		msg, err := h.NatsJs.GetLastMsg(streamName, subj)
+		if err != nil {
+			return false
+		}
+		event := &harness.ChangeEvent{}
+		return json.Unmarshal(msg.Data, event) == nil && event.FullDocument.Id == id
+	}, 5*time.Second, 100*time.Millisecond)
+}
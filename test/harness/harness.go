@@ -34,6 +34,8 @@ const (
 	Nats2     = "nats2"
 	Nats3     = "nats3"
 	Connector = "connector"
+
+	ConnectorSharded = "connector-sharded"
 )
 
 type Harness struct {
@@ -225,6 +227,18 @@ func (h *Harness) MustMongoRenameCollection(ctx context.Context, dbName, collNam
 	require.NoError(h.t, err)
 }
 
+func (h *Harness) MustMongoShardCollection(ctx context.Context, dbName, collName string, key bson.D) {
+	db := h.MongoClient.Database("admin")
+
+	err := db.RunCommand(ctx, bson.D{{Key: "enableSharding", Value: dbName}}).Err()
+	require.NoError(h.t, err)
+	err = db.RunCommand(ctx, bson.D{
+		{Key: "shardCollection", Value: fmt.Sprintf("%s.%s", dbName, collName)},
+		{Key: "key", Value: key},
+	}).Err()
+	require.NoError(h.t, err)
+}
+
 func (h *Harness) MustNatsSubscribeNextMsg(subj string, timeout time.Duration) *nats.Msg {
 	msg, err := h.mustNatsSubscribeNextMsg(subj, timeout)
 	require.NoError(h.t, err)
@@ -0,0 +1,136 @@
+//go:build integration
+
+package sharded
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/damianiandrea/mongodb-nats-connector/test/harness"
+)
+
+func TestShardedCluster(t *testing.T) {
+	ctx := context.Background()
+	h := harness.New(t, harness.FromEnv())
+
+	h.MustStartContainer(ctx, harness.ConnectorSharded)
+	t.Cleanup(func() {
+		h.MustStopContainer(ctx, harness.ConnectorSharded)
+		assert.NoError(t, h.MongoClient.Database("test-connector").Drop(ctx))
+		assert.NoError(t, h.MongoClient.Database("resume-tokens").Drop(ctx))
+		assert.NoError(t, h.NatsJs.PurgeStream("COLL1"))
+		assert.NoError(t, h.NatsJs.PurgeStream("COLL2"))
+	})
+
+	h.MustWaitForConnector(30 * time.Second)
+
+	t.Run("detects the sharded topology", func(t *testing.T) {
+		response, err := http.Get(fmt.Sprintf("%s/healthz", h.ConnectorUrl))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, response.Body.Close())
+		})
+
+		healthRes := &healthResponse{}
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.NoError(t, json.NewDecoder(response.Body).Decode(healthRes))
+		require.Equal(t, "UP", healthRes.Components.Mongo.Status)
+		require.Equal(t, "sharded", healthRes.Components.Mongo.Details.Topology.Kind)
+		require.ElementsMatch(t, []string{"shard1", "shard2"}, healthRes.Components.Mongo.Details.Topology.Shards)
+	})
+
+	t.Run("publishes the change events of all the shards in cluster time order", func(t *testing.T) {
+		h.MustMongoShardCollection(ctx, "test-connector", "coll1", bson.D{{Key: "_id", Value: "hashed"}})
+
+		h.MustVerifyMessageCorrectness(100, "test-connector", "coll1", func() {})
+	})
+
+	t.Run("counts the change events of each shard after moving a chunk", func(t *testing.T) {
+		if harness.MustGetMongoMajorVersion(t) < 6 {
+			t.Skip("chunk migrations are only reported by MongoDB 6.0 and later")
+		}
+		// coll2 publishes the default operation types only, the chunk migration is observed with showExpandedEvents
+		coll2 := h.MongoClient.Database("test-connector").Collection("coll2")
+		insert := func(id int) {
+			_, err := coll2.InsertOne(ctx, bson.D{{Key: "_id", Value: id}})
+			require.NoError(t, err)
+		}
+		insert(0)
+		h.MustMongoShardCollection(ctx, "test-connector", "coll2", bson.D{{Key: "_id", Value: 1}})
+
+		var db struct {
+			Primary string `bson:"primary"`
+		}
+		require.NoError(t, h.MongoClient.Database("config").Collection("databases").
+			FindOne(ctx, bson.D{{Key: "_id", Value: "test-connector"}}).Decode(&db))
+		toShard := "shard1"
+		if db.Primary == toShard {
+			toShard = "shard2"
+		}
+		admin := h.MongoClient.Database("admin")
+		require.NoError(t, admin.RunCommand(ctx, bson.D{
+			{Key: "split", Value: "test-connector.coll2"},
+			{Key: "middle", Value: bson.D{{Key: "_id", Value: 100}}},
+		}).Err())
+		require.NoError(t, admin.RunCommand(ctx, bson.D{
+			{Key: "moveChunk", Value: "test-connector.coll2"},
+			{Key: "find", Value: bson.D{{Key: "_id", Value: 100}}},
+			{Key: "to", Value: toShard},
+			{Key: "_waitForDelete", Value: true},
+		}).Err())
+
+		for id := 1; id <= 4; id++ {
+			insert(id)
+			insert(100 + id)
+		}
+		msgs := h.MustNatsSubscribeAll("COLL2.insert", 9, 10*time.Second)
+		require.NotContains(t, msgs, (*nats.Msg)(nil))
+
+		require.EventuallyWithT(t, func(c *assert.CollectT) {
+			metrics := mustGetMetrics(t, h.ConnectorUrl)
+			assert.Contains(c, metrics, fmt.Sprintf(
+				`connector_chunk_migrations_total{from_shard="%s",namespace="test-connector.coll2",to_shard="%s"} 1`,
+				db.Primary, toShard))
+			assert.Contains(c, metrics, fmt.Sprintf(
+				`connector_shard_change_events_total{namespace="test-connector.coll2",shard="%s"} 5`, db.Primary))
+			assert.Contains(c, metrics, fmt.Sprintf(
+				`connector_shard_change_events_total{namespace="test-connector.coll2",shard="%s"} 4`, toShard))
+		}, 10*time.Second, 500*time.Millisecond)
+	})
+}
+
+func mustGetMetrics(t *testing.T, connectorUrl string) string {
+	response, err := http.Get(fmt.Sprintf("%s/metrics", connectorUrl))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, response.Body.Close())
+	}()
+
+	metrics, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return string(metrics)
+}
+
+type healthResponse struct {
+	Components struct {
+		Mongo struct {
+			Status  string `json:"status"`
+			Details struct {
+				Topology struct {
+					Kind   string   `json:"kind"`
+					Shards []string `json:"shards"`
+				} `json:"topology"`
+			} `json:"details"`
+		} `json:"mongo"`
+	} `json:"components"`
+}